@@ -2,30 +2,103 @@ package main
 
 import (
 	"os"
+	"strconv"
+	"time"
 	"vind/backend/internal/handler"
+	"vind/backend/internal/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
+// durationEnv reads a duration such as "30s" from the environment, falling back to def.
+func durationEnv(name string, def time.Duration) time.Duration {
+	if val := os.Getenv(name); val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			panic("Invalid " + name + ": " + err.Error())
+		}
+		return d
+	}
+	return def
+}
+
+// intEnv reads an integer from the environment, falling back to def.
+func intEnv(name string, def int) int {
+	if val := os.Getenv(name); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			panic("Invalid " + name + ": " + err.Error())
+		}
+		return n
+	}
+	return def
+}
+
 func main() {
 	err := godotenv.Load("../.env")
 	if err != nil {
 		panic("Error loading .env file")
 	}
 
-	r := gin.Default()
+	if key := os.Getenv("VIND_MASTER_KEY"); key != "" {
+		path := os.Getenv("PROFILES_PATH")
+		if path == "" {
+			path = "profiles.json"
+		}
+		store, err := service.NewProfileStore(path, key)
+		if err != nil {
+			panic("Error loading connection profiles: " + err.Error())
+		}
+		handler.SetProfileStore(store)
+	}
+
+	handler.SetStreamRowLimit(intEnv("STREAM_MAX_ROWS", 1_000_000))
+	handler.SetTransactionIdleTimeout(durationEnv("TRANSACTION_IDLE_TIMEOUT", 5*time.Minute))
+	service.SetExactCountLimits(
+		int64(intEnv("EXACT_COUNT_MAX_ROWS", 1_000_000)),
+		durationEnv("EXACT_COUNT_TIMEOUT", 2*time.Second),
+	)
+
+	r := gin.New()
+	r.Use(handler.AbortFailedResponses(), gin.Logger(), gin.Recovery())
+	r.Use(handler.StatementTimeout(
+		durationEnv("QUERY_TIMEOUT", 30*time.Second),
+		durationEnv("QUERY_TIMEOUT_MAX", 10*time.Minute),
+	))
+	r.Use(handler.TransactionSession())
 
 	r.GET("/ping", handler.Ping)
 
 	r.POST("/connect", handler.ConnectHandler)
+	r.GET("/connections", handler.ListConnectionsHandler)
+	r.DELETE("/connections/:id", handler.CloseConnectionHandler)
+	r.GET("/profiles", handler.ListProfilesHandler)
+	r.POST("/profiles", handler.CreateProfileHandler)
+	r.GET("/profiles/:id", handler.GetProfileHandler)
+	r.PUT("/profiles/:id", handler.UpdateProfileHandler)
+	r.DELETE("/profiles/:id", handler.DeleteProfileHandler)
+	r.POST("/profiles/:id/connect", handler.ConnectProfileHandler)
+	r.GET("/transactions", handler.ListTransactionsHandler)
+	r.POST("/transactions", handler.BeginTransactionHandler)
+	r.POST("/transactions/:id/commit", handler.CommitTransactionHandler)
+	r.POST("/transactions/:id/rollback", handler.RollbackTransactionHandler)
 	r.GET("/tables", handler.ListTablesHandler)
 	r.GET("/columns", handler.ListColumnsHandler)
 	r.POST("/query", handler.QueryHandler)
+	r.POST("/query/export", handler.ExportQueryHandler)
+	r.GET("/query/running", handler.ListRunningQueriesHandler)
+	r.DELETE("/query/:id", handler.CancelQueryHandler)
 	r.GET("/records", handler.TableDataHandler)
+	r.GET("/records/export", handler.ExportTableHandler)
+	r.GET("/records/value", handler.RecordValueHandler)
 	r.POST("/records", handler.InsertRecordHandler)
 	r.PUT("/records", handler.UpdateRecordHandler)
 	r.DELETE("/records", handler.DeleteRecordHandler)
+	r.PUT("/records/row", handler.UpdateRowHandler)
+	r.DELETE("/records/row", handler.DeleteRowHandler)
+	r.POST("/records/batch", handler.BatchRecordsHandler)
+	r.POST("/records/import", handler.ImportCSVHandler)
 	r.POST("/api/schema/tables", handler.CreateTableHandler)
 	r.PATCH("/api/schema/tables/:table_name", handler.AlterTableHandler)
 	r.DELETE("/api/schema/tables/:table_name", handler.DropTableHandler)
@@ -1,9 +1,86 @@
 package helper
 
-import "regexp"
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
 
-var IdentifierRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+// IdentifierError reports a schema, table, column or constraint name that
+// cannot be used as an identifier.
+type IdentifierError struct {
+	Name   string
+	Reason string
+}
+
+func (e *IdentifierError) Error() string {
+	return fmt.Sprintf("invalid identifier %q: %s", e.Name, e.Reason)
+}
+
+// CheckIdentifiers reports the first of names that cannot be quoted as an
+// identifier in dialect d. Any characters other than NUL are allowed, since
+// names are always quoted; length is limited to what the database keeps, as
+// PostgreSQL silently truncates longer names.
+func CheckIdentifiers(d SQLDialect, names ...string) error {
+	for _, name := range names {
+		reason := ""
+		switch {
+		case name == "":
+			reason = "empty name"
+		case !utf8.ValidString(name):
+			reason = "not valid UTF-8"
+		case strings.ContainsRune(name, 0):
+			reason = "contains a NUL character"
+		case d == DialectPostgres && len(name) > 63:
+			reason = "longer than 63 bytes"
+		case d == DialectMySQL && utf8.RuneCountInString(name) > 64:
+			reason = "longer than 64 characters"
+		}
+		if reason != "" {
+			return &IdentifierError{Name: name, Reason: reason}
+		}
+	}
+	return nil
+}
+
+// QuoteIdentifier quotes name as an identifier in dialect d, doubling any
+// embedded quote character, so that its case and characters are kept as
+// given.
+func QuoteIdentifier(d SQLDialect, name string) string {
+	if d == DialectMySQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// QualifiedName is a table name, qualified by its schema unless Schema is
+// empty.
+type QualifiedName struct {
+	Schema string
+	Name   string
+}
+
+// Check reports whether n's schema, if any, and name can be quoted in d.
+func (n QualifiedName) Check(d SQLDialect) error {
+	if n.Schema != "" {
+		if err := CheckIdentifiers(d, n.Schema); err != nil {
+			return err
+		}
+	}
+	return CheckIdentifiers(d, n.Name)
+}
+
+// Quote returns n quoted for d, as schema.name or just name.
+func (n QualifiedName) Quote(d SQLDialect) string {
+	if n.Schema == "" {
+		return QuoteIdentifier(d, n.Name)
+	}
+	return QuoteIdentifier(d, n.Schema) + "." + QuoteIdentifier(d, n.Name)
+}
 
-func IsValidIdentifier(s string) bool {
-	return IdentifierRegex.MatchString(s)
+func (n QualifiedName) String() string {
+	if n.Schema == "" {
+		return n.Name
+	}
+	return n.Schema + "." + n.Name
 }
@@ -0,0 +1,50 @@
+package helper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckIdentifiers(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect SQLDialect
+		names   []string
+		wantErr string
+	}{
+		{name: "mixed case and unicode", names: []string{"Orders", "Ünïcode täble", "my-table"}},
+		{name: "embedded quotes", names: []string{`a"b`, "c`d"}},
+		{name: "empty", names: []string{"ok", ""}, wantErr: "empty name"},
+		{name: "nul", names: []string{"a\x00b"}, wantErr: "NUL"},
+		{name: "invalid utf-8", names: []string{"a\xffb"}, wantErr: "UTF-8"},
+		{name: "postgres length", dialect: DialectPostgres, names: []string{strings.Repeat("é", 32)}, wantErr: "63 bytes"},
+		{name: "mysql counts characters", dialect: DialectMySQL, names: []string{strings.Repeat("é", 64)}},
+		{name: "mysql length", dialect: DialectMySQL, names: []string{strings.Repeat("a", 65)}, wantErr: "64 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckIdentifiers(tt.dialect, tt.names...)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var identErr *IdentifierError
+			assert.ErrorAs(t, err, &identErr)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestQualifiedNameQuote(t *testing.T) {
+	name := QualifiedName{Schema: "Sales", Name: `Order "Items"`}
+	assert.Equal(t, `"Sales"."Order ""Items"""`, name.Quote(DialectPostgres))
+	assert.Equal(t, `"Sales"."Order ""Items"""`, name.Quote(DialectSQLite))
+	assert.Equal(t, "`Sales`.`Order \"Items\"`", name.Quote(DialectMySQL))
+	assert.Equal(t, "`a``b`", QualifiedName{Name: "a`b"}.Quote(DialectMySQL))
+	assert.Equal(t, `Sales.Order "Items"`, name.String())
+
+	assert.NoError(t, QualifiedName{Name: "Orders"}.Check(DialectPostgres))
+	assert.Error(t, QualifiedName{Schema: "a\x00", Name: "Orders"}.Check(DialectPostgres))
+}
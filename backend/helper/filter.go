@@ -0,0 +1,189 @@
+package helper
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FilterError reports a table-data filter that could not be parsed or that
+// names an unknown column.
+type FilterError struct {
+	Filter string
+	Reason string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("invalid filter %q: %s", e.Filter, e.Reason)
+}
+
+// filterArity is how many values each filter operator takes; -1 means one
+// or more.
+var filterArity = map[string]int{
+	"=":           1,
+	"!=":          1,
+	">":           1,
+	"<":           1,
+	">=":          1,
+	"<=":          1,
+	"like":        1,
+	"not like":    1,
+	"ilike":       1,
+	"in":          -1,
+	"not in":      -1,
+	"between":     2,
+	"is null":     0,
+	"is not null": 0,
+}
+
+// Filter is a single column condition.
+type Filter struct {
+	Column string
+	Op     string // one of the filterArity keys
+	Values []string
+}
+
+// ParseFilters parses table-data filters of the form column:op:value.
+// Operators are case-insensitive and may use underscores for spaces
+// ("not_in"). in and not in take a comma-separated list and between takes
+// exactly two values; is null and is not null take none. Alternatives
+// separated by "|" within one filter are OR-ed together, and separate
+// filters are AND-ed. A backslash escapes a literal "|" or ",". Columns
+// are checked with known, if it is not nil.
+func ParseFilters(filters []string, known func(column string) bool) ([][]Filter, error) {
+	groups := make([][]Filter, 0, len(filters))
+	for _, raw := range filters {
+		var group []Filter
+		for _, alt := range splitEscaped(raw, '|') {
+			f, err := parseFilter(alt)
+			if err != nil {
+				return nil, &FilterError{Filter: raw, Reason: err.Error()}
+			}
+			if known != nil && !known(f.Column) {
+				return nil, &FilterError{Filter: raw, Reason: fmt.Sprintf("unknown column %q", f.Column)}
+			}
+			group = append(group, f)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+func parseFilter(s string) (Filter, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) < 2 {
+		return Filter{}, fmt.Errorf("expected column:operator:value")
+	}
+	f := Filter{Column: parts[0], Op: normalizeFilterOp(parts[1])}
+	if f.Column == "" {
+		return Filter{}, fmt.Errorf("missing column")
+	}
+	arity, ok := filterArity[f.Op]
+	if !ok {
+		return Filter{}, fmt.Errorf("unsupported operator %q", parts[1])
+	}
+
+	hasValue := len(parts) == 3
+	switch arity {
+	case 0:
+		if hasValue && parts[2] != "" {
+			return Filter{}, fmt.Errorf("%s takes no value", f.Op)
+		}
+	case 1:
+		if !hasValue {
+			return Filter{}, fmt.Errorf("missing value")
+		}
+		f.Values = []string{parts[2]}
+	default:
+		if !hasValue || parts[2] == "" {
+			return Filter{}, fmt.Errorf("missing value")
+		}
+		f.Values = splitEscaped(parts[2], ',')
+		if arity > 0 && len(f.Values) != arity {
+			return Filter{}, fmt.Errorf("%s takes %d comma-separated values", f.Op, arity)
+		}
+	}
+	return f, nil
+}
+
+// normalizeFilterOp lower-cases op and turns underscores and runs of
+// spaces into single spaces.
+func normalizeFilterOp(op string) string {
+	op = strings.Join(strings.Fields(strings.ToLower(strings.ReplaceAll(op, "_", " "))), " ")
+	if op == "<>" {
+		return "!="
+	}
+	return op
+}
+
+// splitEscaped splits s at each sep not preceded by a backslash and removes
+// the backslash from escaped separators. Other backslashes are kept, since
+// LIKE patterns use them.
+func splitEscaped(s string, sep byte) []string {
+	var parts []string
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == sep:
+			b.WriteByte(sep)
+			i++
+		case s[i] == sep:
+			parts = append(parts, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return append(parts, b.String())
+}
+
+// FilterSQL parses filters with ParseFilters, checking their columns with
+// known, and returns the matching WHERE condition, without the WHERE
+// keyword, and the values to bind to it. Columns are quoted with quote, and
+// Postgres placeholders are numbered from firstArg. ilike is emulated with
+// LOWER on dialects that lack it.
+func FilterSQL(filters []string, known func(column string) bool, d SQLDialect, quote func(string) string, firstArg int) (string, []any, error) {
+	groups, err := ParseFilters(filters, known)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var args []any
+	placeholder := func(v string) string {
+		args = append(args, v)
+		return Placeholder(d, firstArg+len(args)-1)
+	}
+
+	conditions := make([]string, 0, len(groups))
+	for _, group := range groups {
+		alts := make([]string, len(group))
+		for i, f := range group {
+			col := quote(f.Column)
+			switch f.Op {
+			case "is null", "is not null":
+				alts[i] = col + " " + strings.ToUpper(f.Op)
+			case "in", "not in":
+				list := make([]string, len(f.Values))
+				for j, v := range f.Values {
+					list[j] = placeholder(v)
+				}
+				alts[i] = fmt.Sprintf("%s %s (%s)", col, strings.ToUpper(f.Op), strings.Join(list, ", "))
+			case "between":
+				alts[i] = fmt.Sprintf("%s BETWEEN %s AND %s", col, placeholder(f.Values[0]), placeholder(f.Values[1]))
+			case "ilike":
+				if d == DialectPostgres {
+					alts[i] = fmt.Sprintf("%s ILIKE %s", col, placeholder(f.Values[0]))
+				} else {
+					alts[i] = fmt.Sprintf("LOWER(%s) LIKE LOWER(%s)", col, placeholder(f.Values[0]))
+				}
+			default:
+				alts[i] = fmt.Sprintf("%s %s %s", col, strings.ToUpper(f.Op), placeholder(f.Values[0]))
+			}
+		}
+		if len(alts) == 1 {
+			conditions = append(conditions, alts[0])
+		} else {
+			conditions = append(conditions, "("+strings.Join(alts, " OR ")+")")
+		}
+	}
+	return strings.Join(conditions, " AND "), args, nil
+}
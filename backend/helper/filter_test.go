@@ -0,0 +1,91 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func quoteTestIdent(name string) string { return `"` + name + `"` }
+
+func TestFilterSQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		filters  []string
+		dialect  SQLDialect
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:     "comparisons",
+			filters:  []string{"age:>=:30", "name:LIKE:jo%", "role:<>:admin"},
+			wantSQL:  `"age" >= $3 AND "name" LIKE $4 AND "role" != $5`,
+			wantArgs: []any{"30", "jo%", "admin"},
+		},
+		{
+			name:     "in and null checks",
+			filters:  []string{"status:in:a,b", "deleted_at:is null"},
+			wantSQL:  `"status" IN ($3, $4) AND "deleted_at" IS NULL`,
+			wantArgs: []any{"a", "b"},
+		},
+		{
+			name:     "underscored operators and escapes",
+			filters:  []string{`tag:not_in:x\,y,z`, "note:not like:a\\_b", "ended_at:is_not_null:"},
+			dialect:  DialectMySQL,
+			wantSQL:  `"tag" NOT IN (?, ?) AND "note" NOT LIKE ? AND "ended_at" IS NOT NULL`,
+			wantArgs: []any{"x,y", "z", "a\\_b"},
+		},
+		{
+			name:     "or group",
+			filters:  []string{`status:=:open|priority:between:1,3`, `title:=:a\|b`},
+			wantSQL:  `("status" = $3 OR "priority" BETWEEN $4 AND $5) AND "title" = $6`,
+			wantArgs: []any{"open", "1", "3", "a|b"},
+		},
+		{
+			name:     "ilike is emulated outside postgres",
+			filters:  []string{"name:ilike:JO%"},
+			dialect:  DialectSQLite,
+			wantSQL:  `LOWER("name") LIKE LOWER(?)`,
+			wantArgs: []any{"JO%"},
+		},
+		{
+			name:    "no filters",
+			wantSQL: "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sql, args, err := FilterSQL(tc.filters, nil, tc.dialect, quoteTestIdent, 3)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantSQL, sql)
+			assert.Equal(t, tc.wantArgs, args)
+		})
+	}
+}
+
+func TestFilterSQLErrors(t *testing.T) {
+	tests := []struct {
+		filter  string
+		wantErr string
+	}{
+		{"name", `invalid filter "name": expected column:operator:value`},
+		{"name:regex:^a", `invalid filter "name:regex:^a": unsupported operator "regex"`},
+		{"age:>=", `invalid filter "age:>=": missing value`},
+		{"age:between:1", `invalid filter "age:between:1": between takes 2 comma-separated values`},
+		{"id:in:", `invalid filter "id:in:": missing value`},
+		{"x:is null:1", `invalid filter "x:is null:1": is null takes no value`},
+		{"a:=:1|:=:2", `invalid filter "a:=:1|:=:2": missing column`},
+		{"id:=:1|nmae:=:x", `invalid filter "id:=:1|nmae:=:x": unknown column "nmae"`},
+	}
+	known := func(column string) bool { return column != "nmae" }
+
+	for _, tc := range tests {
+		t.Run(tc.filter, func(t *testing.T) {
+			_, _, err := FilterSQL([]string{tc.filter}, known, DialectPostgres, quoteTestIdent, 1)
+			var filterErr *FilterError
+			assert.ErrorAs(t, err, &filterErr)
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
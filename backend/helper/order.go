@@ -0,0 +1,175 @@
+package helper
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OrderByError reports a table-data sort order that could not be parsed or
+// names a column the table does not have.
+type OrderByError struct {
+	OrderBy string
+	Reason  string
+}
+
+func (e *OrderByError) Error() string {
+	return fmt.Sprintf("invalid order_by %q: %s", e.OrderBy, e.Reason)
+}
+
+// OrderTerm is one column of a sort order.
+type OrderTerm struct {
+	Column string
+	Desc   bool
+	Nulls  string // "", "first" or "last"; "" leaves the database default
+}
+
+// ParseOrderBy parses a comma-separated sort order such as
+// "created_at:desc:nulls_last,id". Each term is a column optionally followed
+// by asc or desc and then by nulls_first or nulls_last, case-insensitively.
+// known, if set, reports whether a column exists.
+func ParseOrderBy(orderBy string, known func(column string) bool) ([]OrderTerm, error) {
+	if orderBy == "" {
+		return nil, nil
+	}
+
+	var terms []OrderTerm
+	for _, raw := range strings.Split(orderBy, ",") {
+		parts := strings.Split(strings.TrimSpace(raw), ":")
+		term := OrderTerm{Column: parts[0]}
+		if term.Column == "" {
+			return nil, &OrderByError{OrderBy: orderBy, Reason: "missing column"}
+		}
+		if known != nil && !known(term.Column) {
+			return nil, &OrderByError{OrderBy: orderBy, Reason: fmt.Sprintf("unknown column %q", term.Column)}
+		}
+
+		opts := parts[1:]
+		if len(opts) > 0 {
+			switch normalizeFilterOp(opts[0]) {
+			case "desc":
+				term.Desc = true
+				opts = opts[1:]
+			case "asc":
+				opts = opts[1:]
+			}
+		}
+		if len(opts) > 0 {
+			switch normalizeFilterOp(opts[0]) {
+			case "nulls first":
+				term.Nulls = "first"
+				opts = opts[1:]
+			case "nulls last":
+				term.Nulls = "last"
+				opts = opts[1:]
+			}
+		}
+		if len(opts) > 0 {
+			return nil, &OrderByError{OrderBy: orderBy, Reason: fmt.Sprintf("unsupported option %q for %s", opts[0], term.Column)}
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+// OrderBySQL returns the ORDER BY list for terms, without the ORDER BY
+// keyword. MySQL has no NULLS FIRST/LAST, so it sorts on IS NULL first.
+func OrderBySQL(terms []OrderTerm, d SQLDialect, quote func(string) string) string {
+	list := make([]string, 0, len(terms))
+	for _, t := range terms {
+		col := quote(t.Column)
+		dir := ""
+		if t.Desc {
+			dir = " DESC"
+		}
+
+		switch {
+		case t.Nulls == "":
+			list = append(list, col+dir)
+		case d == DialectMySQL:
+			nullsDir := " DESC"
+			if t.Nulls == "last" {
+				nullsDir = ""
+			}
+			list = append(list, col+" IS NULL"+nullsDir, col+dir)
+		default:
+			list = append(list, col+dir+" NULLS "+strings.ToUpper(t.Nulls))
+		}
+	}
+	return strings.Join(list, ", ")
+}
+
+// NullsFirst reports whether NULLs sort before other values under t in
+// dialect d. Postgres treats NULL as larger than any value by default,
+// MySQL and SQLite as smaller.
+func (t OrderTerm) NullsFirst(d SQLDialect) bool {
+	switch t.Nulls {
+	case "first":
+		return true
+	case "last":
+		return false
+	}
+	if d == DialectPostgres {
+		return t.Desc
+	}
+	return !t.Desc
+}
+
+// Reverse returns the term that sorts rows in the opposite order, with the
+// NULLs position made explicit.
+func (t OrderTerm) Reverse(d SQLDialect) OrderTerm {
+	nulls := "first"
+	if t.NullsFirst(d) {
+		nulls = "last"
+	}
+	return OrderTerm{Column: t.Column, Desc: !t.Desc, Nulls: nulls}
+}
+
+// KeysetSQL returns a condition, without the WHERE keyword, matching the
+// rows that sort strictly after a row whose terms columns hold values, and
+// the values to bind to it. Postgres placeholders are numbered from
+// firstArg.
+func KeysetSQL(terms []OrderTerm, values []any, d SQLDialect, quote func(string) string, firstArg int) (string, []any) {
+	var args []any
+	placeholder := func(v any) string {
+		args = append(args, v)
+		return Placeholder(d, firstArg+len(args)-1)
+	}
+
+	// Row (a, b) follows (x, y) when a follows x, or a = x and b follows y.
+	var alts []string
+	for i, t := range terms {
+		if values[i] == nil && !t.NullsFirst(d) {
+			continue // nothing sorts after the trailing NULLs
+		}
+
+		var conds []string
+		for j, prev := range terms[:i] {
+			if values[j] == nil {
+				conds = append(conds, quote(prev.Column)+" IS NULL")
+			} else {
+				conds = append(conds, quote(prev.Column)+" = "+placeholder(values[j]))
+			}
+		}
+
+		col := quote(t.Column)
+		if values[i] == nil {
+			conds = append(conds, col+" IS NOT NULL")
+		} else {
+			op := " > "
+			if t.Desc {
+				op = " < "
+			}
+			after := col + op + placeholder(values[i])
+			if !t.NullsFirst(d) {
+				after = "(" + after + " OR " + col + " IS NULL)"
+			}
+			conds = append(conds, after)
+		}
+		alts = append(alts, "("+strings.Join(conds, " AND ")+")")
+	}
+
+	if len(alts) == 0 {
+		return "1 = 0", nil
+	}
+	return "(" + strings.Join(alts, " OR ") + ")", args
+}
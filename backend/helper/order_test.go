@@ -0,0 +1,74 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderBySQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		orderBy string
+		dialect SQLDialect
+		want    string
+	}{
+		{name: "single column", orderBy: "id", want: `"id"`},
+		{name: "directions", orderBy: "created_at:desc, id:ASC", want: `"created_at" DESC, "id"`},
+		{name: "nulls ordering", orderBy: "due:asc:nulls_last,done:NULLS FIRST", want: `"due" NULLS LAST, "done" NULLS FIRST`},
+		{name: "mysql nulls ordering", orderBy: "due:desc:nulls_last,done:nulls_first", dialect: DialectMySQL, want: `"due" IS NULL, "due" DESC, "done" IS NULL DESC, "done"`},
+		{name: "empty", orderBy: "", want: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			terms, err := ParseOrderBy(tc.orderBy, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, OrderBySQL(terms, tc.dialect, quoteTestIdent))
+		})
+	}
+}
+
+func TestParseOrderByErrors(t *testing.T) {
+	known := func(col string) bool { return col == "id" || col == "name" }
+
+	tests := []struct {
+		orderBy string
+		wantErr string
+	}{
+		{"missing", `invalid order_by "missing": unknown column "missing"`},
+		{"id:sideways", `invalid order_by "id:sideways": unsupported option "sideways" for id`},
+		{"id:nulls_last:desc", `invalid order_by "id:nulls_last:desc": unsupported option "desc" for id`},
+		{"id,,name", `invalid order_by "id,,name": missing column`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.orderBy, func(t *testing.T) {
+			_, err := ParseOrderBy(tc.orderBy, known)
+			var orderErr *OrderByError
+			assert.ErrorAs(t, err, &orderErr)
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestKeysetSQL(t *testing.T) {
+	terms := []OrderTerm{{Column: "at", Desc: true}, {Column: "id"}}
+
+	sql, args := KeysetSQL(terms, []any{"2024-01-01", 7}, DialectPostgres, quoteTestIdent, 3)
+	assert.Equal(t, `(("at" < $3) OR ("at" = $4 AND ("id" > $5 OR "id" IS NULL)))`, sql)
+	assert.Equal(t, []any{"2024-01-01", "2024-01-01", 7}, args)
+
+	// Postgres sorts NULLs first in descending order, so all other values follow.
+	sql, args = KeysetSQL(terms, []any{nil, 7}, DialectPostgres, quoteTestIdent, 1)
+	assert.Equal(t, `(("at" IS NOT NULL) OR ("at" IS NULL AND ("id" > $1 OR "id" IS NULL)))`, sql)
+	assert.Equal(t, []any{7}, args)
+
+	// SQLite sorts them last, so nothing follows a NULL except on the tiebreaker.
+	sql, args = KeysetSQL(terms, []any{nil, 7}, DialectSQLite, quoteTestIdent, 1)
+	assert.Equal(t, `(("at" IS NULL AND "id" > ?))`, sql)
+	assert.Equal(t, []any{7}, args)
+
+	reversed := []OrderTerm{terms[0].Reverse(DialectSQLite), terms[1].Reverse(DialectSQLite)}
+	assert.Equal(t, []OrderTerm{{Column: "at", Nulls: "first"}, {Column: "id", Desc: true, Nulls: "last"}}, reversed)
+}
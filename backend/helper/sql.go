@@ -0,0 +1,736 @@
+package helper
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SQLDialect selects the lexical rules TokenizeSQL applies.
+type SQLDialect int
+
+const (
+	DialectPostgres SQLDialect = iota
+	DialectMySQL
+	DialectSQLite
+)
+
+type SQLTokenKind int
+
+const (
+	SQLWord        SQLTokenKind = iota // keyword or bare identifier
+	SQLQuotedIdent                     // "name", `name` or [name]
+	SQLString                          // '...', E'...', $tag$...$tag$ and MySQL "..."
+	SQLNumber
+	SQLParam // $1, ?, ?1, :name, @name
+	SQLPunct // operators, parentheses, commas and semicolons
+)
+
+// SQLToken is a lexical token of a SQL string. Start and End are byte
+// offsets into the original string.
+type SQLToken struct {
+	Kind  SQLTokenKind
+	Text  string
+	Start int
+	End   int
+}
+
+// Is reports whether t is the word kw, compared case-insensitively.
+func (t SQLToken) Is(kw string) bool {
+	return t.Kind == SQLWord && strings.EqualFold(t.Text, kw)
+}
+
+// TokenizeSQL splits sql into tokens, dropping whitespace and comments.
+// Unterminated strings and comments run to the end of the input rather than
+// failing, since the server reports a better error for them.
+func TokenizeSQL(sql string, d SQLDialect) []SQLToken {
+	var tokens []SQLToken
+	emit := func(kind SQLTokenKind, start, end int) {
+		tokens = append(tokens, SQLToken{Kind: kind, Text: sql[start:end], Start: start, End: end})
+	}
+	brackets := 0 // depth of array subscripts, such as arr[lo:hi]
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case c == '-' && strings.HasPrefix(sql[i:], "--"),
+			c == '#' && d == DialectMySQL:
+			i = skipLine(sql, i)
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			i = skipBlockComment(sql, i, d == DialectPostgres)
+		case c == '\'':
+			end := scanQuoted(sql, i, '\'', d == DialectMySQL)
+			emit(SQLString, i, end)
+			i = end
+		case (c == 'E' || c == 'e') && d == DialectPostgres && strings.HasPrefix(sql[i+1:], "'"):
+			end := scanQuoted(sql, i+1, '\'', true)
+			emit(SQLString, i, end)
+			i = end
+		case c == '"':
+			end := scanQuoted(sql, i, '"', d == DialectMySQL)
+			if d == DialectMySQL {
+				emit(SQLString, i, end)
+			} else {
+				emit(SQLQuotedIdent, i, end)
+			}
+			i = end
+		case c == '`' && d != DialectPostgres:
+			end := scanQuoted(sql, i, '`', false)
+			emit(SQLQuotedIdent, i, end)
+			i = end
+		case c == '[' && d == DialectSQLite:
+			end := strings.IndexByte(sql[i:], ']')
+			if end < 0 {
+				end = len(sql)
+			} else {
+				end += i + 1
+			}
+			emit(SQLQuotedIdent, i, end)
+			i = end
+		case c == '$' && d == DialectPostgres:
+			if tag, ok := dollarTag(sql[i:]); ok {
+				end := strings.Index(sql[i+len(tag):], tag)
+				if end < 0 {
+					end = len(sql)
+				} else {
+					end += i + 2*len(tag)
+				}
+				emit(SQLString, i, end)
+				i = end
+			} else if end := scanDigits(sql, i+1); end > i+1 {
+				emit(SQLParam, i, end)
+				i = end
+			} else {
+				emit(SQLPunct, i, i+1)
+				i++
+			}
+		case c == '?' && d != DialectPostgres:
+			end := scanDigits(sql, i+1)
+			emit(SQLParam, i, end)
+			i = end
+		case c == ':' && strings.HasPrefix(sql[i:], "::"):
+			emit(SQLPunct, i, i+2)
+			i += 2
+		case c == ':' && brackets > 0 && endsOperand(tokens):
+			// The colon between the bounds of an array slice.
+			emit(SQLPunct, i, i+1)
+			i++
+		case (c == ':' || (c == '@' || c == '$') && d == DialectSQLite) && isWordStart(sql, i+1):
+			end := scanWord(sql, i+1)
+			emit(SQLParam, i, end)
+			i = end
+		case c >= '0' && c <= '9', c == '.' && i+1 < len(sql) && sql[i+1] >= '0' && sql[i+1] <= '9':
+			end := scanNumber(sql, i)
+			emit(SQLNumber, i, end)
+			i = end
+		case isWordStart(sql, i):
+			end := scanWord(sql, i)
+			emit(SQLWord, i, end)
+			i = end
+		default:
+			_, size := utf8.DecodeRuneInString(sql[i:])
+			emit(SQLPunct, i, i+size)
+			i += size
+			if c == '[' {
+				brackets++
+			} else if c == ']' && brackets > 0 {
+				brackets--
+			}
+		}
+	}
+	return tokens
+}
+
+// endsOperand reports whether the last token can end the lower bound of an
+// array slice, so that a colon after it separates the bounds rather than
+// starting a :name parameter.
+func endsOperand(tokens []SQLToken) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	switch last := tokens[len(tokens)-1]; last.Kind {
+	case SQLWord, SQLQuotedIdent, SQLNumber, SQLParam:
+		return true
+	case SQLPunct:
+		return last.Text == "]" || last.Text == ")"
+	}
+	return false
+}
+
+func skipLine(sql string, i int) int {
+	if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+		return i + end + 1
+	}
+	return len(sql)
+}
+
+// skipBlockComment skips a /* */ comment. Postgres allows them to nest.
+func skipBlockComment(sql string, i int, nested bool) int {
+	depth := 0
+	for i < len(sql) {
+		switch {
+		case strings.HasPrefix(sql[i:], "/*"):
+			if depth > 0 && !nested {
+				i += 2
+				continue
+			}
+			depth++
+			i += 2
+		case strings.HasPrefix(sql[i:], "*/"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return len(sql)
+}
+
+// scanQuoted returns the offset just past the string or identifier quoted
+// with q that starts at sql[i]. A doubled quote is an escaped quote; with
+// backslash set, so is \q.
+func scanQuoted(sql string, i int, q byte, backslash bool) int {
+	for j := i + 1; j < len(sql); j++ {
+		switch sql[j] {
+		case '\\':
+			if backslash {
+				j++
+			}
+		case q:
+			if j+1 < len(sql) && sql[j+1] == q {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(sql)
+}
+
+// dollarTag returns the $tag$ opening a Postgres dollar-quoted string.
+func dollarTag(s string) (string, bool) {
+	for j := 1; j < len(s); j++ {
+		c := s[j]
+		switch {
+		case c == '$':
+			return s[:j+1], true
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80:
+		case c >= '0' && c <= '9' && j > 1:
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func scanDigits(sql string, i int) int {
+	for i < len(sql) && sql[i] >= '0' && sql[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+func scanNumber(sql string, i int) int {
+	i = scanDigits(sql, i)
+	if i < len(sql) && sql[i] == '.' {
+		i = scanDigits(sql, i+1)
+	}
+	if i < len(sql) && (sql[i] == 'e' || sql[i] == 'E') {
+		j := i + 1
+		if j < len(sql) && (sql[j] == '+' || sql[j] == '-') {
+			j++
+		}
+		if end := scanDigits(sql, j); end > j {
+			i = end
+		}
+	}
+	return i
+}
+
+func isWordStart(sql string, i int) bool {
+	if i >= len(sql) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(sql[i:])
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func scanWord(sql string, i int) int {
+	for i < len(sql) {
+		r, size := utf8.DecodeRuneInString(sql[i:])
+		if r != '_' && r != '$' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// SQLStatementInfo describes what kind of statement a SQL string holds.
+type SQLStatementInfo struct {
+	// Command is the statement's leading keyword, upper-cased. For WITH
+	// queries it is the keyword of the main statement after the CTEs.
+	Command string
+	// ReturnsRows reports whether the statement produces a result set.
+	ReturnsRows bool
+}
+
+// rowCommands are statements that always produce a result set.
+var rowCommands = map[string]bool{
+	"SELECT":   true,
+	"VALUES":   true,
+	"TABLE":    true,
+	"SHOW":     true,
+	"EXPLAIN":  true,
+	"DESCRIBE": true,
+	"DESC":     true,
+	"PRAGMA":   true,
+	"FETCH":    true,
+	"CALL":     true,
+}
+
+// IsDML reports whether the statement modifies rows, so that its rows
+// affected count is meaningful.
+func (s SQLStatementInfo) IsDML() bool {
+	switch s.Command {
+	case "INSERT", "UPDATE", "DELETE", "MERGE", "REPLACE":
+		return true
+	}
+	return false
+}
+
+// ClassifySQL inspects a single statement without running it.
+func ClassifySQL(sql string, d SQLDialect) SQLStatementInfo {
+	tokens := TokenizeSQL(sql, d)
+
+	var info SQLStatementInfo
+	depth := 0
+	isWith := false
+	for _, t := range tokens {
+		if t.Kind == SQLPunct {
+			switch t.Text {
+			case "(":
+				depth++
+			case ")":
+				depth--
+			}
+			continue
+		}
+		if t.Kind != SQLWord {
+			if info.Command == "" {
+				break
+			}
+			continue
+		}
+
+		word := strings.ToUpper(t.Text)
+		switch {
+		case info.Command == "" && word == "WITH":
+			isWith = true
+			info.Command = word
+		case info.Command == "":
+			info.Command = word
+		case isWith && depth == 0 && (rowCommands[word] || word == "INSERT" || word == "UPDATE" || word == "DELETE" || word == "MERGE"):
+			isWith = false
+			info.Command = word
+		case depth == 0 && word == "RETURNING":
+			info.ReturnsRows = true
+		}
+	}
+
+	if rowCommands[info.Command] {
+		info.ReturnsRows = true
+	}
+	return info
+}
+
+// Identifier returns the name an identifier token stands for: quoted
+// identifiers without their quotes, and words folded to lower case on
+// PostgreSQL, which folds unquoted names. ok is false for other tokens.
+func (t SQLToken) Identifier(d SQLDialect) (name string, ok bool) {
+	switch t.Kind {
+	case SQLWord:
+		if d == DialectPostgres {
+			return strings.ToLower(t.Text), true
+		}
+		return t.Text, true
+	case SQLQuotedIdent:
+		if len(t.Text) < 2 {
+			return "", false
+		}
+		q, inner := t.Text[0], t.Text[1:len(t.Text)-1]
+		if q == '[' {
+			return inner, true
+		}
+		return strings.ReplaceAll(inner, string([]byte{q, q}), string(q)), true
+	}
+	return "", false
+}
+
+// SelectSource describes a SELECT whose rows are rows of a single table.
+type SelectSource struct {
+	Table QualifiedName
+
+	columns  map[string]string // result column to the table column it reads
+	star     bool              // the select list includes * or table.*
+	computed map[string]bool   // names given to other select list items
+	fold     bool              // names match regardless of case, as on MySQL and SQLite
+}
+
+// Column returns the table column that the result column name reads, if it
+// is a plain reference to one rather than a computed value.
+func (s SelectSource) Column(name string) (string, bool) {
+	if col, ok := s.columns[name]; ok {
+		return col, true
+	}
+	if s.fold {
+		for result, col := range s.columns {
+			if strings.EqualFold(result, name) {
+				return col, true
+			}
+		}
+	}
+	if s.star && !s.computed[name] {
+		for computed := range s.computed {
+			if s.fold && strings.EqualFold(computed, name) {
+				return "", false
+			}
+		}
+		return name, true
+	}
+	return "", false
+}
+
+// selectClauses may follow the table of a single-table SELECT.
+var selectClauses = map[string]bool{
+	"WHERE": true, "ORDER": true, "LIMIT": true, "OFFSET": true, "FETCH": true, "FOR": true, "WINDOW": true,
+}
+
+// selectBreaks make a SELECT's rows something other than its table's rows.
+var selectBreaks = map[string]bool{
+	"GROUP": true, "HAVING": true, "UNION": true, "INTERSECT": true, "EXCEPT": true, "INTO": true,
+}
+
+// ParseSelectSource recognises a SELECT that reads a single table, without
+// joins, grouping, DISTINCT, set operations or a subquery in FROM, and
+// reports which of its result columns are plain references to columns of
+// that table. ok is false for any other statement.
+func ParseSelectSource(sql string, d SQLDialect) (src SelectSource, ok bool) {
+	tokens := TokenizeSQL(sql, d)
+	for len(tokens) > 0 && tokens[len(tokens)-1].Text == ";" {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) < 4 || !tokens[0].Is("SELECT") || tokens[1].Is("DISTINCT") {
+		return src, false
+	}
+
+	// Split the select list into items at top-level commas, up to FROM.
+	var items [][]SQLToken
+	start, from, depth := 1, -1, 0
+	if tokens[1].Is("ALL") {
+		start = 2
+	}
+	for i := start; i < len(tokens) && from < 0; i++ {
+		t := tokens[i]
+		switch {
+		case t.Text == "(" || t.Text == "[":
+			depth++
+		case t.Text == ")" || t.Text == "]":
+			depth--
+		case depth > 0:
+		case t.Text == ";":
+			return src, false
+		case t.Text == ",":
+			items = append(items, tokens[start:i])
+			start = i + 1
+		case t.Is("FROM"):
+			items = append(items, tokens[start:i])
+			from = i
+		case t.Kind == SQLWord && selectBreaks[strings.ToUpper(t.Text)]:
+			return src, false
+		}
+	}
+	if from < 0 {
+		return src, false
+	}
+
+	// FROM names one table, optionally qualified and aliased.
+	rest := tokens[from+1:]
+	name, n := qualifiedName(rest, d)
+	if n == 0 {
+		return src, false
+	}
+	src.Table = name
+	rest = rest[n:]
+	alias := ""
+	if len(rest) > 0 && rest[0].Is("AS") {
+		rest = rest[1:]
+		if len(rest) == 0 {
+			return src, false
+		}
+	}
+	if len(rest) > 0 && !(rest[0].Kind == SQLWord && selectClauses[strings.ToUpper(rest[0].Text)]) {
+		var ok bool
+		if alias, ok = rest[0].Identifier(d); !ok {
+			return src, false
+		}
+		rest = rest[1:]
+	}
+	if len(rest) > 0 && !(rest[0].Kind == SQLWord && selectClauses[strings.ToUpper(rest[0].Text)]) {
+		return src, false
+	}
+	depth = 0
+	for _, t := range rest {
+		switch {
+		case t.Text == "(":
+			depth++
+		case t.Text == ")":
+			depth--
+		case depth == 0 && (t.Text == ";" || t.Kind == SQLWord && selectBreaks[strings.ToUpper(t.Text)]):
+			return src, false
+		}
+	}
+
+	// A qualifier must name the table, by its alias if it has one.
+	ownQualifier := func(t SQLToken) bool {
+		q, ok := t.Identifier(d)
+		if alias != "" {
+			return ok && q == alias
+		}
+		return ok && q == src.Table.Name
+	}
+	src.columns = map[string]string{}
+	src.computed = map[string]bool{}
+	src.fold = d != DialectPostgres
+	for _, item := range items {
+		var resultName string
+		if len(item) >= 2 && item[len(item)-2].Is("AS") {
+			resultName, _ = item[len(item)-1].Identifier(d)
+			item = item[:len(item)-2]
+		} else if len(item) >= 2 && item[len(item)-2].Kind != SQLPunct {
+			// A bare alias after a column reference: "name label".
+			resultName, _ = item[len(item)-1].Identifier(d)
+			item = item[:len(item)-1]
+		} else if len(item) >= 2 && item[len(item)-2].Text == ")" {
+			resultName, _ = item[len(item)-1].Identifier(d)
+			item = item[:len(item)-1]
+		}
+
+		switch {
+		case len(item) == 1 && item[0].Text == "*":
+			src.star = true
+			continue
+		case len(item) == 3 && item[1].Text == "." && item[2].Text == "*" && ownQualifier(item[0]):
+			src.star = true
+			continue
+		}
+		var column string
+		var ok bool
+		switch {
+		case len(item) == 1:
+			column, ok = item[0].Identifier(d)
+		case len(item) == 3 && item[1].Text == "." && ownQualifier(item[0]):
+			column, ok = item[2].Identifier(d)
+		}
+		if !ok {
+			if resultName != "" {
+				src.computed[resultName] = true
+			}
+			continue
+		}
+		if resultName == "" {
+			resultName = column
+		}
+		src.columns[resultName] = column
+	}
+	return src, true
+}
+
+// qualifiedName reads a name or schema.name at the start of tokens and
+// returns it with the number of tokens it took, or 0 if there is none.
+func qualifiedName(tokens []SQLToken, d SQLDialect) (QualifiedName, int) {
+	if len(tokens) == 0 {
+		return QualifiedName{}, 0
+	}
+	first, ok := tokens[0].Identifier(d)
+	if !ok {
+		return QualifiedName{}, 0
+	}
+	if len(tokens) >= 3 && tokens[1].Text == "." {
+		if second, ok := tokens[2].Identifier(d); ok {
+			return QualifiedName{Schema: first, Name: second}, 3
+		}
+		return QualifiedName{}, 0
+	}
+	if len(tokens) >= 2 && tokens[1].Text == "(" {
+		return QualifiedName{}, 0 // a table function
+	}
+	return QualifiedName{Name: first}, 1
+}
+
+// SplitSQL splits a script into statements at top-level semicolons,
+// skipping empty statements. Semicolons inside strings, comments, quoted
+// identifiers, dollar-quoted bodies and the BEGIN ... END block of a
+// trigger or routine do not end a statement.
+func SplitSQL(sql string, d SQLDialect) []string {
+	tokens := TokenizeSQL(sql, d)
+
+	var statements []string
+	start := -1      // index of the current statement's first token
+	routine := false // the statement creates a trigger, procedure or function
+	depth := 0       // BEGIN/CASE nesting inside a routine body
+
+	flush := func(end int) {
+		if start >= 0 {
+			statements = append(statements, sql[tokens[start].Start:tokens[end].End])
+		}
+		start, routine, depth = -1, false, 0
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.Kind == SQLPunct && t.Text == ";" && depth == 0 {
+			flush(i - 1)
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		if t.Kind != SQLWord {
+			continue
+		}
+
+		switch {
+		case tokens[start].Is("CREATE") && (t.Is("TRIGGER") || t.Is("PROCEDURE") || t.Is("FUNCTION")):
+			routine = true
+		case !routine:
+		case t.Is("BEGIN") || t.Is("CASE"):
+			depth++
+		case t.Is("END") && depth > 0:
+			if i+1 < len(tokens) {
+				next := tokens[i+1]
+				if next.Is("IF") || next.Is("LOOP") || next.Is("WHILE") || next.Is("REPEAT") {
+					i++
+					continue
+				}
+				if next.Is("CASE") {
+					i++
+				}
+			}
+			depth--
+		}
+	}
+	flush(len(tokens) - 1)
+	return statements
+}
+
+// Placeholder returns the dialect's placeholder for the nth bind value,
+// counting from 1.
+func Placeholder(d SQLDialect, n int) string {
+	if d == DialectPostgres {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+// QuoteLiteral returns v as a SQL literal of the dialect: strings quoted,
+// byte slices as binary literals and values without a literal of their own,
+// such as slices and maps, as quoted JSON.
+func QuoteLiteral(d SQLDialect, v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if val {
+			return "TRUE"
+		}
+		return "FALSE"
+	case int, int32, int64, uint64:
+		return fmt.Sprint(val)
+	case float32:
+		return QuoteLiteral(d, float64(val))
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return quoteString(d, strconv.FormatFloat(val, 'g', -1, 64))
+		}
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	case string:
+		return quoteString(d, val)
+	case json.RawMessage:
+		return quoteString(d, string(val))
+	case []byte:
+		if d == DialectPostgres {
+			return `'\x` + hex.EncodeToString(val) + "'"
+		}
+		return "X'" + hex.EncodeToString(val) + "'"
+	case time.Time:
+		if d == DialectMySQL {
+			return quoteString(d, val.Format("2006-01-02 15:04:05.999999"))
+		}
+		return quoteString(d, val.Format("2006-01-02 15:04:05.999999999Z07:00"))
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return quoteString(d, fmt.Sprint(v))
+	}
+	return quoteString(d, string(b))
+}
+
+// quoteString quotes s as a string literal. MySQL also treats backslashes
+// in strings as escapes.
+func quoteString(d SQLDialect, s string) string {
+	s = strings.ReplaceAll(s, "'", "''")
+	if d == DialectMySQL {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return "'" + s + "'"
+}
+
+// BindNamed replaces the :name parameters in sql with the dialect's
+// positional placeholders and returns the values to bind to them, taken
+// from params. SQLite's @name and $name forms are replaced as well.
+func BindNamed(sql string, d SQLDialect, params map[string]any) (string, []any, error) {
+	var b strings.Builder
+	var args []any
+	positions := map[string]int{}
+	last := 0
+
+	for _, t := range TokenizeSQL(sql, d) {
+		if t.Kind != SQLParam || !strings.ContainsRune(":@$", rune(t.Text[0])) || !isWordStart(t.Text, 1) {
+			continue
+		}
+		name := t.Text[1:]
+		value, ok := params[name]
+		if !ok {
+			return "", nil, fmt.Errorf("missing value for parameter %s", t.Text)
+		}
+
+		b.WriteString(sql[last:t.Start])
+		last = t.End
+		if d == DialectPostgres {
+			pos, seen := positions[name]
+			if !seen {
+				args = append(args, value)
+				pos = len(args)
+				positions[name] = pos
+			}
+			fmt.Fprintf(&b, "$%d", pos)
+		} else {
+			args = append(args, value)
+			b.WriteByte('?')
+		}
+	}
+	b.WriteString(sql[last:])
+	return b.String(), args, nil
+}
@@ -0,0 +1,304 @@
+package helper
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenizeSQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		dialect SQLDialect
+		want    []string
+	}{
+		{
+			name: "comments are skipped",
+			sql:  "-- note\nSELECT /* a /* nested */ b */ 1",
+			want: []string{"SELECT", "1"},
+		},
+		{
+			name: "strings keep semicolons",
+			sql:  `SELECT 'a;b', 'it''s', E'x\'y'`,
+			want: []string{"SELECT", "'a;b'", ",", "'it''s'", ",", `E'x\'y'`},
+		},
+		{
+			name: "dollar quoting",
+			sql:  "SELECT $fn$ don't; $$ $fn$, $1",
+			want: []string{"SELECT", "$fn$ don't; $$ $fn$", ",", "$1"},
+		},
+		{
+			name: "casts are not parameters",
+			sql:  "SELECT :id::int",
+			want: []string{"SELECT", ":id", "::", "int"},
+		},
+		{
+			name:    "mysql backslash escapes and hash comments",
+			sql:     "SELECT 'a\\'b', `c`, \"d\" # trailing",
+			dialect: DialectMySQL,
+			want:    []string{"SELECT", `'a\'b'`, ",", "`c`", ",", `"d"`},
+		},
+		{
+			name:    "sqlite parameters and brackets",
+			sql:     "SELECT [weird name] FROM t WHERE a = ?1 AND b = @b",
+			dialect: DialectSQLite,
+			want:    []string{"SELECT", "[weird name]", "FROM", "t", "WHERE", "a", "=", "?1", "AND", "b", "=", "@b"},
+		},
+		{
+			name: "unicode identifiers",
+			sql:  "SELECT größe FROM 表",
+			want: []string{"SELECT", "größe", "FROM", "表"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, tok := range TokenizeSQL(tc.sql, tc.dialect) {
+				got = append(got, tok.Text)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestClassifySQL(t *testing.T) {
+	tests := []struct {
+		sql         string
+		command     string
+		returnsRows bool
+		dml         bool
+	}{
+		{sql: "select 1", command: "SELECT", returnsRows: true},
+		{sql: "  -- comment\n/* block */ SELECT 1", command: "SELECT", returnsRows: true},
+		{sql: "(SELECT 1) UNION (SELECT 2)", command: "SELECT", returnsRows: true},
+		{sql: "WITH t AS (SELECT 1) SELECT * FROM t", command: "SELECT", returnsRows: true},
+		{sql: "WITH t AS (DELETE FROM a RETURNING id) INSERT INTO b SELECT id FROM t", command: "INSERT", dml: true},
+		{sql: "WITH RECURSIVE t(n) AS (VALUES (1)) SELECT n FROM t", command: "SELECT", returnsRows: true},
+		{sql: "VALUES (1), (2)", command: "VALUES", returnsRows: true},
+		{sql: "TABLE users", command: "TABLE", returnsRows: true},
+		{sql: "SHOW search_path", command: "SHOW", returnsRows: true},
+		{sql: "EXPLAIN ANALYZE DELETE FROM users", command: "EXPLAIN", returnsRows: true},
+		{sql: "INSERT INTO users (name) VALUES ('x') RETURNING id", command: "INSERT", returnsRows: true, dml: true},
+		{sql: "INSERT INTO users (name) VALUES ('returning')", command: "INSERT", dml: true},
+		{sql: "UPDATE users SET name = 'x'", command: "UPDATE", dml: true},
+		{sql: "CREATE TABLE t (id int)", command: "CREATE"},
+		{sql: "", command: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.sql, func(t *testing.T) {
+			info := ClassifySQL(tc.sql, DialectPostgres)
+			assert.Equal(t, tc.command, info.Command)
+			assert.Equal(t, tc.returnsRows, info.ReturnsRows)
+			assert.Equal(t, tc.dml, info.IsDML())
+		})
+	}
+}
+
+func TestParseSelectSource(t *testing.T) {
+	tests := []struct {
+		sql     string
+		dialect SQLDialect
+		ok      bool
+		table   QualifiedName
+		columns map[string]string // result column to table column; "" for none
+	}{
+		{
+			sql: "SELECT * FROM users", ok: true, table: QualifiedName{Name: "users"},
+			columns: map[string]string{"id": "id", "name": "name"},
+		},
+		{
+			sql: `SELECT u.id, u."Name" AS label, upper(email) AS email, 1 AS one FROM app.Users u WHERE id > 1 ORDER BY 1;`, ok: true,
+			table:   QualifiedName{Schema: "app", Name: "users"},
+			columns: map[string]string{"id": "id", "label": "Name", "Name": "", "email": "", "one": ""},
+		},
+		{
+			sql: "SELECT *, lower(name) AS name FROM users", ok: true, table: QualifiedName{Name: "users"},
+			columns: map[string]string{"id": "id", "name": ""},
+		},
+		{
+			sql: "SELECT `Id` FROM `shop`.`Orders` FOR UPDATE", dialect: DialectMySQL, ok: true,
+			table: QualifiedName{Schema: "shop", Name: "Orders"}, columns: map[string]string{"Id": "Id"},
+		},
+		{
+			sql: "SELECT x.* FROM [t] AS x LIMIT 5", dialect: DialectSQLite, ok: true,
+			table: QualifiedName{Name: "t"}, columns: map[string]string{"a": "a"},
+		},
+		{sql: "SELECT other.* FROM t", ok: true, table: QualifiedName{Name: "t"}, columns: map[string]string{"a": ""}},
+		{sql: "SELECT DISTINCT name FROM users"},
+		{sql: "SELECT a.id FROM a JOIN b ON a.id = b.id"},
+		{sql: "SELECT * FROM a, b"},
+		{sql: "SELECT * FROM a LEFT JOIN b USING (id)"},
+		{sql: "SELECT kind, count(*) FROM events GROUP BY kind"},
+		{sql: "SELECT id FROM a UNION SELECT id FROM b"},
+		{sql: "SELECT * FROM (SELECT 1) s"},
+		{sql: "SELECT * FROM generate_series(1, 3)"},
+		{sql: "SELECT * INTO copy FROM users"},
+		{sql: "WITH t AS (SELECT 1) SELECT * FROM t"},
+		{sql: "SELECT 1"},
+		{sql: "UPDATE users SET name = 'x'"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.sql, func(t *testing.T) {
+			src, ok := ParseSelectSource(tc.sql, tc.dialect)
+			assert.Equal(t, tc.ok, ok)
+			if !ok {
+				return
+			}
+			assert.Equal(t, tc.table, src.Table)
+			for name, want := range tc.columns {
+				got, _ := src.Column(name)
+				assert.Equal(t, want, got, name)
+			}
+		})
+	}
+}
+
+func TestSplitSQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		dialect SQLDialect
+		want    []string
+	}{
+		{
+			name: "simple script",
+			sql:  "CREATE TABLE t (id int);\nINSERT INTO t VALUES (1);;\n  SELECT * FROM t",
+			want: []string{"CREATE TABLE t (id int)", "INSERT INTO t VALUES (1)", "SELECT * FROM t"},
+		},
+		{
+			name: "semicolons in strings and comments",
+			sql:  "SELECT 'a;b'; -- c;d\nSELECT \"x;y\" /* ; */ FROM t;",
+			want: []string{"SELECT 'a;b'", `SELECT "x;y" /* ; */ FROM t`},
+		},
+		{
+			name: "dollar-quoted function body",
+			sql:  "CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql; SELECT f()",
+			want: []string{"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql", "SELECT f()"},
+		},
+		{
+			name:    "sqlite trigger body",
+			sql:     "CREATE TRIGGER tr AFTER INSERT ON t BEGIN UPDATE t SET n = CASE WHEN 1 THEN 2 END; DELETE FROM u; END; SELECT 1",
+			dialect: DialectSQLite,
+			want:    []string{"CREATE TRIGGER tr AFTER INSERT ON t BEGIN UPDATE t SET n = CASE WHEN 1 THEN 2 END; DELETE FROM u; END", "SELECT 1"},
+		},
+		{
+			name:    "mysql procedure with control flow",
+			sql:     "CREATE PROCEDURE p() BEGIN IF 1 THEN SELECT 1; END IF; END; CALL p()",
+			dialect: DialectMySQL,
+			want:    []string{"CREATE PROCEDURE p() BEGIN IF 1 THEN SELECT 1; END IF; END", "CALL p()"},
+		},
+		{
+			name: "transaction keywords are not blocks",
+			sql:  "BEGIN; UPDATE t SET a = 1; END;",
+			want: []string{"BEGIN", "UPDATE t SET a = 1", "END"},
+		},
+		{
+			name: "only comments",
+			sql:  "-- nothing here\n;",
+			want: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, SplitSQL(tc.sql, tc.dialect))
+		})
+	}
+}
+
+func TestBindNamed(t *testing.T) {
+	params := map[string]any{"id": 7, "name": "ann"}
+
+	tests := []struct {
+		name     string
+		sql      string
+		dialect  SQLDialect
+		wantSQL  string
+		wantArgs []any
+		wantErr  string
+	}{
+		{
+			name:     "postgres reuses positions",
+			sql:      "SELECT * FROM t WHERE id = :id OR parent = :id AND name = :name::text",
+			wantSQL:  "SELECT * FROM t WHERE id = $1 OR parent = $1 AND name = $2::text",
+			wantArgs: []any{7, "ann"},
+		},
+		{
+			name:     "mysql repeats values",
+			sql:      "SELECT * FROM t WHERE id = :id OR parent = :id",
+			dialect:  DialectMySQL,
+			wantSQL:  "SELECT * FROM t WHERE id = ? OR parent = ?",
+			wantArgs: []any{7, 7},
+		},
+		{
+			name:     "strings and comments are left alone",
+			sql:      "SELECT ':id' -- :name\n, :name",
+			wantSQL:  "SELECT ':id' -- :name\n, $1",
+			wantArgs: []any{"ann"},
+		},
+		{
+			name:     "sqlite prefixes",
+			sql:      "SELECT @id, $name",
+			dialect:  DialectSQLite,
+			wantSQL:  "SELECT ?, ?",
+			wantArgs: []any{7, "ann"},
+		},
+		{
+			name:     "array slices are not parameters",
+			sql:      "SELECT arr[1:2], arr[lo:hi], arr[arr2[1]:hi], arr[(n):id], arr[:id] FROM t WHERE id = :id",
+			wantSQL:  "SELECT arr[1:2], arr[lo:hi], arr[arr2[1]:hi], arr[(n):id], arr[$1] FROM t WHERE id = $1",
+			wantArgs: []any{7},
+		},
+		{
+			name:    "missing parameter",
+			sql:     "SELECT :missing",
+			wantErr: "missing value for parameter :missing",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sql, args, err := BindNamed(tc.sql, tc.dialect, params)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantSQL, sql)
+			assert.Equal(t, tc.wantArgs, args)
+		})
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	when := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		dialect SQLDialect
+		value   any
+		want    string
+	}{
+		{DialectPostgres, nil, "NULL"},
+		{DialectPostgres, true, "TRUE"},
+		{DialectSQLite, int64(-42), "-42"},
+		{DialectPostgres, 1.5, "1.5"},
+		{DialectPostgres, math.NaN(), "'NaN'"},
+		{DialectPostgres, `it's C:\temp`, `'it''s C:\temp'`},
+		{DialectMySQL, `it's C:\temp`, `'it''s C:\\temp'`},
+		{DialectPostgres, []byte{0x00, 0xff}, `'\x00ff'`},
+		{DialectMySQL, []byte{0x00, 0xff}, "X'00ff'"},
+		{DialectPostgres, json.RawMessage(`{"a":"b'c"}`), `'{"a":"b''c"}'`},
+		{DialectPostgres, when, "'2024-05-01 12:30:00Z'"},
+		{DialectMySQL, when, "'2024-05-01 12:30:00'"},
+		{DialectSQLite, []any{int64(1), "x"}, `'[1,"x"]'`},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, QuoteLiteral(tt.dialect, tt.value), "%v", tt.value)
+	}
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"vind/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// connectionHeader carries the ID returned by /connect. The connection_id
+// query parameter is accepted as a fallback for plain links and EventSource.
+const connectionHeader = "X-Connection-ID"
+
+// connection is an open database handle registered by ConnectHandler.
+type connection struct {
+	ID        string           `json:"id"`
+	Name      string           `json:"name,omitempty"`
+	Driver    string           `json:"driver"`
+	CreatedAt time.Time        `json:"created_at"`
+	Client    service.DBClient `json:"-"`
+}
+
+type connectionRegistry struct {
+	mu    sync.RWMutex
+	conns map[string]*connection
+}
+
+var connections = &connectionRegistry{conns: map[string]*connection{}}
+
+// readRandom fills IDs with random bytes; tests replace it to fail.
+var readRandom = rand.Read
+
+// newConnectionID returns a random ID for a connection, transaction or
+// query.
+func newConnectionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := readRandom(b); err != nil {
+		return "", fmt.Errorf("generating ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (r *connectionRegistry) add(driver, name string, client service.DBClient) (*connection, error) {
+	id, err := newConnectionID()
+	if err != nil {
+		return nil, err
+	}
+	conn := &connection{
+		ID:        id,
+		Name:      name,
+		Driver:    driver,
+		CreatedAt: time.Now().UTC(),
+		Client:    client,
+	}
+
+	r.mu.Lock()
+	r.conns[conn.ID] = conn
+	r.mu.Unlock()
+	return conn, nil
+}
+
+func (r *connectionRegistry) get(id string) (*connection, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	conn, ok := r.conns[id]
+	return conn, ok
+}
+
+func (r *connectionRegistry) remove(id string) (*connection, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conn, ok := r.conns[id]
+	delete(r.conns, id)
+	return conn, ok
+}
+
+func (r *connectionRegistry) list() []*connection {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]*connection, 0, len(r.conns))
+	for _, conn := range r.conns {
+		list = append(list, conn)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// connectionID returns the connection ID the request refers to, if any.
+func connectionID(c *gin.Context) string {
+	if id := c.GetHeader(connectionHeader); id != "" {
+		return id
+	}
+	return c.Query("connection_id")
+}
+
+// currentDB resolves the database client for the request's transaction, if
+// it is bound to one, or else for its connection ID. It returns nil when the
+// request names no connection or an unknown one.
+func currentDB(c *gin.Context) service.DBClient {
+	if s := currentTransaction(c); s != nil {
+		return s.Tx
+	}
+	conn, ok := connections.get(connectionID(c))
+	if !ok {
+		return nil
+	}
+	return conn.Client
+}
+
+func ListConnectionsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"connections": connections.list()})
+}
+
+func CloseConnectionHandler(c *gin.Context) {
+	id := c.Param("id")
+	conn, ok := connections.remove(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Connection not found"})
+		return
+	}
+	transactions.rollbackConnection(id)
+
+	if err := conn.Client.Disconnect(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disconnect: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Connection closed", "connection_id": id})
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"vind/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectHandlerRegistersConnection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newPostgresClient = func() service.DBClient {
+		return &mockDBClient{connectFunc: func(dsn string) error { return nil }}
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/connect", bytes.NewBufferString(`{"driver": "postgres", "dsn": "good", "name": "reporting"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	ConnectHandler(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		ConnectionID string `json:"connection_id"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.ConnectionID)
+	t.Cleanup(func() { connections.remove(resp.ConnectionID) })
+
+	conn, ok := connections.get(resp.ConnectionID)
+	assert.True(t, ok)
+	assert.Equal(t, "postgres", conn.Driver)
+	assert.Equal(t, "reporting", conn.Name)
+}
+
+func TestConnectHandlerIDFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	disconnected := false
+	newPostgresClient = func() service.DBClient {
+		return &mockDBClient{
+			connectFunc:    func(dsn string) error { return nil },
+			disconnectFunc: func() error { disconnected = true; return nil },
+		}
+	}
+	readRandom = func(b []byte) (int, error) { return 0, errors.New("no entropy") }
+	t.Cleanup(func() { readRandom = rand.Read })
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/connect", bytes.NewBufferString(`{"driver": "postgres", "dsn": "good"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	ConnectHandler(c)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"error":"Failed to register connection: generating ID: no entropy"}`, w.Body.String())
+	assert.True(t, disconnected)
+	assert.Empty(t, connections.list())
+}
+
+func TestConnectionsAreIsolated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	first := &listTablesMock{listTablesFunc: func(schema string) ([]string, error) { return []string{"first"}, nil }}
+	second := &listTablesMock{listTablesFunc: func(schema string) ([]string, error) { return []string{"second"}, nil }}
+
+	for _, tc := range []struct {
+		db       service.DBClient
+		expected string
+	}{
+		{db: first, expected: `{"tables":["first"]}`},
+		{db: second, expected: `{"tables":["second"]}`},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("GET", "/tables", nil)
+		useDB(t, c.Request, tc.db)
+
+		ListTablesHandler(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, tc.expected, w.Body.String())
+	}
+}
+
+func TestConnectionIDQueryParam(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := &listTablesMock{listTablesFunc: func(schema string) ([]string, error) { return []string{"foo"}, nil }}
+	conn, err := connections.add("mock", "", db)
+	require.NoError(t, err)
+	t.Cleanup(func() { connections.remove(conn.ID) })
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/tables?connection_id="+conn.ID, nil)
+
+	ListTablesHandler(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"tables":["foo"]}`, w.Body.String())
+}
+
+func TestUnknownConnectionID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/tables", nil)
+	c.Request.Header.Set(connectionHeader, "does-not-exist")
+
+	ListTablesHandler(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `{"error":"No active DB connection"}`)
+}
+
+func TestListConnectionsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	conn, err := connections.add("postgres", "reporting", &mockDBClient{})
+	require.NoError(t, err)
+	t.Cleanup(func() { connections.remove(conn.ID) })
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/connections", nil)
+
+	ListConnectionsHandler(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"`+conn.ID+`","name":"reporting","driver":"postgres"`)
+}
+
+func TestCloseConnectionHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		register      bool
+		disconnectErr error
+		expectedCode  int
+		expectedBody  string
+	}{
+		{
+			name:         "unknown connection",
+			register:     false,
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"Connection not found"}`,
+		},
+		{
+			name:          "disconnect error",
+			register:      true,
+			disconnectErr: errors.New("fail"),
+			expectedCode:  http.StatusInternalServerError,
+			expectedBody:  `{"error":"Failed to disconnect: fail"}`,
+		},
+		{
+			name:         "success",
+			register:     true,
+			expectedCode: http.StatusOK,
+			expectedBody: `"message":"Connection closed"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			disconnected := false
+			id := "missing"
+			if tc.register {
+				conn, err := connections.add("mock", "", &mockDBClient{disconnectFunc: func() error {
+					disconnected = true
+					return tc.disconnectErr
+				}})
+				require.NoError(t, err)
+				id = conn.ID
+			}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest("DELETE", "/connections/"+id, nil)
+			c.Params = gin.Params{{Key: "id", Value: id}}
+
+			CloseConnectionHandler(c)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tc.expectedBody)
+			assert.Equal(t, tc.register, disconnected)
+
+			_, stillOpen := connections.get(id)
+			assert.False(t, stillOpen)
+		})
+	}
+}
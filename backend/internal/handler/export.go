@@ -0,0 +1,362 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"vind/backend/helper"
+	"vind/backend/internal/model"
+	"vind/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	exportCSV    = "csv"
+	exportJSON   = "json"
+	exportNDJSON = "ndjson"
+	exportSQL    = "sql"
+
+	abortResponseKey = "abort_response"
+)
+
+var exportContentTypes = map[string]string{
+	exportCSV:    "text/csv; charset=utf-8",
+	exportJSON:   "application/json",
+	exportNDJSON: ndjsonContentType,
+	exportSQL:    "application/sql; charset=utf-8",
+}
+
+// exportWriter is a service.RowWriter that sends a whole result set as a
+// file download: CSV with a header row, a JSON array of objects, one JSON
+// object per line, or one INSERT into table per row. As with streamWriter,
+// nothing is written until the columns arrive, and the first row lifts the
+// statement timeout; exports are not capped by the stream row limit.
+type exportWriter struct {
+	c        *gin.Context
+	format   string
+	filename string
+	table    helper.QualifiedName // target of SQL INSERTs
+	d        helper.SQLDialect
+
+	fields  []model.ColumnMeta
+	csv     *csv.Writer
+	keys    [][]byte // JSON-encoded column names
+	insert  string   // INSERT INTO ... VALUES
+	rows    int
+	started bool
+}
+
+// newExportWriter reads the ?format= of an export, csv by default, and
+// writes a 400 and returns nil if it is unknown. name is the file's name
+// without extension, unless ?filename= overrides it.
+func newExportWriter(c *gin.Context, name string, table helper.QualifiedName, d helper.SQLDialect) *exportWriter {
+	format := strings.ToLower(c.DefaultQuery("format", exportCSV))
+	if _, ok := exportContentTypes[format]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format; use csv, json, ndjson or sql"})
+		return nil
+	}
+	filename := c.Query("filename")
+	if filename == "" {
+		filename = name + "." + format
+	}
+	return &exportWriter{c: c, format: format, filename: filename, table: table, d: d}
+}
+
+func (e *exportWriter) WriteColumns(columns []model.ColumnMeta) error {
+	w := e.c.Writer
+	w.Header().Set("Content-Type", exportContentTypes[e.format])
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": e.filename}))
+	w.WriteHeader(http.StatusOK)
+	e.started = true
+	e.fields = columns
+
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+	}
+	var err error
+	switch e.format {
+	case exportCSV:
+		e.csv = csv.NewWriter(w)
+		err = e.csv.Write(names)
+	case exportJSON, exportNDJSON:
+		e.keys = make([][]byte, len(names))
+		for i, name := range names {
+			e.keys[i], _ = json.Marshal(name)
+		}
+		if e.format == exportJSON {
+			_, err = w.WriteString("[")
+		}
+	case exportSQL:
+		quoted := make([]string, len(names))
+		for i, name := range names {
+			quoted[i] = helper.QuoteIdentifier(e.d, name)
+		}
+		e.insert = "INSERT INTO " + e.table.Quote(e.d) + " (" + strings.Join(quoted, ", ") + ") VALUES ("
+	}
+	if err != nil {
+		return err
+	}
+	// Send the headers, and with them the query ID, before the first row.
+	e.flush()
+	return nil
+}
+
+func (e *exportWriter) WriteRow(values []any) error {
+	if e.rows == 0 {
+		liftStatementTimeout(e.c)
+	}
+	var err error
+	switch e.format {
+	case exportCSV:
+		record := make([]string, len(values))
+		for i, v := range values {
+			record[i] = exportText(v)
+		}
+		err = e.csv.Write(record)
+	case exportJSON, exportNDJSON:
+		var buf bytes.Buffer
+		if e.format == exportJSON && e.rows > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('{')
+		for i, v := range values {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(e.keys[i])
+			buf.WriteByte(':')
+			b, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			buf.Write(b)
+		}
+		buf.WriteByte('}')
+		if e.format == exportNDJSON {
+			buf.WriteByte('\n')
+		}
+		_, err = e.c.Writer.Write(buf.Bytes())
+	case exportSQL:
+		literals := make([]string, len(values))
+		for i, v := range values {
+			// Binary values arrive hex-encoded and Postgres arrays as JSON
+			// arrays; export them as bytes and array literals again.
+			if s, ok := v.(string); ok && e.fields[i].Binary {
+				if b, err := hex.DecodeString(s); err == nil {
+					v = b
+				}
+			}
+			if e.d == helper.DialectPostgres {
+				if text, ok := service.PostgresArrayText(e.fields[i].Type, v); ok {
+					v = text
+				}
+			}
+			literals[i] = helper.QuoteLiteral(e.d, v)
+		}
+		_, err = e.c.Writer.WriteString(e.insert + strings.Join(literals, ", ") + ");\n")
+	}
+	if err != nil {
+		return err
+	}
+	e.rows++
+	if e.rows%streamFlushEvery == 0 {
+		e.flush()
+	}
+	return nil
+}
+
+func (e *exportWriter) flush() {
+	if e.csv != nil {
+		e.csv.Flush()
+	}
+	e.c.Writer.Flush()
+}
+
+// end completes the file after the scan returned err. A failed export ends
+// with an error line or comment where the format has one, and then has its
+// connection dropped, see AbortFailedResponses, so that the download fails
+// instead of leaving a file that looks complete.
+func (e *exportWriter) end(err error) {
+	w := e.c.Writer
+	if err != nil {
+		_, msg := dbErrorStatus(e.c, err)
+		log.Println("Export failed:", msg)
+		switch e.format {
+		case exportNDJSON:
+			b, _ := json.Marshal(gin.H{"error": msg})
+			w.Write(append(b, '\n'))
+		case exportSQL:
+			w.WriteString("-- export failed: " + strings.ReplaceAll(msg, "\n", " ") + "\n")
+		}
+		e.flush()
+		e.c.Set(abortResponseKey, true)
+		return
+	}
+	if e.format == exportJSON {
+		w.WriteString("]\n")
+	}
+	e.flush()
+}
+
+// AbortFailedResponses drops the connection of a response that failed after
+// its status was sent, such as an export cut short, rather than ending it
+// normally: clients then see an incomplete transfer instead of a complete
+// but truncated body. It must come before gin's Recovery, which would
+// otherwise swallow the abort.
+func AbortFailedResponses() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if c.GetBool(abortResponseKey) {
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
+
+// exportText formats a value for CSV: strings and JSON documents as they
+// are, NULL as an empty field and everything else as JSON.
+func exportText(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case json.RawMessage:
+		return string(val)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// ExportQueryHandler runs a single query and sends its whole result as a
+// file in the ?format= given, see exportWriter. SQL exports insert into
+// ?table=, "export" by default.
+func ExportQueryHandler(c *gin.Context) {
+	var req model.QueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.SQL) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	db := currentDB(c)
+	if db == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No active database connection"})
+		return
+	}
+	if len(helper.SplitSQL(req.SQL, db.Dialect())) > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exporting multi-statement scripts is not supported"})
+		return
+	}
+	// Statements are checked before they run, since one that writes would
+	// otherwise take effect before the export is refused.
+	switch info := helper.ClassifySQL(req.SQL, db.Dialect()); {
+	case info.IsDML():
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exporting statements that modify data is not supported"})
+		return
+	case !info.ReturnsRows:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query has no result set to export"})
+		return
+	}
+	table := helper.QualifiedName{Schema: c.Query("schema"), Name: c.DefaultQuery("table", "export")}
+	export := newExportWriter(c, "export", table, db.Dialect())
+	if export == nil {
+		return
+	}
+
+	query := req.SQL
+	args, named, err := decodeParams(req.Params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid params: " + err.Error()})
+		return
+	}
+	if named != nil {
+		if query, args, err = helper.BindNamed(query, db.Dialect(), named); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid params: " + err.Error()})
+			return
+		}
+	}
+
+	log.Println("Exporting query:", req.SQL)
+	ctx, running, ok := startQuery(c, req.SQL, db)
+	if !ok {
+		return
+	}
+	_, err = db.StreamQuery(ctx, query, export, args...)
+	finishExport(c, export, running, err)
+}
+
+// ExportTableHandler sends every row of a table matching the filters of
+// GET /records, in their order_by, as a file in the ?format= given, see
+// exportWriter.
+func ExportTableHandler(c *gin.Context) {
+	db := currentDB(c)
+	if db == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not connected to any database"})
+		return
+	}
+	table := c.Query("table")
+	if table == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing table name"})
+		return
+	}
+	var columns []string
+	if val := c.Query("columns"); val != "" {
+		columns = strings.Split(val, ",")
+	}
+	req := model.TableDataRequest{
+		Schema:  c.Query("schema"),
+		Table:   table,
+		OrderBy: c.Query("order_by"),
+		Columns: columns,
+		Count:   "none",
+		Filters: c.QueryArray("filter"),
+		All:     true,
+	}
+
+	name := helper.QualifiedName{Schema: req.Schema, Name: table}
+	export := newExportWriter(c, table, name, db.Dialect())
+	if export == nil {
+		return
+	}
+
+	log.Println("Exporting table:", name)
+	ctx, running, ok := startQuery(c, "export of table "+name.String(), db)
+	if !ok {
+		return
+	}
+	_, err := db.StreamTableData(ctx, req, export)
+	finishExport(c, export, running, err)
+}
+
+// finishExport unregisters the running export and completes its response
+// after the scan returned err.
+func finishExport(c *gin.Context, export *exportWriter, running *runningQuery, err error) {
+	if cancelled := runningQueries.finish(running); cancelled {
+		err = errors.New("Query was cancelled")
+		if !export.started {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "query_id": running.ID})
+			return
+		}
+	}
+	switch {
+	case export.started:
+		export.end(err)
+	case err != nil:
+		dbError(c, err)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query has no result set to export"})
+	}
+}
@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"vind/backend/internal/model"
+	"vind/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportQueryHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	twoRows := func(query string) (*model.QueryResponse, error) {
+		return &model.QueryResponse{Columns: []string{"id", "name"}, Rows: [][]any{{1, "a,b"}, {2, nil}}}, nil
+	}
+
+	tests := []struct {
+		name                string
+		query               string
+		body                string
+		mockFunc            func(query string) (*model.QueryResponse, error)
+		expectedCode        int
+		expectedContentType string
+		expectedFilename    string
+		expectedBody        string
+	}{
+		{
+			name:                "csv by default",
+			mockFunc:            twoRows,
+			expectedCode:        http.StatusOK,
+			expectedContentType: "text/csv",
+			expectedFilename:    `attachment; filename=export.csv`,
+			expectedBody:        "id,name\n1,\"a,b\"\n2,\n",
+		},
+		{
+			name:                "json",
+			query:               "?format=json&filename=users.json",
+			mockFunc:            twoRows,
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/json",
+			expectedFilename:    `attachment; filename=users.json`,
+			expectedBody:        `[{"id":1,"name":"a,b"},{"id":2,"name":null}]` + "\n",
+		},
+		{
+			name:                "ndjson",
+			query:               "?format=ndjson",
+			mockFunc:            twoRows,
+			expectedCode:        http.StatusOK,
+			expectedContentType: ndjsonContentType,
+			expectedFilename:    `attachment; filename=export.ndjson`,
+			expectedBody:        "{\"id\":1,\"name\":\"a,b\"}\n{\"id\":2,\"name\":null}\n",
+		},
+		{
+			name:                "sql",
+			query:               "?format=sql&table=users&schema=public",
+			mockFunc:            twoRows,
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/sql",
+			expectedFilename:    `attachment; filename=export.sql`,
+			expectedBody: `INSERT INTO "public"."users" ("id", "name") VALUES (1, 'a,b');` + "\n" +
+				`INSERT INTO "public"."users" ("id", "name") VALUES (2, NULL);` + "\n",
+		},
+		{
+			name:  "sql arrays and json",
+			query: "?format=sql&table=docs",
+			mockFunc: func(query string) (*model.QueryResponse, error) {
+				return &model.QueryResponse{
+					Columns: []string{"tags", "doc"},
+					Fields:  []model.ColumnMeta{{Name: "tags", Type: "_TEXT"}, {Name: "doc", Type: "JSONB"}},
+					Rows:    [][]any{{[]any{"a", "it's", nil}, json.RawMessage(`{"k":["a","b"]}`)}},
+				}, nil
+			},
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/sql",
+			expectedFilename:    `attachment; filename=export.sql`,
+			expectedBody:        `INSERT INTO "docs" ("tags", "doc") VALUES ('{"a","it''s",NULL}', '{"k":["a","b"]}');` + "\n",
+		},
+		{
+			name: "empty result keeps the header",
+			mockFunc: func(query string) (*model.QueryResponse, error) {
+				return &model.QueryResponse{Columns: []string{"id"}}, nil
+			},
+			expectedCode:        http.StatusOK,
+			expectedContentType: "text/csv",
+			expectedFilename:    `attachment; filename=export.csv`,
+			expectedBody:        "id\n",
+		},
+		{
+			name:                "no result set",
+			mockFunc:            func(query string) (*model.QueryResponse, error) { return &model.QueryResponse{Command: "CREATE"}, nil },
+			expectedCode:        http.StatusBadRequest,
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"Query has no result set to export"}`,
+		},
+		{
+			name: "query error",
+			mockFunc: func(query string) (*model.QueryResponse, error) {
+				return nil, errors.New("syntax error")
+			},
+			expectedCode:        http.StatusInternalServerError,
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"syntax error"}`,
+		},
+		{
+			name:                "invalid format",
+			query:               "?format=xml",
+			mockFunc:            twoRows,
+			expectedCode:        http.StatusBadRequest,
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"Invalid format; use csv, json, ndjson or sql"}`,
+		},
+		{
+			name:                "script",
+			body:                `{"sql": "SELECT 1; SELECT 2"}`,
+			mockFunc:            twoRows,
+			expectedCode:        http.StatusBadRequest,
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"Exporting multi-statement scripts is not supported"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := gin.New()
+			r.POST("/query/export", ExportQueryHandler)
+
+			body := tc.body
+			if body == "" {
+				body = `{"sql": "SELECT * FROM users"}`
+			}
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/query/export"+tc.query, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			useDB(t, req, &mockDBClient{executeQueryFunc: tc.mockFunc})
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Contains(t, w.Header().Get("Content-Type"), tc.expectedContentType)
+			assert.Equal(t, tc.expectedFilename, w.Header().Get("Content-Disposition"))
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestExportQueryHandlerRefusesWrites(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/query/export", ExportQueryHandler)
+
+	db := service.NewSQLiteClient()
+	if !assert.NoError(t, db.Connect(":memory:")) {
+		return
+	}
+	t.Cleanup(func() { db.Disconnect() })
+	_, err := db.ExecuteQuery(context.Background(), `CREATE TABLE users (id INTEGER PRIMARY KEY)`)
+	assert.NoError(t, err)
+	_, err = db.ExecuteQuery(context.Background(), `INSERT INTO users VALUES (1), (2)`)
+	assert.NoError(t, err)
+
+	for body, want := range map[string]string{
+		`{"sql": "CREATE TABLE gone (id INTEGER)"}`:         `{"error":"Query has no result set to export"}`,
+		`{"sql": "DELETE FROM users"}`:                      `{"error":"Exporting statements that modify data is not supported"}`,
+		`{"sql": "DELETE FROM users RETURNING id"}`:         `{"error":"Exporting statements that modify data is not supported"}`,
+		`{"sql": "WITH d AS (SELECT 1) DELETE FROM users"}`: `{"error":"Exporting statements that modify data is not supported"}`,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/query/export", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		useDB(t, req, db)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.JSONEq(t, want, w.Body.String(), body)
+	}
+
+	resp, err := db.ExecuteQuery(context.Background(), `SELECT COUNT(*) FROM users`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, [][]any{{int64(2)}}, resp.Rows)
+	}
+	_, err = db.ExecuteQuery(context.Background(), `SELECT * FROM gone`)
+	assert.Error(t, err)
+}
+
+func TestExportTableHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.GET("/records/export", ExportTableHandler)
+
+	var got model.TableDataRequest
+	db := &mockDBClient{
+		getTableDataFunc: func(req model.TableDataRequest) (*model.TableDataResponse, error) {
+			got = req
+			return &model.TableDataResponse{Columns: []string{"id", "tags"}, Rows: [][]any{{1, []any{"x", "y"}}}}, nil
+		},
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/records/export?table=users&filter=id:gt:0&order_by=id:desc&limit=10&offset=5", nil)
+	useDB(t, req, db)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "attachment; filename=users.csv", w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "id,tags\n1,\"[\"\"x\"\",\"\"y\"\"]\"\n", w.Body.String())
+	assert.Equal(t, []string{"id:gt:0"}, got.Filters)
+	assert.Equal(t, "id:desc", got.OrderBy)
+	assert.True(t, got.All)
+	assert.Equal(t, "none", got.Count)
+	assert.NotEmpty(t, w.Header().Get(queryIDHeader))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/records/export", nil)
+	useDB(t, req, db)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// failingExportMock sends one row of a table, then fails; until released,
+// it waits after that row.
+type failingExportMock struct {
+	mockDBClient
+	release chan struct{}
+}
+
+func (m *failingExportMock) StreamTableData(ctx context.Context, req model.TableDataRequest, w service.RowWriter) (*model.TableDataResponse, error) {
+	if err := w.WriteColumns([]model.ColumnMeta{{Name: "id"}}); err != nil {
+		return nil, err
+	}
+	if err := w.WriteRow([]any{1}); err != nil {
+		return nil, err
+	}
+	select {
+	case <-m.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return nil, errors.New("connection lost")
+}
+
+func TestExportTableHandlerFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(AbortFailedResponses(), StatementTimeout(20*time.Millisecond, time.Minute))
+	r.GET("/records/export", ExportTableHandler)
+
+	db := &failingExportMock{release: make(chan struct{})}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		useDB(t, req, db)
+		r.ServeHTTP(w, req)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/records/export?table=users&format=ndjson")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The export is listed while it runs, and outlives the statement timeout.
+	time.Sleep(40 * time.Millisecond)
+	running := runningQueries.list()
+	if assert.Len(t, running, 1) {
+		assert.Equal(t, resp.Header.Get(queryIDHeader), running[0].ID)
+		assert.Equal(t, "export of table users", running[0].SQL)
+	}
+	close(db.release)
+
+	body, err := io.ReadAll(resp.Body)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, "{\"id\":1}\n{\"error\":\"connection lost\"}\n", string(body))
+}
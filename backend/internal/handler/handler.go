@@ -1,21 +1,27 @@
 package handler
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"vind/backend/helper"
 	"vind/backend/internal/model"
 	"vind/backend/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
-var activeDB service.DBClient
-
 // newPostgresClient is a function that returns a service.DBClient.
 // By default, it returns service.NewPostgresClient(), but can be overridden in tests.
 var newPostgresClient func() service.DBClient = func() service.DBClient { return service.NewPostgresClient() }
 
+// newMySQLClient is overridable in tests in the same way as newPostgresClient.
+var newMySQLClient func() service.DBClient = func() service.DBClient { return service.NewMySQLClient() }
+
+// newSQLiteClient is overridable in tests in the same way as newPostgresClient.
+var newSQLiteClient func() service.DBClient = func() service.DBClient { return service.NewSQLiteClient() }
+
 func Ping(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "pong",
@@ -29,33 +35,60 @@ func ConnectHandler(c *gin.Context) {
 		return
 	}
 
-	switch req.Driver {
+	openConnection(c, req.Driver, req.Name, req.DSN)
+}
+
+// newClient returns an unconnected client for driver, or nil if the driver is unsupported.
+func newClient(driver string) service.DBClient {
+	switch driver {
 	case "postgres":
-		activeDB = newPostgresClient()
-	default:
+		return newPostgresClient()
+	case "mysql", "mariadb":
+		return newMySQLClient()
+	case "sqlite", "sqlite3":
+		return newSQLiteClient()
+	}
+	return nil
+}
+
+// openConnection connects to dsn, registers the connection and writes the
+// response shared by /connect and /profiles/:id/connect.
+func openConnection(c *gin.Context, driver, name, dsn string) {
+	db := newClient(driver)
+	if db == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported driver"})
 		return
 	}
 
-	if err := activeDB.Connect(req.DSN); err != nil {
+	if err := db.Connect(dsn); err != nil {
+		db.Disconnect()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect: " + err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Connected successfully"})
+	conn, err := connections.add(driver, name, db)
+	if err != nil {
+		db.Disconnect()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register connection: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Connected successfully", "connection_id": conn.ID})
 }
 
 func ListTablesHandler(c *gin.Context) {
-	if activeDB == nil {
+	db := currentDB(c)
+	if db == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No active DB connection"})
 		return
 	}
 
-	schema := c.DefaultQuery("schema", "public")
+	// An empty schema lets the driver pick its default ("public" on Postgres,
+	// the DSN's database on MySQL, "main" on SQLite).
+	schema := c.Query("schema")
 
-	tables, err := activeDB.ListTables(schema)
+	tables, err := db.ListTables(c.Request.Context(), schema)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		dbError(c, err)
 		return
 	}
 
@@ -67,12 +100,13 @@ func ListTablesHandler(c *gin.Context) {
 }
 
 func ListColumnsHandler(c *gin.Context) {
-	if activeDB == nil {
+	db := currentDB(c)
+	if db == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Not connected to any database"})
 		return
 	}
 
-	schema := c.DefaultQuery("schema", "public")
+	schema := c.Query("schema")
 	table := c.Query("table")
 	if table == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing 'table' query parameter"})
@@ -80,9 +114,9 @@ func ListColumnsHandler(c *gin.Context) {
 	}
 
 	log.Printf("Listing columns for %s.%s\n", schema, table)
-	columns, err := activeDB.ListColumns(schema, table)
+	columns, err := db.ListColumns(c.Request.Context(), helper.QualifiedName{Schema: schema, Name: table})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch columns: " + err.Error()})
+		dbError(c, fmt.Errorf("Failed to fetch columns: %w", err))
 		return
 	}
 
@@ -90,7 +124,8 @@ func ListColumnsHandler(c *gin.Context) {
 }
 
 func CreateTableHandler(c *gin.Context) {
-	if activeDB == nil {
+	db := currentDB(c)
+	if db == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No active DB connection"})
 		return
 	}
@@ -100,8 +135,9 @@ func CreateTableHandler(c *gin.Context) {
 		return
 	}
 
-	if err := activeDB.CreateTable(req.TableName, req.Columns); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	table := helper.QualifiedName{Schema: req.Schema, Name: req.TableName}
+	if err := db.CreateTable(c.Request.Context(), table, req.Columns); err != nil {
+		dbError(c, err)
 		return
 	}
 
@@ -119,13 +155,15 @@ func AlterTableHandler(c *gin.Context) {
 		return
 	}
 
-	if activeDB == nil {
+	db := currentDB(c)
+	if db == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No active DB connection"})
 		return
 	}
 
-	if err := activeDB.AlterTable(tableName, req.Operations); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	table := helper.QualifiedName{Schema: c.Query("schema"), Name: tableName}
+	if err := db.AlterTable(c.Request.Context(), table, req.Operations); err != nil {
+		dbError(c, err)
 		return
 	}
 
@@ -136,7 +174,8 @@ func AlterTableHandler(c *gin.Context) {
 }
 
 func DropTableHandler(c *gin.Context) {
-	if activeDB == nil {
+	db := currentDB(c)
+	if db == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No active DB connection"})
 		return
 	}
@@ -151,8 +190,9 @@ func DropTableHandler(c *gin.Context) {
 		}
 	}
 
-	if err := activeDB.DropTable(tableName, cascade); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	table := helper.QualifiedName{Schema: c.Query("schema"), Name: tableName}
+	if err := db.DropTable(c.Request.Context(), table, cascade); err != nil {
+		dbError(c, err)
 		return
 	}
 
@@ -169,13 +209,14 @@ func AddConstraintHandler(c *gin.Context) {
 		return
 	}
 
-	if activeDB == nil {
+	db := currentDB(c)
+	if db == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No active DB connection"})
 		return
 	}
 
-	if err := activeDB.AddConstraint(params); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := db.AddConstraint(c.Request.Context(), params); err != nil {
+		dbError(c, err)
 		return
 	}
 
@@ -193,13 +234,15 @@ func DropConstraintHandler(c *gin.Context) {
 		}
 	}
 
-	if activeDB == nil {
+	db := currentDB(c)
+	if db == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No active DB connection"})
 		return
 	}
 
-	if err := activeDB.DropConstraint(tableName, constraintName, cascade); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	table := helper.QualifiedName{Schema: c.Query("schema"), Name: tableName}
+	if err := db.DropConstraint(c.Request.Context(), table, constraintName, cascade); err != nil {
+		dbError(c, err)
 		return
 	}
 
@@ -207,15 +250,17 @@ func DropConstraintHandler(c *gin.Context) {
 }
 
 func ListConstraintsHandler(c *gin.Context) {
-	if activeDB == nil {
+	db := currentDB(c)
+	if db == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No active DB connection"})
 		return
 	}
 
 	tableName := c.Param("table_name")
-	constraints, err := activeDB.ListConstraints(tableName)
+	table := helper.QualifiedName{Schema: c.Query("schema"), Name: tableName}
+	constraints, err := db.ListConstraints(c.Request.Context(), table)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		dbError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"constraints": constraints})
@@ -2,120 +2,255 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"vind/backend/helper"
 	"vind/backend/internal/model"
 	"vind/backend/internal/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type mockDBClient struct {
 	connectFunc         func(dsn string) error
+	disconnectFunc      func() error
+	beginTxFunc         func() (service.Tx, error)
 	listColumnsFunc     func(schema, table string) ([]model.Column, error)
-	executeQueryFunc    func(query string) ([]string, [][]any, error)
-	getTableDataFunc    func(model.TableDataRequest) ([]string, [][]any, error)
-	insertRecordFunc    func(schema, table string, data map[string]any) error
-	updateRecordFunc    func(schema, table string, data, where map[string]any) (int64, error)
-	deleteRecordFunc    func(schema, table string, conditions map[string]any) (int64, error)
+	executeQueryFunc    func(query string) (*model.QueryResponse, error)
+	executeScriptFunc   func(statements []string, opts model.ScriptOptions) (*model.ScriptResponse, error)
+	cancelQueryFunc     func(backendID int64) error
+	getTableDataFunc    func(model.TableDataRequest) (*model.TableDataResponse, error)
+	getCellValueFunc    func(schema, table, column string, key map[string]any) (*model.CellValue, error)
+	insertRecordFunc    func(schema, table string, data map[string]any) (*model.QueryResponse, error)
+	upsertRecordFunc    func(table helper.QualifiedName, data map[string]any, upsert model.Upsert) (*model.QueryResponse, error)
+	updateRecordFunc    func(schema, table string, data, where map[string]any) (*model.QueryResponse, error)
+	deleteRecordFunc    func(schema, table string, conditions map[string]any) (*model.QueryResponse, error)
+	updateRowFunc       func(edit model.RowEdit) (*model.RowEditResult, error)
+	deleteRowFunc       func(edit model.RowEdit) error
+	executeBatchFunc    func(req model.BatchRequest) (*model.BatchResponse, error)
+	importCSVFunc       func(table helper.QualifiedName, data string, opts model.ImportOptions) (*model.ImportResponse, error)
 	createTableFunc     func(tableName string, columns []model.ColumnDef) error
 	alterTableFunc      func(tableName string, ops []model.AlterTableOperation) error
 	dropTableFunc       func(tableName string, cascade bool) error
 	addConstraintFunc   func(params model.AddConstraintParams) error
 	dropConstraintFunc  func(tableName, constraintName string, cascade bool) error
 	listConstraintsFunc func(tableName string) ([]model.ConstraintInfo, error)
+
+	queryArgs []any // bind arguments of the last ExecuteQuery call
+}
+
+// writeMockRows replays a buffered result set through w, stopping at the
+// first error like the real clients do. Columns are reported without a type
+// unless fields are given.
+func writeMockRows(w service.RowWriter, columns []string, fields []model.ColumnMeta, rows [][]any) error {
+	if fields == nil {
+		fields = make([]model.ColumnMeta, len(columns))
+		for i, name := range columns {
+			fields[i] = model.ColumnMeta{Name: name}
+		}
+	}
+	if err := w.WriteColumns(fields); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (m *mockDBClient) Connect(dsn string) error {
 	return m.connectFunc(dsn)
 }
-func (m *mockDBClient) Disconnect() error                          { return nil }
-func (m *mockDBClient) ListSchemas() ([]string, error)             { return nil, nil }
-func (m *mockDBClient) ListTables(schema string) ([]string, error) { return nil, nil }
-func (m *mockDBClient) ListColumns(schema, table string) ([]model.Column, error) {
+func (m *mockDBClient) Disconnect() error {
+	if m.disconnectFunc != nil {
+		return m.disconnectFunc()
+	}
+	return nil
+}
+func (m *mockDBClient) BeginTx(ctx context.Context) (service.Tx, error) {
+	if m.beginTxFunc != nil {
+		return m.beginTxFunc()
+	}
+	return nil, errors.New("transactions not supported")
+}
+func (m *mockDBClient) ListSchemas(ctx context.Context) ([]string, error) { return nil, nil }
+func (m *mockDBClient) ListTables(ctx context.Context, schema string) ([]string, error) {
+	return nil, nil
+}
+func (m *mockDBClient) ListColumns(ctx context.Context, table helper.QualifiedName) ([]model.Column, error) {
 	if m.listColumnsFunc != nil {
-		return m.listColumnsFunc(schema, table)
+		return m.listColumnsFunc(table.Schema, table.Name)
 	}
 	return nil, nil
 }
-func (m *mockDBClient) ExecuteQuery(query string) ([]string, [][]any, error) {
+func (m *mockDBClient) ExecuteQuery(ctx context.Context, query string, args ...any) (*model.QueryResponse, error) {
+	m.queryArgs = args
 	if m.executeQueryFunc != nil {
 		return m.executeQueryFunc(query)
 	}
-	return nil, nil, nil
+	return &model.QueryResponse{}, nil
+}
+func (m *mockDBClient) StreamQuery(ctx context.Context, query string, w service.RowWriter, args ...any) (*model.QueryResponse, error) {
+	resp, err := m.ExecuteQuery(ctx, query, args...)
+	if err != nil || resp.Columns == nil {
+		return resp, err
+	}
+	return &model.QueryResponse{Command: resp.Command}, writeMockRows(w, resp.Columns, resp.Fields, resp.Rows)
 }
-func (m *mockDBClient) GetTableData(req model.TableDataRequest) ([]string, [][]any, error) {
+func (m *mockDBClient) ExecuteScript(ctx context.Context, statements []string, opts model.ScriptOptions) (*model.ScriptResponse, error) {
+	if m.executeScriptFunc != nil {
+		return m.executeScriptFunc(statements, opts)
+	}
+	return &model.ScriptResponse{}, nil
+}
+func (m *mockDBClient) Dialect() helper.SQLDialect {
+	return helper.DialectPostgres
+}
+func (m *mockDBClient) CancelQuery(ctx context.Context, backendID int64) error {
+	if m.cancelQueryFunc != nil {
+		return m.cancelQueryFunc(backendID)
+	}
+	return nil
+}
+func (m *mockDBClient) GetTableData(ctx context.Context, req model.TableDataRequest) (*model.TableDataResponse, error) {
 	if m.getTableDataFunc != nil {
 		return m.getTableDataFunc(req)
 	}
-	return nil, nil, nil
+	return &model.TableDataResponse{}, nil
+}
+func (m *mockDBClient) StreamTableData(ctx context.Context, req model.TableDataRequest, w service.RowWriter) (*model.TableDataResponse, error) {
+	resp, err := m.GetTableData(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	page := &model.TableDataResponse{NextCursor: resp.NextCursor, PrevCursor: resp.PrevCursor}
+	return page, writeMockRows(w, resp.Columns, resp.Fields, resp.Rows)
 }
-func (m *mockDBClient) InsertRecord(schema, table string, data map[string]any) error {
+func (m *mockDBClient) GetCellValue(ctx context.Context, table helper.QualifiedName, column string, key map[string]any) (*model.CellValue, error) {
+	if m.getCellValueFunc != nil {
+		return m.getCellValueFunc(table.Schema, table.Name, column, key)
+	}
+	return &model.CellValue{}, nil
+}
+func (m *mockDBClient) InsertRecord(ctx context.Context, table helper.QualifiedName, data map[string]any) (*model.QueryResponse, error) {
 	if m.insertRecordFunc != nil {
-		return m.insertRecordFunc(schema, table, data)
+		return m.insertRecordFunc(table.Schema, table.Name, data)
 	}
-	return nil
+	return &model.QueryResponse{}, nil
+}
+func (m *mockDBClient) UpsertRecord(ctx context.Context, table helper.QualifiedName, data map[string]any, upsert model.Upsert) (*model.QueryResponse, error) {
+	if m.upsertRecordFunc != nil {
+		return m.upsertRecordFunc(table, data, upsert)
+	}
+	return &model.QueryResponse{}, nil
 }
-func (m *mockDBClient) UpdateRecord(schema, table string, data, where map[string]any) (int64, error) {
+func (m *mockDBClient) UpdateRecord(ctx context.Context, table helper.QualifiedName, data, where map[string]any) (*model.QueryResponse, error) {
 	if m.updateRecordFunc != nil {
-		return m.updateRecordFunc(schema, table, data, where)
+		return m.updateRecordFunc(table.Schema, table.Name, data, where)
 	}
-	return 0, nil
+	return &model.QueryResponse{}, nil
 }
-func (m *mockDBClient) DeleteRecord(schema, table string, conditions map[string]any) (int64, error) {
+func (m *mockDBClient) DeleteRecord(ctx context.Context, table helper.QualifiedName, conditions map[string]any) (*model.QueryResponse, error) {
 	if m.deleteRecordFunc != nil {
-		return m.deleteRecordFunc(schema, table, conditions)
+		return m.deleteRecordFunc(table.Schema, table.Name, conditions)
+	}
+	return &model.QueryResponse{}, nil
+}
+func (m *mockDBClient) UpdateRow(ctx context.Context, edit model.RowEdit) (*model.RowEditResult, error) {
+	if m.updateRowFunc != nil {
+		return m.updateRowFunc(edit)
+	}
+	return &model.RowEditResult{}, nil
+}
+func (m *mockDBClient) DeleteRow(ctx context.Context, edit model.RowEdit) error {
+	if m.deleteRowFunc != nil {
+		return m.deleteRowFunc(edit)
+	}
+	return nil
+}
+func (m *mockDBClient) ExecuteBatch(ctx context.Context, req model.BatchRequest) (*model.BatchResponse, error) {
+	if m.executeBatchFunc != nil {
+		return m.executeBatchFunc(req)
 	}
-	return 0, nil
+	return &model.BatchResponse{}, nil
 }
-func (m *mockDBClient) CreateTable(tableName string, columns []model.ColumnDef) error {
+func (m *mockDBClient) ImportCSV(ctx context.Context, table helper.QualifiedName, src io.Reader, opts model.ImportOptions) (*model.ImportResponse, error) {
+	if m.importCSVFunc != nil {
+		data, err := io.ReadAll(src)
+		if err != nil {
+			return nil, err
+		}
+		return m.importCSVFunc(table, string(data), opts)
+	}
+	return &model.ImportResponse{}, nil
+}
+func (m *mockDBClient) CreateTable(ctx context.Context, table helper.QualifiedName, columns []model.ColumnDef) error {
 	if m.createTableFunc != nil {
-		return m.createTableFunc(tableName, columns)
+		return m.createTableFunc(table.Name, columns)
 	}
 	return nil
 }
-func (m *mockDBClient) AlterTable(tableName string, ops []model.AlterTableOperation) error {
+func (m *mockDBClient) AlterTable(ctx context.Context, table helper.QualifiedName, ops []model.AlterTableOperation) error {
 	if m.alterTableFunc != nil {
-		return m.alterTableFunc(tableName, ops)
+		return m.alterTableFunc(table.Name, ops)
 	}
 	return nil
 }
-func (m *mockDBClient) DropTable(tableName string, cascade bool) error {
+func (m *mockDBClient) DropTable(ctx context.Context, table helper.QualifiedName, cascade bool) error {
 	if m.dropTableFunc != nil {
-		return m.dropTableFunc(tableName, cascade)
+		return m.dropTableFunc(table.Name, cascade)
 	}
 	return nil
 }
-func (m *mockDBClient) AddConstraint(params model.AddConstraintParams) error {
+func (m *mockDBClient) AddConstraint(ctx context.Context, params model.AddConstraintParams) error {
 	if m.addConstraintFunc != nil {
 		return m.addConstraintFunc(params)
 	}
 	return nil
 }
-func (m *mockDBClient) DropConstraint(tableName, constraintName string, cascade bool) error {
+func (m *mockDBClient) DropConstraint(ctx context.Context, table helper.QualifiedName, constraintName string, cascade bool) error {
 	if m.dropConstraintFunc != nil {
-		return m.dropConstraintFunc(tableName, constraintName, cascade)
+		return m.dropConstraintFunc(table.Name, constraintName, cascade)
 	}
 	return nil
 }
-func (m *mockDBClient) ListConstraints(tableName string) ([]model.ConstraintInfo, error) {
+func (m *mockDBClient) ListConstraints(ctx context.Context, table helper.QualifiedName) ([]model.ConstraintInfo, error) {
 	if m.listConstraintsFunc != nil {
-		return m.listConstraintsFunc(tableName)
+		return m.listConstraintsFunc(table.Name)
 	}
 	return nil, nil
 }
 
+// useDB registers db as an open connection for the duration of the test and
+// points req at it. A nil db leaves the request without a connection ID.
+func useDB(t *testing.T, req *http.Request, db service.DBClient) {
+	if db == nil {
+		return
+	}
+	conn, err := connections.add("mock", "", db)
+	require.NoError(t, err)
+	t.Cleanup(func() { connections.remove(conn.ID) })
+	req.Header.Set(connectionHeader, conn.ID)
+}
+
 type listTablesMock struct {
 	mockDBClient
 	listTablesFunc func(schema string) ([]string, error)
 }
 
 // Override ListTables to use the injected func
-func (m *listTablesMock) ListTables(schema string) ([]string, error) {
+func (m *listTablesMock) ListTables(ctx context.Context, schema string) ([]string, error) {
 	return m.listTablesFunc(schema)
 }
 
@@ -138,7 +273,7 @@ func TestConnectHandler(t *testing.T) {
 		},
 		{
 			name:         "unsupported driver",
-			body:         `{"driver": "mysql", "dsn": "abc"}`,
+			body:         `{"driver": "oracle", "dsn": "abc"}`,
 			expectedCode: http.StatusBadRequest,
 			expectedBody: `{"error":"Unsupported driver"}`,
 		},
@@ -156,21 +291,45 @@ func TestConnectHandler(t *testing.T) {
 			driver:         "postgres",
 			mockConnectErr: nil,
 			expectedCode:   http.StatusOK,
-			expectedBody:   `{"message":"Connected successfully"}`,
+			expectedBody:   `"message":"Connected successfully"`,
+		},
+		{
+			name:           "mysql success",
+			body:           `{"driver": "mysql", "dsn": "user:pass@/db"}`,
+			driver:         "mysql",
+			mockConnectErr: nil,
+			expectedCode:   http.StatusOK,
+			expectedBody:   `"message":"Connected successfully"`,
+		},
+		{
+			name:           "sqlite success",
+			body:           `{"driver": "sqlite", "dsn": "/tmp/app.db"}`,
+			driver:         "sqlite",
+			mockConnectErr: nil,
+			expectedCode:   http.StatusOK,
+			expectedBody:   `"message":"Connected successfully"`,
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			// Patch newPostgresClient for postgres case
-			if tc.driver == "postgres" {
-				newPostgresClient = func() service.DBClient {
-					return &mockDBClient{connectFunc: func(dsn string) error {
-						return tc.mockConnectErr
-					}}
-				}
-			} else {
-				newPostgresClient = func() service.DBClient { return nil }
+			mockClient := func() service.DBClient {
+				return &mockDBClient{connectFunc: func(dsn string) error {
+					return tc.mockConnectErr
+				}}
+			}
+			newPostgresClient = func() service.DBClient { return nil }
+			newMySQLClient = func() service.DBClient { return nil }
+			newSQLiteClient = func() service.DBClient { return nil }
+
+			// Patch the constructor for the driver under test
+			switch tc.driver {
+			case "postgres":
+				newPostgresClient = mockClient
+			case "mysql":
+				newMySQLClient = mockClient
+			case "sqlite":
+				newSQLiteClient = mockClient
 			}
 
 			w := httptest.NewRecorder()
@@ -235,8 +394,6 @@ func TestListTablesHandler(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			// Patch global activeDB
-			activeDB = tc.activeDB
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
 			url := "/tables"
@@ -244,6 +401,7 @@ func TestListTablesHandler(t *testing.T) {
 				url += "?schema=" + tc.schema
 			}
 			c.Request, _ = http.NewRequest("GET", url, nil)
+			useDB(t, c.Request, tc.activeDB)
 
 			ListTablesHandler(c)
 
@@ -260,7 +418,7 @@ func TestQueryHandler(t *testing.T) {
 		name             string
 		activeDB         service.DBClient
 		body             string
-		executeQueryFunc func(query string) ([]string, [][]any, error)
+		executeQueryFunc func(query string) (*model.QueryResponse, error)
 		expectedCode     int
 		expectedBody     string
 	}{
@@ -281,8 +439,8 @@ func TestQueryHandler(t *testing.T) {
 		{
 			name: "query error",
 			activeDB: &mockDBClient{
-				executeQueryFunc: func(query string) ([]string, [][]any, error) {
-					return nil, nil, errors.New("fail query")
+				executeQueryFunc: func(query string) (*model.QueryResponse, error) {
+					return nil, errors.New("fail query")
 				},
 			},
 			body:         `{"sql": "SELECT * FROM foo"}`,
@@ -292,22 +450,34 @@ func TestQueryHandler(t *testing.T) {
 		{
 			name: "success",
 			activeDB: &mockDBClient{
-				executeQueryFunc: func(query string) ([]string, [][]any, error) {
-					return []string{"id", "name"}, [][]any{{1, "Alice"}, {2, "Bob"}}, nil
+				executeQueryFunc: func(query string) (*model.QueryResponse, error) {
+					return &model.QueryResponse{Columns: []string{"id", "name"}, Rows: [][]any{{1, "Alice"}, {2, "Bob"}}}, nil
 				},
 			},
 			body:         `{"sql": "SELECT id, name FROM users"}`,
 			expectedCode: http.StatusOK,
 			expectedBody: `{"columns":["id","name"],"rows":[[1,"Alice"],[2,"Bob"]]}`,
 		},
+		{
+			name: "rows affected",
+			activeDB: &mockDBClient{
+				executeQueryFunc: func(query string) (*model.QueryResponse, error) {
+					n := int64(3)
+					return &model.QueryResponse{Command: "UPDATE", RowsAffected: &n}, nil
+				},
+			},
+			body:         `{"sql": "UPDATE users SET active = true"}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"command":"UPDATE","message":"Query executed successfully","rows_affected":3}`,
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			activeDB = tc.activeDB
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
 			c.Request, _ = http.NewRequest("POST", "/query", bytes.NewBufferString(tc.body))
+			useDB(t, c.Request, tc.activeDB)
 			c.Request.Header.Set("Content-Type", "application/json")
 
 			// Patch ExecuteQuery if needed
@@ -379,7 +549,6 @@ func TestListColumnsHandler(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			activeDB = tc.activeDB
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
 			url := "/columns?table=" + tc.table
@@ -387,6 +556,7 @@ func TestListColumnsHandler(t *testing.T) {
 				url += "&schema=" + tc.schema
 			}
 			c.Request, _ = http.NewRequest("GET", url, nil)
+			useDB(t, c.Request, tc.activeDB)
 
 			// Patch ListColumns if needed
 			if m, ok := tc.activeDB.(*mockDBClient); ok && tc.listColumnsFunc != nil {
@@ -408,7 +578,7 @@ func TestTableDataHandler(t *testing.T) {
 		name           string
 		activeDB       service.DBClient
 		queryParams    string
-		getTableDataFn func(model.TableDataRequest) ([]string, [][]any, error)
+		getTableDataFn func(model.TableDataRequest) (*model.TableDataResponse, error)
 		expectedCode   int
 		expectedBody   string
 	}{
@@ -429,34 +599,79 @@ func TestTableDataHandler(t *testing.T) {
 		{
 			name: "db error",
 			activeDB: &mockDBClient{
-				getTableDataFunc: func(req model.TableDataRequest) ([]string, [][]any, error) {
-					return nil, nil, errors.New("fail db")
+				getTableDataFunc: func(req model.TableDataRequest) (*model.TableDataResponse, error) {
+					return nil, errors.New("fail db")
 				},
 			},
 			queryParams:  "schema=public&table=users",
 			expectedCode: http.StatusInternalServerError,
 			expectedBody: `{"error":"fail db"}`,
 		},
+		{
+			name:         "invalid count",
+			activeDB:     &mockDBClient{},
+			queryParams:  "schema=public&table=users&count=all",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid count; use auto, exact, estimate or none"}`,
+		},
+		{
+			name: "invalid cursor",
+			activeDB: &mockDBClient{
+				getTableDataFunc: func(req model.TableDataRequest) (*model.TableDataResponse, error) {
+					return nil, fmt.Errorf("%w: cursor does not match order_by", service.ErrInvalidCursor)
+				},
+			},
+			queryParams:  "schema=public&table=users&cursor=abc",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"invalid cursor: cursor does not match order_by"}`,
+		},
+		{
+			name: "table not found",
+			activeDB: &mockDBClient{
+				getTableDataFunc: func(req model.TableDataRequest) (*model.TableDataResponse, error) {
+					return nil, fmt.Errorf("%w: public.nope", service.ErrTableNotFound)
+				},
+			},
+			queryParams:  "schema=public&table=nope",
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"table not found: public.nope"}`,
+		},
+		{
+			name: "invalid filter",
+			activeDB: &mockDBClient{
+				getTableDataFunc: func(req model.TableDataRequest) (*model.TableDataResponse, error) {
+					_, _, err := helper.FilterSQL(req.Filters, nil, helper.DialectPostgres, func(s string) string { return s }, 1)
+					return nil, err
+				},
+			},
+			queryParams:  "schema=public&table=users&filter=age:~:3",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"invalid filter \"age:~:3\": unsupported operator \"~\""}`,
+		},
 		{
 			name: "success",
 			activeDB: &mockDBClient{
-				getTableDataFunc: func(req model.TableDataRequest) ([]string, [][]any, error) {
-					return []string{"id", "name"}, [][]any{{1, "Alice"}, {2, "Bob"}}, nil
+				getTableDataFunc: func(req model.TableDataRequest) (*model.TableDataResponse, error) {
+					return &model.TableDataResponse{
+						Columns: []string{"id", "name"},
+						Fields:  []model.ColumnMeta{{Name: "id", Type: "INT4"}, {Name: "name", Type: "TEXT"}},
+						Rows:    [][]any{{1, "Alice"}, {2, "Bob"}},
+					}, nil
 				},
 			},
 			queryParams:  "schema=public&table=users&limit=2&offset=0",
 			expectedCode: http.StatusOK,
-			expectedBody: `{"columns":["id","name"],"rows":[[1,"Alice"],[2,"Bob"]]}`,
+			expectedBody: `{"columns":["id","name"],"fields":[{"name":"id","type":"INT4"},{"name":"name","type":"TEXT"}],"rows":[[1,"Alice"],[2,"Bob"]]}`,
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			activeDB = tc.activeDB
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
 			url := "/tabledata?" + tc.queryParams
 			c.Request, _ = http.NewRequest("GET", url, nil)
+			useDB(t, c.Request, tc.activeDB)
 
 			// Patch GetTableData if needed
 			if m, ok := tc.activeDB.(*mockDBClient); ok && tc.getTableDataFn != nil {
@@ -471,6 +686,72 @@ func TestTableDataHandler(t *testing.T) {
 	}
 }
 
+func TestRecordValueHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		activeDB     service.DBClient
+		queryParams  string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "missing column",
+			activeDB:     &mockDBClient{},
+			queryParams:  "table=files&key[id]=1",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Missing table or column name"}`,
+		},
+		{
+			name:         "missing key",
+			activeDB:     &mockDBClient{},
+			queryParams:  "table=files&column=data",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Missing row key"}`,
+		},
+		{
+			name: "row not found",
+			activeDB: &mockDBClient{
+				getCellValueFunc: func(schema, table, column string, key map[string]any) (*model.CellValue, error) {
+					return nil, service.ErrRowNotFound
+				},
+			},
+			queryParams:  "table=files&column=data&key[id]=9",
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"row not found"}`,
+		},
+		{
+			name: "success",
+			activeDB: &mockDBClient{
+				getCellValueFunc: func(schema, table, column string, key map[string]any) (*model.CellValue, error) {
+					if key["id"] != "1" {
+						return nil, service.ErrRowNotFound
+					}
+					return &model.CellValue{Field: model.ColumnMeta{Name: column, Type: "BYTEA", Binary: true}, Value: "00ff"}, nil
+				},
+			},
+			queryParams:  "schema=public&table=files&column=data&key[id]=1",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"field":{"name":"data","type":"BYTEA","binary":true},"value":"00ff"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest("GET", "/records/value?"+tc.queryParams, nil)
+			useDB(t, c.Request, tc.activeDB)
+
+			RecordValueHandler(c)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tc.expectedBody)
+		})
+	}
+}
+
 func TestInsertRecordHandler(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -478,7 +759,8 @@ func TestInsertRecordHandler(t *testing.T) {
 		name             string
 		activeDB         service.DBClient
 		body             string
-		insertRecordFunc func(schema, table string, data map[string]any) error
+		insertRecordFunc func(schema, table string, data map[string]any) (*model.QueryResponse, error)
+		upsertRecordFunc func(table helper.QualifiedName, data map[string]any, upsert model.Upsert) (*model.QueryResponse, error)
 		expectedCode     int
 		expectedBody     string
 	}{
@@ -499,39 +781,104 @@ func TestInsertRecordHandler(t *testing.T) {
 		{
 			name: "db error",
 			activeDB: &mockDBClient{
-				insertRecordFunc: func(schema, table string, data map[string]any) error {
-					return errors.New("fail insert")
+				insertRecordFunc: func(schema, table string, data map[string]any) (*model.QueryResponse, error) {
+					return nil, errors.New("fail insert")
 				},
 			},
 			body:         `{"schema": "public", "table": "users", "data": {"name": "Abdul"}}`,
 			expectedCode: http.StatusInternalServerError,
 			expectedBody: `{"error":"fail insert"}`,
 		},
+		{
+			name: "invalid identifier",
+			activeDB: &mockDBClient{
+				insertRecordFunc: func(schema, table string, data map[string]any) (*model.QueryResponse, error) {
+					return nil, &helper.IdentifierError{Name: table, Reason: "empty name"}
+				},
+			},
+			body:         `{"schema": "public", "table": "", "data": {"name": "Abdul"}}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"invalid identifier \"\": empty name"}`,
+		},
 		{
 			name: "success",
 			activeDB: &mockDBClient{
-				insertRecordFunc: func(schema, table string, data map[string]any) error {
-					return nil
+				insertRecordFunc: func(schema, table string, data map[string]any) (*model.QueryResponse, error) {
+					if schema != "Sales" || table != "Orders" {
+						return nil, errors.New("unexpected table")
+					}
+					return &model.QueryResponse{}, nil
 				},
 			},
-			body:         `{"schema": "public", "table": "users", "data": {"name": "Abdul"}}`,
+			body:         `{"schema": "Sales", "table": "Orders", "data": {"name": "Abdul"}}`,
 			expectedCode: http.StatusOK,
 			expectedBody: `{"message":"Record inserted successfully"}`,
 		},
+		{
+			name: "returns inserted row",
+			activeDB: &mockDBClient{
+				insertRecordFunc: func(schema, table string, data map[string]any) (*model.QueryResponse, error) {
+					return &model.QueryResponse{
+						Columns: []string{"id", "name"},
+						Fields:  []model.ColumnMeta{{Name: "id", Type: "INTEGER", PrimaryKey: true}, {Name: "name", Type: "TEXT"}},
+						Rows:    [][]any{{42, data["name"]}},
+					}, nil
+				},
+			},
+			body:         `{"table": "users", "data": {"name": "Abdul"}}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"columns":["id","name"],"fields":[{"name":"id","type":"INTEGER","primary_key":true},{"name":"name","type":"TEXT"}],"message":"Record inserted successfully","rows":[[42,"Abdul"]]}`,
+		},
+		{
+			name:     "upsert",
+			activeDB: &mockDBClient{},
+			body:     `{"table": "countries", "data": {"code": "DE", "name": "Germany"}, "upsert": {"constraint": "countries_code_key"}}`,
+			upsertRecordFunc: func(table helper.QualifiedName, data map[string]any, upsert model.Upsert) (*model.QueryResponse, error) {
+				if upsert.Constraint != "countries_code_key" {
+					return nil, errors.New("unexpected constraint")
+				}
+				return &model.QueryResponse{}, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"message":"Record upserted successfully"}`,
+		},
+		{
+			name:     "upsert unknown constraint",
+			activeDB: &mockDBClient{},
+			body:     `{"table": "countries", "data": {"code": "DE"}, "upsert": {"constraint": "nope"}}`,
+			upsertRecordFunc: func(table helper.QualifiedName, data map[string]any, upsert model.Upsert) (*model.QueryResponse, error) {
+				return nil, fmt.Errorf("%w %q", service.ErrUnknownConstraint, upsert.Constraint)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"no primary key or unique constraint named \"nope\""}`,
+		},
+		{
+			name:     "upsert ambiguous on mysql",
+			activeDB: &mockDBClient{},
+			body:     `{"table": "users", "data": {"id": 1, "email": "a@b.c"}, "upsert": {}}`,
+			upsertRecordFunc: func(table helper.QualifiedName, data map[string]any, upsert model.Upsert) (*model.QueryResponse, error) {
+				return nil, fmt.Errorf("%w: the table also has \"email\"", service.ErrAmbiguousUpsert)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"upsert key is ambiguous: the table also has \"email\""}`,
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			activeDB = tc.activeDB
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
 			c.Request, _ = http.NewRequest("POST", "/records", bytes.NewBufferString(tc.body))
+			useDB(t, c.Request, tc.activeDB)
 			c.Request.Header.Set("Content-Type", "application/json")
 
 			// Patch InsertRecord if needed
 			if m, ok := tc.activeDB.(*mockDBClient); ok && tc.insertRecordFunc != nil {
 				m.insertRecordFunc = tc.insertRecordFunc
 			}
+			if m, ok := tc.activeDB.(*mockDBClient); ok && tc.upsertRecordFunc != nil {
+				m.upsertRecordFunc = tc.upsertRecordFunc
+			}
 
 			InsertRecordHandler(c)
 
@@ -548,7 +895,7 @@ func TestUpdateRecordHandler(t *testing.T) {
 		name             string
 		activeDB         service.DBClient
 		body             string
-		updateRecordFunc func(schema, table string, data, where map[string]any) (int64, error)
+		updateRecordFunc func(schema, table string, data, where map[string]any) (*model.QueryResponse, error)
 		expectedCode     int
 		expectedBody     string
 	}{
@@ -569,8 +916,8 @@ func TestUpdateRecordHandler(t *testing.T) {
 		{
 			name: "db error",
 			activeDB: &mockDBClient{
-				updateRecordFunc: func(schema, table string, data, where map[string]any) (int64, error) {
-					return 0, errors.New("fail update")
+				updateRecordFunc: func(schema, table string, data, where map[string]any) (*model.QueryResponse, error) {
+					return nil, errors.New("fail update")
 				},
 			},
 			body:         `{"schema": "public", "table": "users", "data": {"email": "updated@example.com"}, "where": {"id": 1}}`,
@@ -580,22 +927,28 @@ func TestUpdateRecordHandler(t *testing.T) {
 		{
 			name: "success",
 			activeDB: &mockDBClient{
-				updateRecordFunc: func(schema, table string, data, where map[string]any) (int64, error) {
-					return 1, nil
+				updateRecordFunc: func(schema, table string, data, where map[string]any) (*model.QueryResponse, error) {
+					n := int64(1)
+					return &model.QueryResponse{
+						Columns:      []string{"id", "email"},
+						Fields:       []model.ColumnMeta{{Name: "id", Type: "int4", PrimaryKey: true}, {Name: "email", Type: "text"}},
+						Rows:         [][]any{{1, "updated@example.com"}},
+						RowsAffected: &n,
+					}, nil
 				},
 			},
 			body:         `{"schema": "public", "table": "users", "data": {"email": "updated@example.com"}, "where": {"id": 1}}`,
 			expectedCode: http.StatusOK,
-			expectedBody: `{"message":"Record(s) updated successfully","rows_affected":1}`,
+			expectedBody: `{"message":"Record(s) updated successfully","rows_affected":1,"columns":["id","email"],"fields":[{"name":"id","type":"int4","primary_key":true},{"name":"email","type":"text"}],"rows":[[1,"updated@example.com"]]}`,
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			activeDB = tc.activeDB
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
 			c.Request, _ = http.NewRequest("PUT", "/records", bytes.NewBufferString(tc.body))
+			useDB(t, c.Request, tc.activeDB)
 			c.Request.Header.Set("Content-Type", "application/json")
 
 			// Patch UpdateRecord if needed
@@ -659,10 +1012,10 @@ func TestCreateTableHandler(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			activeDB = tc.activeDB
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
 			c.Request, _ = http.NewRequest("POST", "/tables", bytes.NewBufferString(tc.body))
+			useDB(t, c.Request, tc.activeDB)
 			c.Request.Header.Set("Content-Type", "application/json")
 
 			// Patch CreateTable if needed
@@ -689,7 +1042,7 @@ func TestDeleteRecordHandler(t *testing.T) {
 		name             string
 		activeDB         service.DBClient
 		body             string
-		deleteRecordFunc func(schema, table string, conditions map[string]any) (int64, error)
+		deleteRecordFunc func(schema, table string, conditions map[string]any) (*model.QueryResponse, error)
 		expectedCode     int
 		expectedBody     string
 	}{
@@ -724,8 +1077,8 @@ func TestDeleteRecordHandler(t *testing.T) {
 		{
 			name: "db error",
 			activeDB: &mockDBClient{
-				deleteRecordFunc: func(schema, table string, conditions map[string]any) (int64, error) {
-					return 0, errors.New("fail delete")
+				deleteRecordFunc: func(schema, table string, conditions map[string]any) (*model.QueryResponse, error) {
+					return nil, errors.New("fail delete")
 				},
 			},
 			body:         `{"schema": "public", "table": "users", "conditions": {"id": 1}}`,
@@ -735,8 +1088,9 @@ func TestDeleteRecordHandler(t *testing.T) {
 		{
 			name: "success",
 			activeDB: &mockDBClient{
-				deleteRecordFunc: func(schema, table string, conditions map[string]any) (int64, error) {
-					return 1, nil
+				deleteRecordFunc: func(schema, table string, conditions map[string]any) (*model.QueryResponse, error) {
+					n := int64(1)
+					return &model.QueryResponse{RowsAffected: &n}, nil
 				},
 			},
 			body:         `{"schema": "public", "table": "users", "conditions": {"id": 1}}`,
@@ -747,10 +1101,10 @@ func TestDeleteRecordHandler(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			activeDB = tc.activeDB
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
 			c.Request, _ = http.NewRequest("DELETE", "/records", bytes.NewBufferString(tc.body))
+			useDB(t, c.Request, tc.activeDB)
 			c.Request.Header.Set("Content-Type", "application/json")
 
 			// Patch DeleteRecord if needed
@@ -770,6 +1124,130 @@ func TestDeleteRecordHandler(t *testing.T) {
 	}
 }
 
+func TestUpdateRowHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		body          string
+		updateRowFunc func(edit model.RowEdit) (*model.RowEditResult, error)
+		expectedCode  int
+		expectedBody  string
+	}{
+		{
+			name:         "missing key",
+			body:         `{"table": "users", "data": {"name": "Ann"}}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Missing table, key or data"}`,
+		},
+		{
+			name: "no key columns",
+			body: `{"table": "logs", "key": {"msg": "boot"}, "data": {"msg": "up"}}`,
+			updateRowFunc: func(edit model.RowEdit) (*model.RowEditResult, error) {
+				return nil, service.ErrNoPrimaryKey
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"table has no primary or unique key"}`,
+		},
+		{
+			name: "conflict",
+			body: `{"table": "users", "key": {"id": 1}, "data": {"name": "Ann"}, "version": "740"}`,
+			updateRowFunc: func(edit model.RowEdit) (*model.RowEditResult, error) {
+				return nil, service.ErrRowConflict
+			},
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"error":"row was changed since it was read"}`,
+		},
+		{
+			name: "success",
+			body: `{"table": "users", "key": {"id": 1}, "data": {"name": "Ann"}, "expected": {"name": "Alice"}}`,
+			updateRowFunc: func(edit model.RowEdit) (*model.RowEditResult, error) {
+				if edit.Expected["name"] != "Alice" {
+					return nil, service.ErrRowConflict
+				}
+				return &model.RowEditResult{Version: "741"}, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"message":"Row updated successfully","version":"741"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest("PUT", "/records/row", bytes.NewBufferString(tc.body))
+			useDB(t, c.Request, &mockDBClient{updateRowFunc: tc.updateRowFunc})
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			UpdateRowHandler(c)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestBatchRecordsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name             string
+		body             string
+		executeBatchFunc func(req model.BatchRequest) (*model.BatchResponse, error)
+		expectedCode     int
+		expectedBody     string
+	}{
+		{
+			name:         "no operations",
+			body:         `{"table": "users", "inserts": []}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Missing table or operations"}`,
+		},
+		{
+			name: "invalid table",
+			body: `{"table": "bad\u0000name", "inserts": [{"name": "Ann"}]}`,
+			executeBatchFunc: func(req model.BatchRequest) (*model.BatchResponse, error) {
+				return nil, helper.CheckIdentifiers(helper.DialectPostgres, req.Table)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"invalid identifier \"bad\\x00name\": contains a NUL character"}`,
+		},
+		{
+			name: "rolled back",
+			body: `{"table": "users", "inserts": [{"name": "Ann"}, {"name": null}], "deletes": [{"id": 3}]}`,
+			executeBatchFunc: func(req model.BatchRequest) (*model.BatchResponse, error) {
+				return &model.BatchResponse{
+					Results: []model.BatchResult{
+						{Op: "insert", Index: 0, RowsAffected: 1},
+						{Op: "insert", Index: 1, Error: "name must not be null"},
+						{Op: "delete", Index: 0, Skipped: true},
+					},
+					Failed:      1,
+					Transaction: "rolled_back",
+				}, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"results":[{"op":"insert","index":0,"rows_affected":1},{"op":"insert","index":1,"rows_affected":0,"error":"name must not be null"},{"op":"delete","index":0,"rows_affected":0,"skipped":true}],"failed":1,"transaction":"rolled_back"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest("POST", "/records/batch", bytes.NewBufferString(tc.body))
+			useDB(t, c.Request, &mockDBClient{executeBatchFunc: tc.executeBatchFunc})
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			BatchRecordsHandler(c)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
 func TestAlterTableHandler(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -898,10 +1376,10 @@ func TestAlterTableHandler(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			activeDB = tc.activeDB
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
 			c.Request, _ = http.NewRequest("PUT", "/tables/"+tc.tableName, bytes.NewBufferString(tc.body))
+			useDB(t, c.Request, tc.activeDB)
 			c.Request.Header.Set("Content-Type", "application/json")
 			c.Params = gin.Params{
 				{Key: "table_name", Value: tc.tableName},
@@ -1013,7 +1491,6 @@ func TestDropTableHandler(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			activeDB = tc.activeDB
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
 
@@ -1024,6 +1501,7 @@ func TestDropTableHandler(t *testing.T) {
 			}
 
 			c.Request, _ = http.NewRequest("DELETE", url, nil)
+			useDB(t, c.Request, tc.activeDB)
 			c.Params = gin.Params{
 				{Key: "table_name", Value: tc.tableName},
 			}
@@ -1131,10 +1609,10 @@ func TestAddConstraintHandler(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			activeDB = tc.activeDB
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
 			c.Request, _ = http.NewRequest("POST", "/api/schema/constraints", bytes.NewBufferString(tc.body))
+			useDB(t, c.Request, tc.activeDB)
 			c.Request.Header.Set("Content-Type", "application/json")
 
 			// Patch AddConstraint if needed
@@ -1246,7 +1724,6 @@ func TestDropConstraintHandler(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			activeDB = tc.activeDB
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
 
@@ -1257,6 +1734,7 @@ func TestDropConstraintHandler(t *testing.T) {
 			}
 
 			c.Request, _ = http.NewRequest("DELETE", url, nil)
+			useDB(t, c.Request, tc.activeDB)
 			c.Params = gin.Params{
 				{Key: "table_name", Value: tc.tableName},
 				{Key: "constraint_name", Value: tc.constraintName},
@@ -1359,10 +1837,10 @@ func TestListConstraintsHandler(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			activeDB = tc.activeDB
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
 			c.Request, _ = http.NewRequest("GET", "/api/schema/"+tc.tableName+"/constraints", nil)
+			useDB(t, c.Request, tc.activeDB)
 			c.Params = gin.Params{
 				{Key: "table_name", Value: tc.tableName},
 			}
@@ -1383,3 +1861,90 @@ func TestListConstraintsHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestImportCSVHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	form := func(fields map[string]string, file string) (*bytes.Buffer, string) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for k, v := range fields {
+			mw.WriteField(k, v)
+		}
+		if file != "" {
+			fw, _ := mw.CreateFormFile("file", "vendor.csv")
+			fw.Write([]byte(file))
+		}
+		mw.Close()
+		return &body, mw.FormDataContentType()
+	}
+
+	tests := []struct {
+		name          string
+		fields        map[string]string
+		file          string
+		importCSVFunc func(table helper.QualifiedName, data string, opts model.ImportOptions) (*model.ImportResponse, error)
+		expectedCode  int
+		expectedBody  string
+	}{
+		{
+			name:         "missing file",
+			fields:       map[string]string{"table": "items"},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Missing table or file"}`,
+		},
+		{
+			name:         "invalid mapping",
+			fields:       map[string]string{"table": "items", "mapping": `["sku"]`},
+			file:         "sku\nA1\n",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid mapping, expected a JSON object"}`,
+		},
+		{
+			name:   "invalid import",
+			fields: map[string]string{"table": "items"},
+			file:   "sku\nA1\n",
+			importCSVFunc: func(table helper.QualifiedName, data string, opts model.ImportOptions) (*model.ImportResponse, error) {
+				return nil, fmt.Errorf("%w: no file column matches a table column; give a mapping", service.ErrInvalidImport)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"invalid import: no file column matches a table column; give a mapping"}`,
+		},
+		{
+			name:   "success",
+			fields: map[string]string{"schema": "vendor", "table": "items", "delimiter": "tab", "header": "true", "mapping": `{"Artikel": "sku"}`},
+			file:   "Artikel\nA1\nA2\n",
+			importCSVFunc: func(table helper.QualifiedName, data string, opts model.ImportOptions) (*model.ImportResponse, error) {
+				if table.Schema != "vendor" || table.Name != "items" || opts.Delimiter != "\t" || !*opts.Header || opts.Mapping["Artikel"] != "sku" {
+					return nil, errors.New("unexpected options")
+				}
+				if data != "Artikel\nA1\nA2\n" {
+					return nil, errors.New("unexpected file")
+				}
+				return &model.ImportResponse{
+					Encoding: "utf-8", Delimiter: "\t", Header: true, Mapping: opts.Mapping,
+					RowsImported: 1, RowsRejected: 1,
+					Rejected: []model.ImportRejection{{Line: 3, Column: "sku", Reason: "value too long"}},
+				}, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"encoding":"utf-8","delimiter":"\t","header":true,"mapping":{"Artikel":"sku"},"rows_imported":1,"rows_rejected":1,"rejected":[{"line":3,"column":"sku","reason":"value too long"}]}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			body, contentType := form(tc.fields, tc.file)
+			c.Request, _ = http.NewRequest("POST", "/records/import", body)
+			c.Request.Header.Set("Content-Type", contentType)
+			useDB(t, c.Request, &mockDBClient{importCSVFunc: tc.importCSVFunc})
+
+			ImportCSVHandler(c)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
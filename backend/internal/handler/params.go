@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12}$`)
+
+// decodeParams parses the params of a query request. An array is bound to
+// the driver's own placeholders ($1 or ?) in order; an object is bound to
+// :name parameters. Each value is a JSON scalar or a typed value such as
+// {"type": "date", "value": "2024-01-31"}.
+func decodeParams(raw json.RawMessage) (positional []any, named map[string]any, err error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil, nil
+	}
+
+	switch raw[0] {
+	case '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, nil, err
+		}
+		positional = make([]any, len(items))
+		for i, item := range items {
+			if positional[i], err = paramValue(item); err != nil {
+				return nil, nil, fmt.Errorf("param %d: %w", i+1, err)
+			}
+		}
+		return positional, nil, nil
+	case '{':
+		var items map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, nil, err
+		}
+		named = make(map[string]any, len(items))
+		for name, item := range items {
+			if named[name], err = paramValue(item); err != nil {
+				return nil, nil, fmt.Errorf("param %s: %w", name, err)
+			}
+		}
+		return nil, named, nil
+	}
+	return nil, nil, errors.New("params must be an array or an object")
+}
+
+// paramValue converts one JSON parameter into a value the drivers can bind.
+// Numbers keep their integer precision; objects must carry a type hint.
+func paramValue(raw json.RawMessage) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	switch val := v.(type) {
+	case json.Number:
+		return numberValue(val)
+	case map[string]any:
+		hint, ok := val["type"].(string)
+		if !ok {
+			return nil, errors.New(`objects must be typed, e.g. {"type": "json", "value": {...}}`)
+		}
+		return typedValue(strings.ToLower(hint), val["value"])
+	case []any:
+		return nil, errors.New(`arrays must be typed, e.g. {"type": "json", "value": [...]}`)
+	}
+	return v, nil
+}
+
+func numberValue(n json.Number) (any, error) {
+	if i, err := n.Int64(); err == nil {
+		return i, nil
+	}
+	return n.Float64()
+}
+
+// typedValue applies a type hint to a parameter value.
+func typedValue(hint string, value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch hint {
+	case "json":
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case "date", "timestamp", "uuid":
+	default:
+		return nil, fmt.Errorf("unknown type %q", hint)
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s value must be a string", hint)
+	}
+	switch hint {
+	case "date":
+		t, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", s)
+		}
+		return t, nil
+	case "timestamp":
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q, expected RFC 3339", s)
+		}
+		return t, nil
+	default:
+		if !uuidRegex.MatchString(s) {
+			return nil, fmt.Errorf("invalid uuid %q", s)
+		}
+		return strings.ToLower(s), nil
+	}
+}
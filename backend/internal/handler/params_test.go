@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"vind/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryHandlerParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		body         string
+		expectedSQL  string
+		expectedArgs []any
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "positional",
+			body:         `{"sql": "SELECT * FROM t WHERE id = $1 AND name = $2", "params": [9007199254740993, "ann"]}`,
+			expectedSQL:  "SELECT * FROM t WHERE id = $1 AND name = $2",
+			expectedArgs: []any{int64(9007199254740993), "ann"},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:        "named with type hints",
+			body:        `{"sql": "SELECT * FROM t WHERE day = :day AND id = :id AND meta @> :meta AND at < :at AND ratio > :ratio", "params": {"day": {"type": "date", "value": "2024-02-29"}, "id": {"type": "uuid", "value": "A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11"}, "meta": {"type": "json", "value": {"k": [1, 2]}}, "at": {"type": "timestamp", "value": "2024-02-29T10:00:00Z"}, "ratio": 0.5}}`,
+			expectedSQL: "SELECT * FROM t WHERE day = $1 AND id = $2 AND meta @> $3 AND at < $4 AND ratio > $5",
+			expectedArgs: []any{
+				time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+				"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11",
+				`{"k":[1,2]}`,
+				time.Date(2024, 2, 29, 10, 0, 0, 0, time.UTC),
+				0.5,
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "missing named parameter",
+			body:         `{"sql": "SELECT :a, :b", "params": {"a": 1}}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid params: missing value for parameter :b"}`,
+		},
+		{
+			name:         "invalid date",
+			body:         `{"sql": "SELECT $1", "params": [{"type": "date", "value": "29/02/2024"}]}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid params: param 1: invalid date \"29/02/2024\", expected YYYY-MM-DD"}`,
+		},
+		{
+			name:         "untyped object",
+			body:         `{"sql": "SELECT $1", "params": [{"k": 1}]}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid params: param 1: objects must be typed, e.g. {\"type\": \"json\", \"value\": {...}}"}`,
+		},
+		{
+			name:         "params with a script",
+			body:         `{"sql": "SELECT $1; SELECT 2", "params": [1]}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Parameters are not supported for multi-statement scripts"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := gin.New()
+			r.POST("/query", QueryHandler)
+
+			var gotSQL string
+			db := &mockDBClient{
+				executeQueryFunc: func(query string) (*model.QueryResponse, error) {
+					gotSQL = query
+					return &model.QueryResponse{Columns: []string{"ok"}, Rows: [][]any{{true}}}, nil
+				},
+			}
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/query", bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			useDB(t, req, db)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code, w.Body.String())
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, w.Body.String())
+				return
+			}
+			assert.Equal(t, tc.expectedSQL, gotSQL)
+			assert.Equal(t, tc.expectedArgs, db.queryArgs)
+		})
+	}
+}
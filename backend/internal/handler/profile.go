@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"vind/backend/internal/model"
+	"vind/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// profileStore is nil when no master key is configured, which disables the
+// profile endpoints.
+var profileStore *service.ProfileStore
+
+// SetProfileStore enables the profile endpoints backed by store.
+func SetProfileStore(store *service.ProfileStore) {
+	profileStore = store
+}
+
+func requireProfileStore(c *gin.Context) bool {
+	if profileStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Profile storage is disabled; set VIND_MASTER_KEY to enable it"})
+		return false
+	}
+	return true
+}
+
+func profileError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrProfileNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidProfile):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func ListProfilesHandler(c *gin.Context) {
+	if !requireProfileStore(c) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"profiles": profileStore.List()})
+}
+
+func GetProfileHandler(c *gin.Context) {
+	if !requireProfileStore(c) {
+		return
+	}
+
+	profile, err := profileStore.Get(c.Param("id"))
+	if err != nil {
+		profileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+func CreateProfileHandler(c *gin.Context) {
+	if !requireProfileStore(c) {
+		return
+	}
+
+	var req model.ConnectionProfile
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	profile, err := profileStore.Create(req)
+	if err != nil {
+		profileError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, profile)
+}
+
+func UpdateProfileHandler(c *gin.Context) {
+	if !requireProfileStore(c) {
+		return
+	}
+
+	var req model.ConnectionProfile
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	profile, err := profileStore.Update(c.Param("id"), req)
+	if err != nil {
+		profileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+func DeleteProfileHandler(c *gin.Context) {
+	if !requireProfileStore(c) {
+		return
+	}
+
+	if err := profileStore.Delete(c.Param("id")); err != nil {
+		profileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Profile deleted successfully"})
+}
+
+func ConnectProfileHandler(c *gin.Context) {
+	if !requireProfileStore(c) {
+		return
+	}
+
+	profile, dsn, err := profileStore.DSN(c.Param("id"))
+	if err != nil {
+		profileError(c, err)
+		return
+	}
+
+	openConnection(c, profile.Driver, profile.Name, dsn)
+}
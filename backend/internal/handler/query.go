@@ -1,10 +1,15 @@
 package handler
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"vind/backend/helper"
 	"vind/backend/internal/model"
+	"vind/backend/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,90 +21,235 @@ func QueryHandler(c *gin.Context) {
 		return
 	}
 
-	if activeDB == nil {
+	db := currentDB(c)
+	if db == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No active database connection"})
 		return
 	}
+	stream, ok := newStreamWriter(c)
+	if !ok {
+		return
+	}
+
+	statements := helper.SplitSQL(req.SQL, db.Dialect())
+	isScript := len(statements) > 1 || req.Transaction
+	if isScript && stream != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Streaming is not supported for multi-statement scripts"})
+		return
+	}
+
+	query := req.SQL
+	args, named, err := decodeParams(req.Params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid params: " + err.Error()})
+		return
+	}
+	if isScript && (args != nil || named != nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parameters are not supported for multi-statement scripts"})
+		return
+	}
+	if named != nil {
+		if query, args, err = helper.BindNamed(query, db.Dialect(), named); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid params: " + err.Error()})
+			return
+		}
+	}
+
 	log.Println("Executing query:", req.SQL)
-	columns, results, err := activeDB.ExecuteQuery(req.SQL)
+	ctx, running, ok := startQuery(c, req.SQL, db)
+	if !ok {
+		return
+	}
+
+	if isScript {
+		opts := model.ScriptOptions{StopOnError: req.StopOnError, Transaction: req.Transaction}
+		resp, err := db.ExecuteScript(ctx, statements, opts)
+		if cancelled := runningQueries.finish(running); cancelled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Query was cancelled", "query_id": running.ID})
+			return
+		}
+		if err != nil {
+			dbError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	var resp *model.QueryResponse
+	if stream != nil {
+		resp, err = db.StreamQuery(ctx, query, stream, args...)
+	} else {
+		resp, err = db.ExecuteQuery(ctx, query, args...)
+	}
+	cancelled := runningQueries.finish(running)
+
+	if stream != nil && stream.started {
+		if cancelled {
+			stream.finish("Query was cancelled")
+		} else {
+			stream.end(err)
+		}
+		return
+	}
+	if cancelled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Query was cancelled", "query_id": running.ID})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		dbError(c, err)
 		return
 	}
 
-	if columns == nil {
-		c.JSON(http.StatusOK, gin.H{"message": "Query executed successfully"})
+	if resp.Columns == nil {
+		body := gin.H{"message": "Query executed successfully", "command": resp.Command}
+		if resp.RowsAffected != nil {
+			body["rows_affected"] = *resp.RowsAffected
+		}
+		c.JSON(http.StatusOK, body)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"columns": columns,
-		"rows":    results,
-	})
+	c.JSON(http.StatusOK, resp)
 }
 
 func TableDataHandler(c *gin.Context) {
-	if activeDB == nil {
+	db := currentDB(c)
+	if db == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Not connected to any database"})
 		return
 	}
 
-	schema := c.DefaultQuery("schema", "public")
+	schema := c.Query("schema")
 	table := c.Query("table")
 	limit := c.DefaultQuery("limit", "100")
 	offset := c.DefaultQuery("offset", "0")
 	orderBy := c.Query("order_by")
+	cursor := c.Query("cursor")
+	count := c.Query("count")
 	filters := c.QueryArray("filter")
+	omitLarge := c.Query("omit_large") == "true"
+	var columns []string
+	if val := c.Query("columns"); val != "" {
+		columns = strings.Split(val, ",")
+	}
 
 	if table == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing table name"})
 		return
 	}
+	if !service.ValidCountMode(count) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid count; use auto, exact, estimate or none"})
+		return
+	}
 
 	req := model.TableDataRequest{
-		Schema:  schema,
-		Table:   table,
-		Limit:   limit,
-		Offset:  offset,
-		OrderBy: orderBy,
-		Filters: filters,
+		Schema:    schema,
+		Table:     table,
+		Limit:     limit,
+		Offset:    offset,
+		OrderBy:   orderBy,
+		Columns:   columns,
+		OmitLarge: omitLarge,
+		Cursor:    cursor,
+		Count:     count,
+		Filters:   filters,
 	}
 
-	columns, rows, err := activeDB.GetTableData(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	stream, ok := newStreamWriter(c)
+	if !ok {
+		return
+	}
+	if stream != nil {
+		resp, err := db.StreamTableData(c.Request.Context(), req, stream)
+		if !stream.started && err != nil {
+			dbError(c, err)
+			return
+		}
+		if resp != nil {
+			stream.summary = pageSummary(resp)
+		}
+		stream.end(err)
 		return
 	}
 
-	resp := model.TableDataResponse{
-		Columns: columns,
-		Rows:    rows,
+	resp, err := db.GetTableData(c.Request.Context(), req)
+	if err != nil {
+		dbError(c, err)
+		return
 	}
 	c.JSON(http.StatusOK, resp)
 }
 
+// RecordValueHandler loads a single column value, such as one omitted from
+// table data, of the row whose primary key is given as key[column]=value.
+func RecordValueHandler(c *gin.Context) {
+	db := currentDB(c)
+	if db == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not connected to any database"})
+		return
+	}
+
+	table := c.Query("table")
+	column := c.Query("column")
+	if table == "" || column == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing table or column name"})
+		return
+	}
+	key := map[string]any{}
+	for col, val := range c.QueryMap("key") {
+		key[col] = val
+	}
+	if len(key) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing row key"})
+		return
+	}
+
+	cell, err := db.GetCellValue(c.Request.Context(), helper.QualifiedName{Schema: c.Query("schema"), Name: table}, column, key)
+	if err != nil {
+		dbError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cell)
+}
+
+// InsertRecordHandler inserts a row or, given an upsert, updates the row it
+// conflicts with instead.
 func InsertRecordHandler(c *gin.Context) {
 	var req struct {
 		Schema string         `json:"schema"`
 		Table  string         `json:"table"`
 		Data   map[string]any `json:"data"`
+		Upsert *model.Upsert  `json:"upsert"` // optional
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
 		return
 	}
 
-	if activeDB == nil {
+	db := currentDB(c)
+	if db == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No active DB connection"})
 		return
 	}
 
-	if err := activeDB.InsertRecord(req.Schema, req.Table, req.Data); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	table := helper.QualifiedName{Schema: req.Schema, Name: req.Table}
+	if req.Upsert != nil {
+		written, err := db.UpsertRecord(c.Request.Context(), table, req.Data, *req.Upsert)
+		if err != nil {
+			dbError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, writtenRecords("Record upserted successfully", written, false))
+		return
+	}
+	written, err := db.InsertRecord(c.Request.Context(), table, req.Data)
+	if err != nil {
+		dbError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Record inserted successfully"})
+	c.JSON(http.StatusOK, writtenRecords("Record inserted successfully", written, false))
 }
 
 func UpdateRecordHandler(c *gin.Context) {
@@ -115,18 +265,20 @@ func UpdateRecordHandler(c *gin.Context) {
 		return
 	}
 
-	if activeDB == nil {
+	db := currentDB(c)
+	if db == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No active DB connection"})
 		return
 	}
 
-	rowsAffected, err := activeDB.UpdateRecord(req.Schema, req.Table, req.Data, req.Where)
+	table := helper.QualifiedName{Schema: req.Schema, Name: req.Table}
+	written, err := db.UpdateRecord(c.Request.Context(), table, req.Data, req.Where)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		dbError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Record(s) updated successfully", "rows_affected": rowsAffected})
+	c.JSON(http.StatusOK, writtenRecords("Record(s) updated successfully", written, true))
 }
 
 func DeleteRecordHandler(c *gin.Context) {
@@ -146,16 +298,179 @@ func DeleteRecordHandler(c *gin.Context) {
 		return
 	}
 
-	if activeDB == nil {
+	db := currentDB(c)
+	if db == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "No active database connection"})
 		return
 	}
 
-	rowsAffected, err := activeDB.DeleteRecord(req.Schema, req.Table, req.Conditions)
+	table := helper.QualifiedName{Schema: req.Schema, Name: req.Table}
+	written, err := db.DeleteRecord(c.Request.Context(), table, req.Conditions)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		dbError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, writtenRecords("Record deleted successfully", written, true))
+}
+
+// writtenRecords is the response to an insert, update or delete: message,
+// the number of rows written if count is set, and the rows themselves where
+// the database could return them.
+func writtenRecords(message string, written *model.QueryResponse, count bool) gin.H {
+	body := gin.H{"message": message}
+	if count {
+		var n int64
+		if written.RowsAffected != nil {
+			n = *written.RowsAffected
+		}
+		body["rows_affected"] = n
+	}
+	if written.Columns != nil {
+		body["columns"] = written.Columns
+		body["fields"] = written.Fields
+		body["rows"] = written.Rows
+	}
+	return body
+}
+
+// UpdateRowHandler updates the single row identified by its primary or
+// unique key, failing with 409 if it no longer holds the expected values.
+func UpdateRowHandler(c *gin.Context) {
+	var edit model.RowEdit
+	if err := c.ShouldBindJSON(&edit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	if edit.Table == "" || len(edit.Key) == 0 || len(edit.Data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing table, key or data"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Record deleted successfully", "rows_affected": rowsAffected})
+	db := currentDB(c)
+	if db == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No active DB connection"})
+		return
+	}
+
+	result, err := db.UpdateRow(c.Request.Context(), edit)
+	if err != nil {
+		dbError(c, err)
+		return
+	}
+
+	resp := gin.H{"message": "Row updated successfully"}
+	if result.Version != "" {
+		resp["version"] = result.Version
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteRowHandler deletes the single row identified by its primary or
+// unique key, failing with 409 if it no longer holds the expected values.
+func DeleteRowHandler(c *gin.Context) {
+	var edit model.RowEdit
+	if err := c.ShouldBindJSON(&edit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	if edit.Table == "" || len(edit.Key) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing table or key"})
+		return
+	}
+
+	db := currentDB(c)
+	if db == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No active DB connection"})
+		return
+	}
+
+	if err := db.DeleteRow(c.Request.Context(), edit); err != nil {
+		dbError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Row deleted successfully"})
+}
+
+// BatchRecordsHandler runs many inserts, updates and deletes on one table in
+// a single transaction and reports the outcome of each.
+func BatchRecordsHandler(c *gin.Context) {
+	var req model.BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	if req.Table == "" || len(req.Inserts)+len(req.Updates)+len(req.Deletes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing table or operations"})
+		return
+	}
+
+	db := currentDB(c)
+	if db == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No active DB connection"})
+		return
+	}
+
+	resp, err := db.ExecuteBatch(c.Request.Context(), req)
+	if err != nil {
+		dbError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ImportCSVHandler loads an uploaded CSV file into an existing table. The
+// multipart form carries the file as "file" next to "table", "schema" and
+// the model.ImportOptions fields, with "mapping" as a JSON object.
+func ImportCSVHandler(c *gin.Context) {
+	table := c.PostForm("table")
+	header, err := c.FormFile("file")
+	if table == "" || err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing table or file"})
+		return
+	}
+
+	opts := model.ImportOptions{
+		Delimiter: c.PostForm("delimiter"),
+		Encoding:  c.PostForm("encoding"),
+		Null:      c.PostForm("null"),
+	}
+	if opts.Delimiter == `\t` || opts.Delimiter == "tab" {
+		opts.Delimiter = "\t"
+	}
+	if s := c.PostForm("header"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid header, expected true or false"})
+			return
+		}
+		opts.Header = &b
+	}
+	if s := c.PostForm("mapping"); s != "" {
+		if err := json.Unmarshal([]byte(s), &opts.Mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mapping, expected a JSON object"})
+			return
+		}
+	}
+
+	db := currentDB(c)
+	if db == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No active DB connection"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot read file: " + err.Error()})
+		return
+	}
+	defer file.Close()
+
+	resp, err := db.ImportCSV(c.Request.Context(), helper.QualifiedName{Schema: c.PostForm("schema"), Name: table}, file, opts)
+	if err != nil {
+		dbError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
 }
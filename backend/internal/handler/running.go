@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+	"vind/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queryIDHeader is set on /query responses so the client can cancel the
+// statement with DELETE /query/:id while it is still running. Clients that
+// need the ID before any response arrives choose it themselves by sending
+// the header with the request.
+const queryIDHeader = "X-Query-ID"
+
+// queryIDPattern is what a client-chosen query ID may look like.
+var queryIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+var errQueryIDInUse = errors.New("Query ID is already in use")
+
+// cancelTimeout bounds the server-side cancel request sent by CancelQueryHandler.
+const cancelTimeout = 5 * time.Second
+
+// runningQuery is a /query execution that has not returned yet.
+type runningQuery struct {
+	ID           string    `json:"id"`
+	ConnectionID string    `json:"connection_id"`
+	SQL          string    `json:"sql"`
+	StartedAt    time.Time `json:"started_at"`
+	BackendID    int64     `json:"backend_id,omitempty"`
+	ElapsedMS    int64     `json:"elapsed_ms"`
+
+	client    service.DBClient
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+type queryRegistry struct {
+	mu      sync.Mutex
+	queries map[string]*runningQuery
+}
+
+var runningQueries = &queryRegistry{queries: map[string]*runningQuery{}}
+
+// start registers a query under id, or a new ID if it is empty, and
+// returns a context that is cancelled by DELETE /query/:id and that records
+// the server-side ID the client reports. It fails with errQueryIDInUse if
+// another running query has the same ID.
+func (r *queryRegistry) start(ctx context.Context, connID, id, sql string, client service.DBClient) (context.Context, *runningQuery, error) {
+	if id == "" {
+		var err error
+		if id, err = newConnectionID(); err != nil {
+			return nil, nil, err
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.queries[id]; ok {
+		return nil, nil, errQueryIDInUse
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	q := &runningQuery{
+		ID:           id,
+		ConnectionID: connID,
+		SQL:          sql,
+		StartedAt:    time.Now().UTC(),
+		client:       client,
+		cancel:       cancel,
+	}
+	ctx = service.WithBackendIDReporter(ctx, func(id int64) {
+		r.mu.Lock()
+		q.BackendID = id
+		r.mu.Unlock()
+	})
+	r.queries[q.ID] = q
+	return ctx, q, nil
+}
+
+// startQuery registers sql as running on the current connection under the
+// ID sent in the request's X-Query-ID header, or a new one, and sets the
+// header on the response. It writes a 400 for a malformed ID, a 409 for one
+// that is in use or a 500 if no ID can be generated, and returns false.
+func startQuery(c *gin.Context, sql string, db service.DBClient) (context.Context, *runningQuery, bool) {
+	id := c.GetHeader(queryIDHeader)
+	if id != "" && !queryIDPattern.MatchString(id) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query ID; use up to 64 letters, digits, '.', '_' or '-'"})
+		return nil, nil, false
+	}
+	ctx, running, err := runningQueries.start(c.Request.Context(), connectionID(c), id, sql, db)
+	if errors.Is(err, errQueryIDInUse) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "query_id": id})
+		return nil, nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+	c.Header(queryIDHeader, running.ID)
+	return ctx, running, true
+}
+
+// finish unregisters q and reports whether it was cancelled by a user.
+func (r *queryRegistry) finish(q *runningQuery) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.queries, q.ID)
+	q.cancel()
+	return q.cancelled
+}
+
+// markCancelled flags the query as cancelled and returns its client and
+// backend ID, or false when no such query is running.
+func (r *queryRegistry) markCancelled(id string) (*runningQuery, int64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	q, ok := r.queries[id]
+	if !ok {
+		return nil, 0, false
+	}
+	q.cancelled = true
+	return q, q.BackendID, true
+}
+
+func (r *queryRegistry) list() []runningQuery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	list := make([]runningQuery, 0, len(r.queries))
+	for _, q := range r.queries {
+		entry := *q
+		entry.ElapsedMS = now.Sub(q.StartedAt).Milliseconds()
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartedAt.Before(list[j].StartedAt) })
+	return list
+}
+
+func ListRunningQueriesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"queries": runningQueries.list()})
+}
+
+// CancelQueryHandler stops a running query. Servers that expose a backend ID
+// are asked to cancel the statement first so the connection stays usable;
+// the query's context is cancelled either way.
+func CancelQueryHandler(c *gin.Context) {
+	id := c.Param("id")
+	q, backendID, ok := runningQueries.markCancelled(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Query not found"})
+		return
+	}
+
+	var cancelErr error
+	if backendID != 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+		cancelErr = q.client.CancelQuery(ctx, backendID)
+		cancel()
+	}
+	q.cancel()
+
+	if cancelErr != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Query cancelled", "query_id": id, "warning": "Server-side cancel failed: " + cancelErr.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Query cancelled", "query_id": id})
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"vind/backend/internal/model"
+	"vind/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingQueryMock reports a backend ID like the Postgres client does and
+// then blocks until its context is cancelled.
+type blockingQueryMock struct {
+	mockDBClient
+	started chan struct{}
+}
+
+func (m *blockingQueryMock) ExecuteQuery(ctx context.Context, query string, args ...any) (*model.QueryResponse, error) {
+	service.ReportBackendID(ctx, 42)
+	close(m.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCancelQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/query", QueryHandler)
+	r.GET("/query/running", ListRunningQueriesHandler)
+	r.DELETE("/query/:id", CancelQueryHandler)
+
+	var cancelledBackend int64
+	db := &blockingQueryMock{started: make(chan struct{})}
+	db.cancelQueryFunc = func(backendID int64) error {
+		cancelledBackend = backendID
+		return nil
+	}
+
+	queryReq, _ := http.NewRequest("POST", "/query", bytes.NewBufferString(`{"sql": "SELECT pg_sleep(60)"}`))
+	queryReq.Header.Set("Content-Type", "application/json")
+	useDB(t, queryReq, db)
+
+	queryResp := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		r.ServeHTTP(queryResp, queryReq)
+		close(done)
+	}()
+
+	select {
+	case <-db.started:
+	case <-time.After(time.Second):
+		t.Fatal("query did not start")
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/query/running", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var running struct {
+		Queries []runningQuery `json:"queries"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &running))
+	require.Len(t, running.Queries, 1)
+	assert.Equal(t, "SELECT pg_sleep(60)", running.Queries[0].SQL)
+	assert.Equal(t, int64(42), running.Queries[0].BackendID)
+	id := running.Queries[0].ID
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/query/"+id, nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"message":"Query cancelled","query_id":"`+id+`"}`, w.Body.String())
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("query was not cancelled")
+	}
+	assert.Equal(t, int64(42), cancelledBackend)
+	assert.Equal(t, http.StatusConflict, queryResp.Code)
+	assert.Equal(t, id, queryResp.Header().Get(queryIDHeader))
+	assert.Contains(t, queryResp.Body.String(), `"error":"Query was cancelled"`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/query/running", nil)
+	r.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"queries":[]}`, w.Body.String())
+}
+
+func TestCancelQueryNotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.DELETE("/query/:id", CancelQueryHandler)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/query/unknown", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":"Query not found"}`, w.Body.String())
+}
+
+func TestCancelQueryClientID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/query", QueryHandler)
+	r.DELETE("/query/:id", CancelQueryHandler)
+
+	newQuery := func(db service.DBClient, id string) *http.Request {
+		req, _ := http.NewRequest("POST", "/query", bytes.NewBufferString(`{"sql": "SELECT pg_sleep(60)"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(queryIDHeader, id)
+		useDB(t, req, db)
+		return req
+	}
+
+	db := &blockingQueryMock{started: make(chan struct{})}
+	queryResp := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		r.ServeHTTP(queryResp, newQuery(db, "report-7"))
+		close(done)
+	}()
+
+	select {
+	case <-db.started:
+	case <-time.After(time.Second):
+		t.Fatal("query did not start")
+	}
+
+	// The ID is known before the query returns anything.
+	running := runningQueries.list()
+	require.Len(t, running, 1)
+	assert.Equal(t, "report-7", running[0].ID)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newQuery(&mockDBClient{}, "report-7"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"error":"Query ID is already in use","query_id":"report-7"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/query/report-7", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("query was not cancelled")
+	}
+	assert.Equal(t, http.StatusConflict, queryResp.Code)
+	assert.Equal(t, "report-7", queryResp.Header().Get(queryIDHeader))
+	assert.Empty(t, runningQueries.list())
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, newQuery(&mockDBClient{}, "no spaces"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, w.Header().Get(queryIDHeader))
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"vind/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryHandlerScripts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name               string
+		query              string
+		body               string
+		expectedStatements []string
+		expectedOpts       model.ScriptOptions
+		expectedCode       int
+		expectedBody       string
+	}{
+		{
+			name:               "several statements",
+			body:               `{"sql": "INSERT INTO t VALUES ('a;b'); SELECT * FROM t;", "stop_on_error": true}`,
+			expectedStatements: []string{"INSERT INTO t VALUES ('a;b')", "SELECT * FROM t"},
+			expectedOpts:       model.ScriptOptions{StopOnError: true},
+			expectedCode:       http.StatusOK,
+			expectedBody:       `{"results":[{"sql":"INSERT INTO t VALUES ('a;b')","duration_ms":0}]}`,
+		},
+		{
+			name:               "single statement in a transaction",
+			body:               `{"sql": "DELETE FROM t", "transaction": true}`,
+			expectedStatements: []string{"DELETE FROM t"},
+			expectedOpts:       model.ScriptOptions{Transaction: true},
+			expectedCode:       http.StatusOK,
+			expectedBody:       `{"results":[{"sql":"DELETE FROM t","duration_ms":0}]}`,
+		},
+		{
+			name:         "streaming a script",
+			query:        "?stream=ndjson",
+			body:         `{"sql": "SELECT 1; SELECT 2"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Streaming is not supported for multi-statement scripts"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := gin.New()
+			r.POST("/query", QueryHandler)
+
+			db := &mockDBClient{
+				executeScriptFunc: func(statements []string, opts model.ScriptOptions) (*model.ScriptResponse, error) {
+					assert.Equal(t, tc.expectedStatements, statements)
+					assert.Equal(t, tc.expectedOpts, opts)
+					return &model.ScriptResponse{Results: []model.StatementResult{{SQL: statements[0]}}}, nil
+				},
+			}
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/query"+tc.query, bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			useDB(t, req, db)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
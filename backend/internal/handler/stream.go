@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"vind/backend/internal/model"
+	"vind/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	streamNDJSON = "ndjson"
+	streamSSE    = "sse"
+
+	ndjsonContentType = "application/x-ndjson"
+	sseContentType    = "text/event-stream"
+
+	// streamFlushEvery is how many rows are written between flushes. Writes
+	// block once the client stops reading, which throttles the row scan.
+	streamFlushEvery = 100
+)
+
+// streamRowLimit is the most rows a single streamed response may contain.
+var streamRowLimit = 1_000_000
+
+// SetStreamRowLimit changes the hard cap on rows per streamed response.
+func SetStreamRowLimit(n int) {
+	streamRowLimit = n
+}
+
+var errInvalidStream = errors.New("Invalid stream format")
+
+// streamFormat reports which streaming format the request asked for, either
+// with ?stream=ndjson|sse or through its Accept header. It returns "" for a
+// regular buffered JSON response.
+func streamFormat(c *gin.Context) (string, error) {
+	switch strings.ToLower(c.Query("stream")) {
+	case "":
+	case streamNDJSON:
+		return streamNDJSON, nil
+	case streamSSE:
+		return streamSSE, nil
+	default:
+		return "", errInvalidStream
+	}
+
+	accept := c.GetHeader("Accept")
+	switch {
+	case strings.Contains(accept, ndjsonContentType):
+		return streamNDJSON, nil
+	case strings.Contains(accept, sseContentType):
+		return streamSSE, nil
+	}
+	return "", nil
+}
+
+// newStreamWriter returns nil when the request did not ask for streaming,
+// and writes a 400 and returns false when its stream options are invalid.
+func newStreamWriter(c *gin.Context) (*streamWriter, bool) {
+	format, err := streamFormat(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if format == "" {
+		return nil, true
+	}
+
+	limit := streamRowLimit
+	if val := c.Query("max_rows"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid max_rows"})
+			return nil, false
+		}
+		if n < limit {
+			limit = n
+		}
+	}
+
+	return &streamWriter{c: c, format: format, limit: limit}, true
+}
+
+// streamWriter is a service.RowWriter that sends a result set to the client
+// as it is scanned. NDJSON responses carry a {"columns": [...], "fields":
+// [...]} line, one JSON array per row and a closing {"done": true, ...} or
+// {"error": ...} line; SSE responses send the same payloads as columns, row,
+// done and error events. Nothing is written until the columns arrive, so
+// errors raised before then can still be reported with a regular status
+// code. The first row lifts the statement timeout; the row limit bounds the
+// rest.
+type streamWriter struct {
+	c         *gin.Context
+	format    string
+	limit     int
+	rows      int
+	started   bool
+	truncated bool
+	summary   gin.H // extra fields for the done payload
+}
+
+func (s *streamWriter) WriteColumns(columns []model.ColumnMeta) error {
+	w := s.c.Writer
+	if s.format == streamSSE {
+		w.Header().Set("Content-Type", sseContentType)
+	} else {
+		w.Header().Set("Content-Type", ndjsonContentType)
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	s.started = true
+
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+	}
+	if err := s.emit("columns", gin.H{"columns": names, "fields": columns}); err != nil {
+		return err
+	}
+	w.Flush()
+	return nil
+}
+
+func (s *streamWriter) WriteRow(values []any) error {
+	if s.rows >= s.limit {
+		s.truncated = true
+		return service.ErrRowLimit
+	}
+	if s.rows == 0 {
+		liftStatementTimeout(s.c)
+	}
+	if err := s.emit("row", values); err != nil {
+		return err
+	}
+	s.rows++
+	if s.rows%streamFlushEvery == 0 {
+		s.c.Writer.Flush()
+	}
+	return nil
+}
+
+// end closes the stream after the scan returned err. Hitting the row limit
+// is not an error; the done payload reports it as truncated instead.
+func (s *streamWriter) end(err error) {
+	if err != nil && !errors.Is(err, service.ErrRowLimit) {
+		_, msg := dbErrorStatus(s.c, err)
+		s.finish(msg)
+		return
+	}
+	s.finish("")
+}
+
+// finish writes the closing line or event. errMsg is empty on success.
+func (s *streamWriter) finish(errMsg string) {
+	if errMsg != "" {
+		s.emit("error", gin.H{"error": errMsg})
+	} else {
+		done := gin.H{"done": true, "row_count": s.rows, "truncated": s.truncated}
+		for k, v := range s.summary {
+			done[k] = v
+		}
+		s.emit("done", done)
+	}
+	s.c.Writer.Flush()
+}
+
+// pageSummary returns the cursors and row count of a streamed table-data page.
+func pageSummary(resp *model.TableDataResponse) gin.H {
+	summary := gin.H{}
+	if resp.Total != nil {
+		summary["total"] = *resp.Total
+		summary["total_kind"] = resp.TotalKind
+	}
+	if resp.NextCursor != "" {
+		summary["next_cursor"] = resp.NextCursor
+	}
+	if resp.PrevCursor != "" {
+		summary["prev_cursor"] = resp.PrevCursor
+	}
+	return summary
+}
+
+func (s *streamWriter) emit(event string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if s.format == streamSSE {
+		_, err = fmt.Fprintf(s.c.Writer, "event: %s\ndata: %s\n\n", event, data)
+	} else {
+		_, err = fmt.Fprintf(s.c.Writer, "%s\n", data)
+	}
+	return err
+}
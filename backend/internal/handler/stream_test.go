@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"vind/backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryHandlerStreaming(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	threeRows := func(query string) (*model.QueryResponse, error) {
+		return &model.QueryResponse{Columns: []string{"id", "name"}, Rows: [][]any{{1, "a"}, {2, "b"}, {3, "c"}}}, nil
+	}
+
+	tests := []struct {
+		name                string
+		query               string
+		accept              string
+		mockFunc            func(query string) (*model.QueryResponse, error)
+		expectedCode        int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "ndjson",
+			query:               "?stream=ndjson",
+			mockFunc:            threeRows,
+			expectedCode:        http.StatusOK,
+			expectedContentType: ndjsonContentType,
+			expectedBody: `{"columns":["id","name"],"fields":[{"name":"id","type":""},{"name":"name","type":""}]}
+[1,"a"]
+[2,"b"]
+[3,"c"]
+{"done":true,"row_count":3,"truncated":false}
+`,
+		},
+		{
+			name:                "sse from accept header",
+			accept:              sseContentType,
+			mockFunc:            threeRows,
+			expectedCode:        http.StatusOK,
+			expectedContentType: sseContentType,
+			expectedBody: "event: columns\ndata: {\"columns\":[\"id\",\"name\"],\"fields\":[{\"name\":\"id\",\"type\":\"\"},{\"name\":\"name\",\"type\":\"\"}]}\n\n" +
+				"event: row\ndata: [1,\"a\"]\n\n" +
+				"event: row\ndata: [2,\"b\"]\n\n" +
+				"event: row\ndata: [3,\"c\"]\n\n" +
+				"event: done\ndata: {\"done\":true,\"row_count\":3,\"truncated\":false}\n\n",
+		},
+		{
+			name:                "row cap",
+			query:               "?stream=ndjson&max_rows=2",
+			mockFunc:            threeRows,
+			expectedCode:        http.StatusOK,
+			expectedContentType: ndjsonContentType,
+			expectedBody: `{"columns":["id","name"],"fields":[{"name":"id","type":""},{"name":"name","type":""}]}
+[1,"a"]
+[2,"b"]
+{"done":true,"row_count":2,"truncated":true}
+`,
+		},
+		{
+			name:  "error before columns",
+			query: "?stream=ndjson",
+			mockFunc: func(query string) (*model.QueryResponse, error) {
+				return nil, errors.New("syntax error")
+			},
+			expectedCode:        http.StatusInternalServerError,
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"syntax error"}`,
+		},
+		{
+			name:                "statement without result set",
+			query:               "?stream=ndjson",
+			mockFunc:            func(query string) (*model.QueryResponse, error) { return &model.QueryResponse{Command: "CREATE"}, nil },
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `{"command":"CREATE","message":"Query executed successfully"}`,
+		},
+		{
+			name:                "invalid format",
+			query:               "?stream=xml",
+			mockFunc:            threeRows,
+			expectedCode:        http.StatusBadRequest,
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"Invalid stream format"}`,
+		},
+		{
+			name:                "invalid max_rows",
+			query:               "?stream=ndjson&max_rows=0",
+			mockFunc:            threeRows,
+			expectedCode:        http.StatusBadRequest,
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"Invalid max_rows"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := gin.New()
+			r.POST("/query", QueryHandler)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/query"+tc.query, bytes.NewBufferString(`{"sql": "SELECT * FROM users"}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			useDB(t, req, &mockDBClient{executeQueryFunc: tc.mockFunc})
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Contains(t, w.Header().Get("Content-Type"), tc.expectedContentType)
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestTableDataHandlerStreaming(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	old := streamRowLimit
+	SetStreamRowLimit(1)
+	t.Cleanup(func() { SetStreamRowLimit(old) })
+
+	r := gin.New()
+	r.GET("/records", TableDataHandler)
+
+	db := &mockDBClient{
+		getTableDataFunc: func(req model.TableDataRequest) (*model.TableDataResponse, error) {
+			return &model.TableDataResponse{Columns: []string{"id"}, Rows: [][]any{{1}, {2}}}, nil
+		},
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/records?table=users&stream=ndjson&max_rows=5", nil)
+	useDB(t, req, db)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "{\"columns\":[\"id\"],\"fields\":[{\"name\":\"id\",\"type\":\"\"}]}\n[1]\n{\"done\":true,\"row_count\":1,\"truncated\":true}\n", w.Body.String())
+}
+
+func TestTableDataHandlerStreamingCursor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.GET("/records", TableDataHandler)
+
+	var got model.TableDataRequest
+	db := &mockDBClient{
+		getTableDataFunc: func(req model.TableDataRequest) (*model.TableDataResponse, error) {
+			got = req
+			return &model.TableDataResponse{Columns: []string{"id"}, Rows: [][]any{{3}}, NextCursor: "next", PrevCursor: "prev"}, nil
+		},
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/records?table=users&cursor=abc&stream=ndjson", nil)
+	useDB(t, req, db)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "abc", got.Cursor)
+	assert.Equal(t, "{\"columns\":[\"id\"],\"fields\":[{\"name\":\"id\",\"type\":\"\"}]}\n[3]\n"+
+		"{\"done\":true,\"next_cursor\":\"next\",\"prev_cursor\":\"prev\",\"row_count\":1,\"truncated\":false}\n", w.Body.String())
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"vind/backend/helper"
+	"vind/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	timeoutKey      = "statement_timeout"
+	timeoutTimerKey = "statement_timeout_timer"
+)
+
+// parseTimeout accepts a Go duration ("500ms", "2m") or a number of seconds.
+func parseTimeout(s string) (time.Duration, error) {
+	if secs, err := strconv.Atoi(s); err == nil {
+		return time.Duration(secs) * time.Second, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// StatementTimeout bounds every request's context so that database calls are
+// cancelled once it expires or the client goes away. Callers may pass a
+// ?timeout= parameter to override defaultTimeout, up to maxTimeout. Streamed
+// responses lift the timeout once their first row is read, see
+// liftStatementTimeout.
+func StatementTimeout(defaultTimeout, maxTimeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := defaultTimeout
+		if val := c.Query("timeout"); val != "" {
+			parsed, err := parseTimeout(val)
+			if err != nil || parsed <= 0 {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid timeout"})
+				return
+			}
+			timeout = parsed
+		}
+		if maxTimeout > 0 && timeout > maxTimeout {
+			timeout = maxTimeout
+		}
+
+		// A timer rather than a deadline, so that it can be stopped.
+		ctx, cancel := context.WithCancelCause(c.Request.Context())
+		timer := time.AfterFunc(timeout, func() { cancel(context.DeadlineExceeded) })
+		defer cancel(nil)
+		defer timer.Stop()
+
+		c.Set(timeoutKey, timeout)
+		c.Set(timeoutTimerKey, timer)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// liftStatementTimeout stops the request's statement timeout, leaving its
+// context to be cancelled only by the client going away or DELETE
+// /query/:id. Streams and exports call it once their first row arrives:
+// the timeout guards how long a statement takes to start returning rows,
+// and STREAM_MAX_ROWS, not the clock, bounds how many it may send.
+func liftStatementTimeout(c *gin.Context) {
+	if timer, ok := c.Get(timeoutTimerKey); ok {
+		timer.(*time.Timer).Stop()
+	}
+}
+
+// dbError writes err as a 500, or as a 504 when the request's statement
+// timeout has expired, in which case drivers report a less helpful
+// cancellation error. Errors caused by the request itself, such as invalid
+// filters, unusable names or unknown columns, are reported as a 400,
+// missing rows and tables as a 404 and edits of rows changed in the
+// meantime as a 409.
+func dbError(c *gin.Context, err error) {
+	code, msg := dbErrorStatus(c, err)
+	c.JSON(code, gin.H{"error": msg})
+}
+
+func dbErrorStatus(c *gin.Context, err error) (int, string) {
+	var filterErr *helper.FilterError
+	var orderErr *helper.OrderByError
+	var identErr *helper.IdentifierError
+	switch {
+	case errors.As(err, &filterErr), errors.As(err, &orderErr), errors.As(err, &identErr),
+		errors.Is(err, service.ErrInvalidCursor), errors.Is(err, service.ErrUnknownColumn),
+		errors.Is(err, service.ErrNoPrimaryKey), errors.Is(err, service.ErrInvalidKey),
+		errors.Is(err, service.ErrNoRowVersion), errors.Is(err, service.ErrUnknownConstraint),
+		errors.Is(err, service.ErrAmbiguousUpsert),
+		errors.Is(err, service.ErrInvalidImport):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, service.ErrRowNotFound), errors.Is(err, service.ErrTableNotFound):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, service.ErrRowConflict), errors.Is(err, service.ErrAmbiguousRow):
+		return http.StatusConflict, err.Error()
+	}
+	if errors.Is(context.Cause(c.Request.Context()), context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout, fmt.Sprintf("Query timed out after %s", c.GetDuration(timeoutKey))
+	}
+	return http.StatusInternalServerError, err.Error()
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"vind/backend/internal/model"
+	"vind/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// slowQueryMock blocks until the request context is done, like a driver
+// whose statement gets cancelled.
+type slowQueryMock struct {
+	mockDBClient
+}
+
+func (m *slowQueryMock) ExecuteQuery(ctx context.Context, query string, args ...any) (*model.QueryResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestStatementTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "invalid timeout",
+			query:        "?timeout=soon",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid timeout"}`,
+		},
+		{
+			name:         "negative timeout",
+			query:        "?timeout=-1",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid timeout"}`,
+		},
+		{
+			name:         "default timeout",
+			query:        "",
+			expectedCode: http.StatusGatewayTimeout,
+			expectedBody: `{"error":"Query timed out after 20ms"}`,
+		},
+		{
+			name:         "timeout override",
+			query:        "?timeout=10ms",
+			expectedCode: http.StatusGatewayTimeout,
+			expectedBody: `{"error":"Query timed out after 10ms"}`,
+		},
+		{
+			name:         "timeout capped at maximum",
+			query:        "?timeout=1h",
+			expectedCode: http.StatusGatewayTimeout,
+			expectedBody: `{"error":"Query timed out after 50ms"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(StatementTimeout(20*time.Millisecond, 50*time.Millisecond))
+			r.POST("/query", QueryHandler)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/query"+tc.query, bytes.NewBufferString(`{"sql": "SELECT pg_sleep(60)"}`))
+			req.Header.Set("Content-Type", "application/json")
+			useDB(t, req, &slowQueryMock{})
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tc.expectedBody)
+		})
+	}
+}
+
+// slowRowsMock streams two rows, waiting firstRow before the first and
+// between longer than the statement timeout before the second.
+type slowRowsMock struct {
+	mockDBClient
+	firstRow, between time.Duration
+}
+
+func (m *slowRowsMock) StreamQuery(ctx context.Context, query string, w service.RowWriter, args ...any) (*model.QueryResponse, error) {
+	if err := w.WriteColumns([]model.ColumnMeta{{Name: "n"}}); err != nil {
+		return nil, err
+	}
+	for i, wait := range []time.Duration{m.firstRow, m.between} {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		if err := w.WriteRow([]any{i + 1}); err != nil {
+			return nil, err
+		}
+	}
+	return &model.QueryResponse{}, nil
+}
+
+func TestStatementTimeoutStreaming(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		firstRow     time.Duration
+		expectedBody string
+	}{
+		{
+			name:         "lifted at the first row",
+			expectedBody: "[1]\n[2]\n{\"done\":true,\"row_count\":2,\"truncated\":false}\n",
+		},
+		{
+			name:         "first row too late",
+			firstRow:     time.Second,
+			expectedBody: "{\"error\":\"Query timed out after 20ms\"}\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(StatementTimeout(20*time.Millisecond, time.Minute))
+			r.POST("/query", QueryHandler)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/query?stream=ndjson", bytes.NewBufferString(`{"sql": "SELECT n FROM big"}`))
+			req.Header.Set("Content-Type", "application/json")
+			useDB(t, req, &slowRowsMock{firstRow: tc.firstRow, between: 60 * time.Millisecond})
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.True(t, strings.HasSuffix(w.Body.String(), tc.expectedBody), w.Body.String())
+		})
+	}
+}
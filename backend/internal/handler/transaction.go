@@ -0,0 +1,276 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"vind/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// transactionHeader routes a request to an open transaction instead of the
+// connection pool. The transaction_id query parameter is accepted as well.
+const transactionHeader = "X-Transaction-ID"
+
+const transactionKey = "transaction"
+
+// transactionIdleTimeout is how long a transaction may go unused before it
+// is rolled back automatically.
+var transactionIdleTimeout = 5 * time.Minute
+
+// SetTransactionIdleTimeout changes the idle timeout of transactions begun afterwards.
+func SetTransactionIdleTimeout(d time.Duration) {
+	transactionIdleTimeout = d
+}
+
+// txSession is a transaction opened with POST /transactions. Its mutex is
+// held by the request using it, so requests on one transaction run one at a
+// time, and its timer rolls it back once it has been idle for too long.
+type txSession struct {
+	ID           string     `json:"id"`
+	ConnectionID string     `json:"connection_id"`
+	StartedAt    time.Time  `json:"started_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	Tx           service.Tx `json:"-"`
+
+	mu     sync.Mutex
+	closed bool
+	idle   time.Duration
+	timer  *time.Timer
+	cancel context.CancelFunc
+}
+
+type txRegistry struct {
+	mu       sync.Mutex
+	sessions map[string]*txSession
+}
+
+var transactions = &txRegistry{sessions: map[string]*txSession{}}
+
+// begin opens a transaction on client. The transaction gets its own
+// context, since cancelling the one it was begun with would roll it back.
+func (r *txRegistry) begin(ctx context.Context, connID string, client service.DBClient) (*txSession, error) {
+	id, err := newConnectionID()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	tx, err := client.BeginTx(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	s := &txSession{
+		ID:           id,
+		ConnectionID: connID,
+		StartedAt:    now,
+		ExpiresAt:    now.Add(transactionIdleTimeout),
+		Tx:           tx,
+		idle:         transactionIdleTimeout,
+		cancel:       cancel,
+	}
+	s.timer = time.AfterFunc(s.idle, func() { r.expire(s.ID) })
+
+	r.mu.Lock()
+	r.sessions[s.ID] = s
+	r.mu.Unlock()
+	return s, nil
+}
+
+// acquire waits for exclusive use of the session and stops its idle timer.
+// It returns false if the session does not exist or has ended meanwhile.
+func (r *txRegistry) acquire(id string) (*txSession, bool) {
+	r.mu.Lock()
+	s, ok := r.sessions[id]
+	r.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, false
+	}
+	s.timer.Stop()
+	return s, true
+}
+
+// release gives up a session obtained with acquire and restarts its idle timer.
+func (r *txRegistry) release(s *txSession) {
+	if !s.closed {
+		r.mu.Lock()
+		s.ExpiresAt = time.Now().UTC().Add(s.idle)
+		r.mu.Unlock()
+		s.timer.Reset(s.idle)
+	}
+	s.mu.Unlock()
+}
+
+// finish commits or rolls back an acquired session and unregisters it.
+func (r *txRegistry) finish(s *txSession, commit bool) error {
+	r.mu.Lock()
+	delete(r.sessions, s.ID)
+	r.mu.Unlock()
+
+	s.closed = true
+	defer s.cancel()
+	if commit {
+		return s.Tx.Commit()
+	}
+	return s.Tx.Rollback()
+}
+
+// expire rolls back a session whose idle timer fired, unless it was used
+// again while the timer was firing.
+func (r *txRegistry) expire(id string) {
+	s, ok := r.acquire(id)
+	if !ok {
+		return
+	}
+	defer r.release(s)
+
+	if time.Now().Before(s.ExpiresAt) {
+		return
+	}
+	r.finish(s, false)
+}
+
+// rollbackConnection rolls back every transaction open on a connection.
+func (r *txRegistry) rollbackConnection(connID string) {
+	r.mu.Lock()
+	var ids []string
+	for id, s := range r.sessions {
+		if s.ConnectionID == connID {
+			ids = append(ids, id)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, id := range ids {
+		if s, ok := r.acquire(id); ok {
+			r.finish(s, false)
+			r.release(s)
+		}
+	}
+}
+
+func (r *txRegistry) list() []txSession {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]txSession, 0, len(r.sessions))
+	for _, s := range r.sessions {
+		list = append(list, txSession{
+			ID:           s.ID,
+			ConnectionID: s.ConnectionID,
+			StartedAt:    s.StartedAt,
+			ExpiresAt:    s.ExpiresAt,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartedAt.Before(list[j].StartedAt) })
+	return list
+}
+
+// transactionID returns the transaction ID the request refers to, if any.
+func transactionID(c *gin.Context) string {
+	if id := c.GetHeader(transactionHeader); id != "" {
+		return id
+	}
+	return c.Query("transaction_id")
+}
+
+// TransactionSession binds requests that name a transaction to it for their
+// whole duration, so that currentDB resolves to the transaction's client.
+func TransactionSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := transactionID(c)
+		if id == "" {
+			c.Next()
+			return
+		}
+
+		s, ok := transactions.acquire(id)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+			return
+		}
+		defer transactions.release(s)
+
+		c.Set(transactionKey, s)
+		c.Next()
+	}
+}
+
+// currentTransaction returns the session bound by TransactionSession, if any.
+func currentTransaction(c *gin.Context) *txSession {
+	if v, ok := c.Get(transactionKey); ok {
+		return v.(*txSession)
+	}
+	return nil
+}
+
+func ListTransactionsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"transactions": transactions.list()})
+}
+
+func BeginTransactionHandler(c *gin.Context) {
+	if currentTransaction(c) != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "A transaction is already open"})
+		return
+	}
+	db := currentDB(c)
+	if db == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No active database connection"})
+		return
+	}
+
+	s, err := transactions.begin(c.Request.Context(), connectionID(c), db)
+	if err != nil {
+		dbError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":        "Transaction started",
+		"transaction_id": s.ID,
+		"expires_at":     s.ExpiresAt,
+	})
+}
+
+func CommitTransactionHandler(c *gin.Context) {
+	endTransaction(c, true)
+}
+
+func RollbackTransactionHandler(c *gin.Context) {
+	endTransaction(c, false)
+}
+
+func endTransaction(c *gin.Context, commit bool) {
+	id := c.Param("id")
+	s := currentTransaction(c)
+	if s == nil || s.ID != id {
+		var ok bool
+		if s, ok = transactions.acquire(id); !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+			return
+		}
+		defer transactions.release(s)
+	}
+
+	if err := transactions.finish(s, commit); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	msg := "Transaction rolled back"
+	if commit {
+		msg = "Transaction committed"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": msg, "transaction_id": id})
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"vind/backend/internal/model"
+	"vind/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockTx is a transaction-bound client that records how it ended.
+type mockTx struct {
+	mockDBClient
+	committed  atomic.Bool
+	rolledBack atomic.Bool
+}
+
+func (m *mockTx) Commit() error {
+	m.committed.Store(true)
+	return nil
+}
+
+func (m *mockTx) Rollback() error {
+	m.rolledBack.Store(true)
+	return nil
+}
+
+func transactionRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(TransactionSession())
+	r.POST("/query", QueryHandler)
+	r.GET("/transactions", ListTransactionsHandler)
+	r.POST("/transactions", BeginTransactionHandler)
+	r.POST("/transactions/:id/commit", CommitTransactionHandler)
+	r.POST("/transactions/:id/rollback", RollbackTransactionHandler)
+	r.DELETE("/connections/:id", CloseConnectionHandler)
+	return r
+}
+
+// beginTransaction opens a transaction on db through the API and returns its ID.
+func beginTransaction(t *testing.T, r *gin.Engine, db service.DBClient) (txID, connID string) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/transactions", nil)
+	useDB(t, req, db)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var resp struct {
+		TransactionID string `json:"transaction_id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.TransactionID, req.Header.Get(connectionHeader)
+}
+
+func TestTransactionLifecycle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := transactionRouter()
+
+	tx := &mockTx{}
+	tx.executeQueryFunc = func(query string) (*model.QueryResponse, error) {
+		return &model.QueryResponse{Columns: []string{"in_tx"}, Rows: [][]any{{true}}}, nil
+	}
+	db := &mockDBClient{
+		beginTxFunc: func() (service.Tx, error) { return tx, nil },
+		executeQueryFunc: func(query string) (*model.QueryResponse, error) {
+			return &model.QueryResponse{Columns: []string{"in_tx"}, Rows: [][]any{{false}}}, nil
+		},
+	}
+	id, _ := beginTransaction(t, r, db)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/query", bytes.NewBufferString(`{"sql": "SELECT 1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(transactionHeader, id)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"columns":["in_tx"],"rows":[[true]]}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/transactions", nil)
+	r.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"id":"`+id+`"`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/transactions/"+id+"/commit", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"message":"Transaction committed","transaction_id":"`+id+`"}`, w.Body.String())
+	assert.True(t, tx.committed.Load())
+	assert.False(t, tx.rolledBack.Load())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/query", bytes.NewBufferString(`{"sql": "SELECT 1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(transactionHeader, id)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":"Transaction not found"}`, w.Body.String())
+}
+
+func TestTransactionRollbackWithHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := transactionRouter()
+
+	tx := &mockTx{}
+	id, _ := beginTransaction(t, r, &mockDBClient{beginTxFunc: func() (service.Tx, error) { return tx, nil }})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/transactions/"+id+"/rollback", nil)
+	req.Header.Set(transactionHeader, id)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"message":"Transaction rolled back","transaction_id":"`+id+`"}`, w.Body.String())
+	assert.True(t, tx.rolledBack.Load())
+}
+
+func TestTransactionIdleTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	old := transactionIdleTimeout
+	SetTransactionIdleTimeout(20 * time.Millisecond)
+	t.Cleanup(func() { SetTransactionIdleTimeout(old) })
+	r := transactionRouter()
+
+	tx := &mockTx{}
+	id, _ := beginTransaction(t, r, &mockDBClient{beginTxFunc: func() (service.Tx, error) { return tx, nil }})
+
+	assert.Eventually(t, tx.rolledBack.Load, time.Second, 5*time.Millisecond)
+	_, ok := transactions.acquire(id)
+	assert.False(t, ok)
+}
+
+func TestTransactionRolledBackWhenConnectionCloses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := transactionRouter()
+
+	tx := &mockTx{}
+	_, connID := beginTransaction(t, r, &mockDBClient{beginTxFunc: func() (service.Tx, error) { return tx, nil }})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/connections/"+connID, nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, tx.rolledBack.Load())
+}
+
+func TestBeginTransactionErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := transactionRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/transactions", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"No active database connection"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/transactions/unknown/commit", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":"Transaction not found"}`, w.Body.String())
+}
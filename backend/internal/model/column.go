@@ -8,3 +8,21 @@ type Column struct {
 	IsUnique   bool   `json:"is_unique"`
 	ForeignKey string `json:"foreign_key"`
 }
+
+// ColumnMeta describes a column of a query result. Properties the driver
+// does not report are omitted.
+type ColumnMeta struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`              // database type name, e.g. "NUMERIC" or "_INT4"
+	Binary    bool   `json:"binary,omitempty"`  // values are hex-encoded bytes
+	Omitted   bool   `json:"omitted,omitempty"` // values are sizes in bytes; load them from /records/value
+	Nullable  *bool  `json:"nullable,omitempty"`
+	Length    *int64 `json:"length,omitempty"` // maximum length of variable-length types
+	Precision *int64 `json:"precision,omitempty"`
+	Scale     *int64 `json:"scale,omitempty"`
+
+	// Schema and Table name the base table the column was read from, when known.
+	Schema     string `json:"schema,omitempty"`
+	Table      string `json:"table,omitempty"`
+	PrimaryKey bool   `json:"primary_key,omitempty"`
+}
@@ -1,10 +1,12 @@
 package model
 
 type AddConstraintParams struct {
+	Schema         string   `json:"schema,omitempty"` // schema of the table; the driver's default if empty
 	TableName      string   `json:"table_name"`
 	ConstraintName string   `json:"constraint_name"`
 	Type           string   `json:"type"`                  // "PRIMARY KEY", "FOREIGN KEY", "UNIQUE", "CHECK"
 	Columns        []string `json:"columns"`               // columns for PK/UNIQUE
+	RefSchema      string   `json:"ref_schema,omitempty"`  // schema of RefTable; the table's schema if empty
 	RefTable       string   `json:"ref_table,omitempty"`   // required for FK
 	RefColumns     []string `json:"ref_columns,omitempty"` // required for FK
 	OnDelete       string   `json:"on_delete,omitempty"`
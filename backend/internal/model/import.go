@@ -0,0 +1,30 @@
+package model
+
+// ImportOptions describes a CSV file loaded into an existing table. Options
+// left empty are detected from the file.
+type ImportOptions struct {
+	Delimiter string            `json:"delimiter,omitempty"` // one character, e.g. "," or "\t"
+	Header    *bool             `json:"header,omitempty"`    // whether the first row names the columns
+	Encoding  string            `json:"encoding,omitempty"`  // e.g. "utf-8", "utf-16le" or "windows-1252"
+	Null      string            `json:"null,omitempty"`      // field read as NULL; empty fields are NULL by default
+	Mapping   map[string]string `json:"mapping,omitempty"`   // file column, by header name or 1-based position, to table column; "" skips it
+}
+
+// ImportResponse reports how a file was read and loaded. Rows that could not
+// be converted to their columns' types are left out and reported; the rest
+// are loaded in one transaction.
+type ImportResponse struct {
+	Encoding     string            `json:"encoding"`
+	Delimiter    string            `json:"delimiter"`
+	Header       bool              `json:"header"`
+	Mapping      map[string]string `json:"mapping"` // file column to table column, as used
+	RowsImported int64             `json:"rows_imported"`
+	RowsRejected int64             `json:"rows_rejected"`
+	Rejected     []ImportRejection `json:"rejected,omitempty"` // the first rejected rows
+}
+
+type ImportRejection struct {
+	Line   int    `json:"line"`             // line of the file the row starts on
+	Column string `json:"column,omitempty"` // table column whose value was rejected
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,15 @@
+package model
+
+type ConnectionProfile struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Driver   string            `json:"driver"` // e.g. "postgres", "mysql", "sqlite"
+	Host     string            `json:"host,omitempty"`
+	Port     int               `json:"port,omitempty"`
+	Database string            `json:"database"` // database name, or file path for SQLite
+	User     string            `json:"user,omitempty"`
+	Password string            `json:"password,omitempty"` // write-only, never returned
+	Options  map[string]string `json:"options,omitempty"`  // e.g. {"sslmode": "disable"}
+
+	HasPassword bool `json:"has_password"`
+}
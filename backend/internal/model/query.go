@@ -1,10 +1,46 @@
 package model
 
+import "encoding/json"
+
 type QueryRequest struct {
 	SQL string `json:"sql"`
+	// Params is an array bound to positional placeholders or an object
+	// bound to :name parameters.
+	Params json.RawMessage `json:"params,omitempty"`
+	// StopOnError and Transaction apply to scripts of several statements.
+	// A transaction is rolled back, and the script stopped, at the first error.
+	StopOnError bool `json:"stop_on_error"`
+	Transaction bool `json:"transaction"`
 }
 
 type QueryResponse struct {
-	Columns []string `json:"columns"`
-	Rows    [][]any  `json:"rows"`
+	Columns      []string     `json:"columns"`
+	Fields       []ColumnMeta `json:"fields,omitempty"`
+	Rows         [][]any      `json:"rows"`
+	Command      string       `json:"command,omitempty"`
+	RowsAffected *int64       `json:"rows_affected,omitempty"` // set for INSERT, UPDATE and DELETE without RETURNING
+}
+
+type ScriptOptions struct {
+	StopOnError bool
+	Transaction bool
+}
+
+// StatementResult is the outcome of one statement of a script. Statements
+// after a stopping error are reported as skipped.
+type StatementResult struct {
+	SQL          string       `json:"sql"`
+	Command      string       `json:"command,omitempty"`
+	Columns      []string     `json:"columns,omitempty"`
+	Fields       []ColumnMeta `json:"fields,omitempty"`
+	Rows         [][]any      `json:"rows,omitempty"`
+	RowsAffected *int64       `json:"rows_affected,omitempty"`
+	DurationMS   float64      `json:"duration_ms"`
+	Error        string       `json:"error,omitempty"`
+	Skipped      bool         `json:"skipped,omitempty"`
+}
+
+type ScriptResponse struct {
+	Results     []StatementResult `json:"results"`
+	Transaction string            `json:"transaction,omitempty"` // "committed" or "rolled_back"
 }
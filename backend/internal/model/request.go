@@ -3,4 +3,5 @@ package model
 type ConnectRequest struct {
 	Driver string `json:"driver"` // e.g. "postgres"
 	DSN    string `json:"dsn"`    // connection string
+	Name   string `json:"name"`   // optional label shown in /connections
 }
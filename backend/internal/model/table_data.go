@@ -1,20 +1,95 @@
 package model
 
 type TableDataRequest struct {
-	Schema  string   `json:"schema"`
-	Table   string   `json:"table"`
-	Limit   string   `json:"limit"`
-	Offset  string   `json:"offset"`
-	OrderBy string   `json:"order_by"` // optional
-	Filters []string `json:"filter"`   // e.g. ["name:like:john", "age:>=:30"]
+	Schema    string   `json:"schema"`
+	Table     string   `json:"table"`
+	Limit     string   `json:"limit"`
+	Offset    string   `json:"offset"`
+	OrderBy   string   `json:"order_by"`   // optional, e.g. "created_at:desc:nulls_last,id"; see helper.ParseOrderBy
+	Columns   []string `json:"columns"`    // optional subset to select; keys are always included
+	OmitLarge bool     `json:"omit_large"` // read large columns such as bytea and json as their size
+	Cursor    string   `json:"cursor"`     // optional next_cursor or prev_cursor of a previous page; replaces Offset
+	Count     string   `json:"count"`      // "auto" (default), "exact", "estimate" or "none"
+	Filters   []string `json:"filter"`     // e.g. ["name:like:john", "age:between:18,30", "status:in:a,b|deleted_at:is_null"]; see helper.ParseFilters
+	All       bool     `json:"-"`          // read every matching row; Limit, Offset and Cursor are ignored
 }
 
 type TableDataResponse struct {
-	Columns []string `json:"columns"`
-	Rows    [][]any  `json:"rows"`
+	Columns    []string     `json:"columns"`
+	Fields     []ColumnMeta `json:"fields,omitempty"`
+	Rows       [][]any      `json:"rows"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	PrevCursor string       `json:"prev_cursor,omitempty"`
+	Total      *int64       `json:"total,omitempty"`      // rows matching the filters
+	TotalKind  string       `json:"total_kind,omitempty"` // "exact" or "estimate"
+}
+
+// CellValue is a single column value of a row, as loaded for columns that
+// table data omitted.
+type CellValue struct {
+	Field ColumnMeta `json:"field"`
+	Value any        `json:"value"`
+}
+
+// RowEdit updates or deletes the single row of a table identified by Key.
+type RowEdit struct {
+	Schema   string         `json:"schema"`
+	Table    string         `json:"table"`
+	Key      map[string]any `json:"key"`                // primary or unique key values of the row
+	Data     map[string]any `json:"data,omitempty"`     // new values; updates only
+	Expected map[string]any `json:"expected,omitempty"` // values the row must still hold, else the edit conflicts
+	Version  string         `json:"version,omitempty"`  // row version (PostgreSQL xmin) the row must still have
+	Force    bool           `json:"force,omitempty"`    // on tables without a key, match Key's columns as given
+}
+
+// RowEditResult reports an edited row's new version, where the database has
+// row versions.
+type RowEditResult struct {
+	Version string `json:"version,omitempty"`
+}
+
+// Upsert turns an insert that conflicts on the table's primary key, or on
+// the named unique constraint, into an update of the existing row.
+type Upsert struct {
+	Constraint string   `json:"constraint,omitempty"` // as listed by ListConstraints; the primary key if empty
+	Update     []string `json:"update,omitempty"`     // columns to overwrite; every inserted column outside the key if omitted
+}
+
+// BatchRequest inserts, updates and deletes rows of one table in a single
+// transaction, running the inserts first, then the updates, then the deletes.
+type BatchRequest struct {
+	Schema          string           `json:"schema"`
+	Table           string           `json:"table"`
+	Inserts         []map[string]any `json:"inserts,omitempty"`
+	Updates         []BatchUpdate    `json:"updates,omitempty"`
+	Deletes         []map[string]any `json:"deletes,omitempty"`           // conditions of each delete
+	Upsert          *Upsert          `json:"upsert,omitempty"`            // makes the inserts upserts
+	ContinueOnError bool             `json:"continue_on_error,omitempty"` // undo only failed operations instead of the whole batch
+}
+
+type BatchUpdate struct {
+	Data  map[string]any `json:"data"`
+	Where map[string]any `json:"where"`
+}
+
+// BatchResult is the outcome of one operation of a batch. Operations after a
+// failure are reported as skipped unless the batch continues on error.
+type BatchResult struct {
+	Op           string `json:"op"`    // "insert", "update" or "delete"
+	Index        int    `json:"index"` // position in the request's list for Op
+	RowsAffected int64  `json:"rows_affected"`
+	Error        string `json:"error,omitempty"`
+	Skipped      bool   `json:"skipped,omitempty"`
+}
+
+type BatchResponse struct {
+	Results     []BatchResult `json:"results"`
+	Failed      int           `json:"failed"`
+	Transaction string        `json:"transaction"` // "committed" or "rolled_back"
 }
 
 type CreateTableRequest struct {
+	Schema    string      `json:"schema"` // optional; the driver's default schema if empty
 	TableName string      `json:"table_name" binding:"required"`
 	Columns   []ColumnDef `json:"columns" binding:"required,dive"`
 }
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"vind/backend/helper"
+	"vind/backend/internal/model"
+)
+
+// batchSavepoint wraps batches run inside an already open transaction, and
+// batchOpSavepoint each operation of a batch that continues on error.
+const (
+	batchSavepoint   = "vind_batch"
+	batchOpSavepoint = "vind_batch_op"
+)
+
+// batchOp is one operation of a batch with its statement. err is set if the
+// statement could not be built, in which case the operation fails unrun.
+type batchOp struct {
+	result model.BatchResult
+	query  string
+	args   []any
+	err    error
+}
+
+// batchColumns returns the names in values, sorted so that operations on
+// the same columns share a prepared statement.
+func batchColumns(d helper.SQLDialect, values map[string]any) ([]string, error) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, helper.CheckIdentifiers(d, names...)
+}
+
+// batchConditions returns the condition matching rows whose columns hold
+// the values in values, with NULL matching NULL, and the values to bind to
+// it. Postgres placeholders are numbered from firstArg.
+func batchConditions(d helper.SQLDialect, values map[string]any, firstArg int) (string, []any, error) {
+	names, err := batchColumns(d, values)
+	if err != nil {
+		return "", nil, err
+	}
+	conds := make([]string, len(names))
+	var args []any
+	for i, name := range names {
+		if values[name] == nil {
+			conds[i] = helper.QuoteIdentifier(d, name) + " IS NULL"
+			continue
+		}
+		args = append(args, values[name])
+		conds[i] = helper.QuoteIdentifier(d, name) + " = " + helper.Placeholder(d, firstArg+len(args)-1)
+	}
+	return strings.Join(conds, " AND "), args, nil
+}
+
+// insertSQL returns the INSERT of data into table, the quoted table name,
+// made an upsert on upsert if it is set.
+func insertSQL(table string, d helper.SQLDialect, data map[string]any, upsert *upsertTarget) (string, []any, error) {
+	if len(data) == 0 {
+		return "", nil, errors.New("no data to insert")
+	}
+	names, err := batchColumns(d, data)
+	if err != nil {
+		return "", nil, err
+	}
+	columns := make([]string, len(names))
+	placeholders := make([]string, len(names))
+	args := make([]any, len(names))
+	for i, name := range names {
+		columns[i] = helper.QuoteIdentifier(d, name)
+		placeholders[i] = helper.Placeholder(d, i+1)
+		args[i] = data[name]
+	}
+	query := "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ")"
+	if upsert != nil {
+		clause, err := upsertClause(d, names, *upsert)
+		if err != nil {
+			return "", nil, err
+		}
+		query += clause
+	}
+	return query, args, nil
+}
+
+func batchUpdate(table string, d helper.SQLDialect, update model.BatchUpdate) (string, []any, error) {
+	if len(update.Data) == 0 {
+		return "", nil, errors.New("no fields to update")
+	}
+	if len(update.Where) == 0 {
+		return "", nil, errors.New("missing WHERE clause — dangerous update prevented")
+	}
+	names, err := batchColumns(d, update.Data)
+	if err != nil {
+		return "", nil, err
+	}
+	set := make([]string, len(names))
+	args := make([]any, len(names))
+	for i, name := range names {
+		set[i] = helper.QuoteIdentifier(d, name) + " = " + helper.Placeholder(d, i+1)
+		args[i] = update.Data[name]
+	}
+	where, whereArgs, err := batchConditions(d, update.Where, len(args)+1)
+	if err != nil {
+		return "", nil, err
+	}
+	return "UPDATE " + table + " SET " + strings.Join(set, ", ") + " WHERE " + where, append(args, whereArgs...), nil
+}
+
+func batchDelete(table string, d helper.SQLDialect, conditions map[string]any) (string, []any, error) {
+	if len(conditions) == 0 {
+		return "", nil, errors.New("missing delete conditions")
+	}
+	where, args, err := batchConditions(d, conditions, 1)
+	if err != nil {
+		return "", nil, err
+	}
+	return "DELETE FROM " + table + " WHERE " + where, args, nil
+}
+
+// batchOps builds the operations of req on table, the quoted table name, in
+// the order they run. upsert, if set, is req.Upsert resolved.
+func batchOps(req model.BatchRequest, table string, d helper.SQLDialect, upsert *upsertTarget) []batchOp {
+	ops := make([]batchOp, 0, len(req.Inserts)+len(req.Updates)+len(req.Deletes))
+	add := func(op string, index int, query string, args []any, err error) {
+		ops = append(ops, batchOp{result: model.BatchResult{Op: op, Index: index}, query: query, args: args, err: err})
+	}
+	for i, data := range req.Inserts {
+		query, args, err := insertSQL(table, d, data, upsert)
+		add("insert", i, query, args, err)
+	}
+	for i, update := range req.Updates {
+		query, args, err := batchUpdate(table, d, update)
+		add("update", i, query, args, err)
+	}
+	for i, conditions := range req.Deletes {
+		query, args, err := batchDelete(table, d, conditions)
+		add("delete", i, query, args, err)
+	}
+	return ops
+}
+
+// runBatch runs the operations of req on table, the quoted table name, in a
+// new transaction, or under a savepoint when the client is bound to openTx.
+// Unless req.ContinueOnError is set, the first failure rolls back the whole
+// batch. upsert, if set, is req.Upsert resolved.
+func runBatch(ctx context.Context, db *sql.DB, openTx *sql.Tx, table string, d helper.SQLDialect, req model.BatchRequest, upsert *upsertTarget) (*model.BatchResponse, error) {
+	ops := batchOps(req, table, d, upsert)
+	if len(ops) == 0 {
+		return nil, errors.New("no operations in batch")
+	}
+
+	if openTx != nil {
+		if _, err := openTx.ExecContext(ctx, "SAVEPOINT "+batchSavepoint); err != nil {
+			return nil, err
+		}
+		resp, err := runBatchOps(ctx, openTx, ops, req.ContinueOnError)
+		if err == nil && resp.Transaction == "" {
+			_, err = openTx.ExecContext(ctx, "RELEASE SAVEPOINT "+batchSavepoint)
+			resp.Transaction = "committed"
+		} else if _, rbErr := openTx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+batchSavepoint); err == nil {
+			err = rbErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// As with scripts, the deferred Rollback undoes a failed batch.
+	defer tx.Rollback()
+
+	resp, err := runBatchOps(ctx, tx, ops, req.ContinueOnError)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Transaction == "" {
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		resp.Transaction = "committed"
+	}
+	return resp, nil
+}
+
+// runBatchOps runs ops on tx, preparing each distinct statement once. With
+// continueOnError every operation runs under its own savepoint, so a failure
+// undoes only that operation; otherwise it stops at the first failure and
+// marks the response as rolled back, leaving the rollback to the caller.
+func runBatchOps(ctx context.Context, tx *sql.Tx, ops []batchOp, continueOnError bool) (*model.BatchResponse, error) {
+	stmts := map[string]*sql.Stmt{}
+	defer func() {
+		for _, stmt := range stmts {
+			stmt.Close()
+		}
+	}()
+	exec := func(op batchOp) (int64, error) {
+		stmt, ok := stmts[op.query]
+		if !ok {
+			var err error
+			if stmt, err = tx.PrepareContext(ctx, op.query); err != nil {
+				return 0, err
+			}
+			stmts[op.query] = stmt
+		}
+		res, err := stmt.ExecContext(ctx, op.args...)
+		if err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
+	}
+
+	resp := &model.BatchResponse{Results: make([]model.BatchResult, len(ops))}
+	stopped := false
+	for i, op := range ops {
+		result := op.result
+		switch {
+		case stopped:
+			result.Skipped = true
+		case op.err != nil:
+			result.Error = op.err.Error()
+		case continueOnError:
+			if _, err := tx.ExecContext(ctx, "SAVEPOINT "+batchOpSavepoint); err != nil {
+				return nil, err
+			}
+			n, err := exec(op)
+			if err != nil {
+				result.Error = err.Error()
+				if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+batchOpSavepoint); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+batchOpSavepoint); err != nil {
+				return nil, err
+			}
+			result.RowsAffected = n
+		default:
+			n, err := exec(op)
+			if err != nil {
+				result.Error = err.Error()
+			}
+			result.RowsAffected = n
+		}
+		if result.Error != "" {
+			resp.Failed++
+			stopped = !continueOnError || ctx.Err() != nil
+		}
+		resp.Results[i] = result
+	}
+
+	if resp.Failed > 0 && !continueOnError {
+		resp.Transaction = "rolled_back"
+	}
+	return resp, nil
+}
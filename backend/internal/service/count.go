@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"vind/backend/internal/model"
+)
+
+// Row count modes of model.TableDataRequest.Count. An empty mode is CountAuto.
+const (
+	CountAuto     = "auto"
+	CountExact    = "exact"
+	CountEstimate = "estimate"
+	CountNone     = "none"
+)
+
+// Kinds of model.TableDataResponse.TotalKind.
+const (
+	TotalExact    = "exact"
+	TotalEstimate = "estimate"
+)
+
+var (
+	// exactCountThreshold is the estimated row count above which CountAuto
+	// reports the estimate instead of counting.
+	exactCountThreshold int64 = 1_000_000
+
+	// exactCountTimeout bounds the exact count CountAuto runs; when it
+	// expires the estimate is reported instead.
+	exactCountTimeout = 2 * time.Second
+)
+
+// SetExactCountLimits changes when table data is counted exactly.
+func SetExactCountLimits(threshold int64, timeout time.Duration) {
+	exactCountThreshold = threshold
+	exactCountTimeout = timeout
+}
+
+// ValidCountMode reports whether mode is a known row count mode.
+func ValidCountMode(mode string) bool {
+	switch mode {
+	case "", CountAuto, CountExact, CountEstimate, CountNone:
+		return true
+	}
+	return false
+}
+
+// rowEstimator returns the planner's estimate of the rows a table-data
+// query matches, and false if the database has none.
+type rowEstimator func(ctx context.Context) (int64, bool, error)
+
+// noRowEstimate is the rowEstimator of databases without planner estimates.
+func noRowEstimate(ctx context.Context) (int64, bool, error) {
+	return 0, false, nil
+}
+
+// countSavepoint wraps the row count of a client bound to a transaction.
+const countSavepoint = "vind_count"
+
+// countRows fills in resp's total for the rows of from matching where,
+// following mode. Inside a transaction the estimate and count run under a
+// savepoint, so that their failure does not abort it; since cancelling a
+// statement can close the connection, and with it the transaction, there
+// CountAuto only skips counting large tables and does not limit the count's
+// run time.
+func countRows(ctx context.Context, q queryer, inTx bool, mode, from, where string, args []any, estimate rowEstimator, resp *model.TableDataResponse) error {
+	if mode == CountNone {
+		return nil
+	}
+	if inTx {
+		return readUnderSavepoint(ctx, q, countSavepoint, func() error {
+			return countMatching(ctx, q, true, mode, from, where, args, estimate, resp)
+		})
+	}
+	return countMatching(ctx, q, false, mode, from, where, args, estimate, resp)
+}
+
+// countMatching is countRows without the savepoint.
+func countMatching(ctx context.Context, q queryer, inTx bool, mode, from, where string, args []any, estimate rowEstimator, resp *model.TableDataResponse) error {
+
+	var estimated *int64
+	if mode != CountExact {
+		n, ok, err := estimate(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil && ok {
+			estimated = &n
+		}
+		if mode == CountEstimate || (estimated != nil && *estimated > exactCountThreshold) {
+			if estimated != nil {
+				resp.Total, resp.TotalKind = estimated, TotalEstimate
+			}
+			return nil
+		}
+	}
+
+	query := "SELECT COUNT(*) FROM " + from
+	if where != "" {
+		query += " WHERE " + where
+	}
+	countCtx := ctx
+	if mode != CountExact && !inTx {
+		var cancel context.CancelFunc
+		countCtx, cancel = context.WithTimeout(ctx, exactCountTimeout)
+		defer cancel()
+	}
+
+	var n int64
+	if err := q.QueryRowContext(countCtx, query, args...).Scan(&n); err != nil {
+		if mode == CountExact || ctx.Err() != nil {
+			return fmt.Errorf("counting rows: %w", err)
+		}
+		if estimated != nil {
+			resp.Total, resp.TotalKind = estimated, TotalEstimate
+		}
+		return nil
+	}
+	resp.Total, resp.TotalKind = &n, TotalExact
+	return nil
+}
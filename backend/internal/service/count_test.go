@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"vind/backend/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountRows(t *testing.T) {
+	s := newTestSQLiteClient(t,
+		`CREATE TABLE t (n INTEGER)`,
+		`INSERT INTO t VALUES (1), (2), (3)`,
+	)
+	old, oldTimeout := exactCountThreshold, exactCountTimeout
+	SetExactCountLimits(1000, time.Second)
+	t.Cleanup(func() { SetExactCountLimits(old, oldTimeout) })
+
+	estimateOf := func(n int64) rowEstimator {
+		return func(ctx context.Context) (int64, bool, error) { return n, true, nil }
+	}
+	failing := func(ctx context.Context) (int64, bool, error) { return 0, false, errors.New("no plan") }
+
+	tests := []struct {
+		name      string
+		mode      string
+		estimate  rowEstimator
+		from      string
+		wantTotal *int64
+		wantKind  string
+	}{
+		{"auto counts small tables", "", estimateOf(10), "t", ptr(int64(2)), TotalExact},
+		{"auto estimates large tables", CountAuto, estimateOf(5000), "t", ptr(int64(5000)), TotalEstimate},
+		{"auto without estimate", CountAuto, failing, "t", ptr(int64(2)), TotalExact},
+		{"auto falls back when counting fails", CountAuto, estimateOf(7), "missing", ptr(int64(7)), TotalEstimate},
+		{"exact ignores the estimate", CountExact, estimateOf(5000), "t", ptr(int64(2)), TotalExact},
+		{"estimate only", CountEstimate, estimateOf(10), "t", ptr(int64(10)), TotalEstimate},
+		{"estimate unavailable", CountEstimate, noRowEstimate, "t", nil, ""},
+		{"none", CountNone, estimateOf(10), "t", nil, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var resp model.TableDataResponse
+			err := countRows(context.Background(), s.db, false, tc.mode, tc.from, "n > ?", []any{1}, tc.estimate, &resp)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantTotal, resp.Total)
+			assert.Equal(t, tc.wantKind, resp.TotalKind)
+		})
+	}
+
+	var resp model.TableDataResponse
+	err := countRows(context.Background(), s.db, false, CountExact, "missing", "", nil, noRowEstimate, &resp)
+	assert.ErrorContains(t, err, "counting rows")
+}
+
+func TestCountRowsInTransaction(t *testing.T) {
+	s := newTestSQLiteClient(t, `CREATE TABLE t (n INTEGER)`)
+	tx, err := s.db.Begin()
+	require.NoError(t, err)
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO t VALUES (1), (2)`)
+	require.NoError(t, err)
+
+	failing := func(ctx context.Context) (int64, bool, error) {
+		_, err := tx.ExecContext(ctx, `SELECT * FROM missing`)
+		return 0, false, err
+	}
+	var resp model.TableDataResponse
+	require.NoError(t, countRows(context.Background(), tx, true, CountAuto, "missing", "", nil, failing, &resp))
+	assert.Nil(t, resp.Total)
+	require.NoError(t, countRows(context.Background(), tx, true, CountAuto, "t", "", nil, failing, &resp))
+	assert.Equal(t, ptr(int64(2)), resp.Total)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, countRows(ctx, tx, true, CountExact, "t", "", nil, noRowEstimate, &resp), context.Canceled)
+
+	// The savepoint is gone and the transaction's own writes are kept.
+	_, err = tx.Exec("RELEASE SAVEPOINT " + countSavepoint)
+	assert.Error(t, err)
+	var n int
+	require.NoError(t, tx.QueryRow(`SELECT COUNT(*) FROM t`).Scan(&n))
+	assert.Equal(t, 2, n)
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,558 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"vind/backend/helper"
+	"vind/backend/internal/model"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+var ErrInvalidImport = errors.New("invalid import")
+
+const (
+	// csvSampleSize is how much of a file encoding, delimiter and header
+	// detection look at.
+	csvSampleSize = 64 << 10
+	// importChunkRows is how many rows are loaded at a time.
+	importChunkRows = 1000
+	// maxImportRejections caps the rejected rows listed in a response.
+	maxImportRejections = 1000
+	// maxInsertParams keeps multi-row INSERTs below the drivers' limits on
+	// bound values.
+	maxInsertParams = 30000
+	importSavepoint = "vind_import"
+)
+
+// csvDelimiters are the delimiters detection chooses between, by preference.
+var csvDelimiters = []rune{',', ';', '\t', '|'}
+
+// importLoader loads rows of values for columns into a table inside tx.
+type importLoader func(ctx context.Context, tx *sql.Tx, columns []string, rows [][]any) error
+
+// importRowError reports which of the rows given to an importLoader it
+// failed on, for loaders that know.
+type importRowError struct {
+	row int
+	err error
+}
+
+func (e *importRowError) Error() string { return e.err.Error() }
+func (e *importRowError) Unwrap() error { return e.err }
+
+// importKind is how the values of a column are converted before loading.
+type importKind int
+
+const (
+	importText importKind = iota
+	importInteger
+	importNumber
+	importBool
+	importDate
+	importTimestamp
+	importTimestampTZ
+	importJSON
+)
+
+// importField is a file column loaded into a table column.
+type importField struct {
+	index  int
+	column string
+	kind   importKind
+}
+
+// columnKind classifies a column type as ListColumns reports it, e.g.
+// "timestamp with time zone", "int(11) unsigned" or "VARCHAR(20)".
+func columnKind(typ string) importKind {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	if typ == "tinyint(1)" {
+		return importBool
+	}
+	base := typ
+	if i := strings.IndexAny(base, "( "); i >= 0 && !strings.HasPrefix(base, "double precision") && !strings.HasPrefix(base, "timestamp") {
+		base = base[:i]
+	}
+	switch {
+	case base == "timestamptz", strings.HasPrefix(base, "timestamp") && strings.Contains(base, "with time zone"):
+		return importTimestampTZ
+	case strings.HasPrefix(base, "timestamp"), base == "datetime":
+		return importTimestamp
+	case base == "date":
+		return importDate
+	case base == "bool", base == "boolean":
+		return importBool
+	case base == "json", base == "jsonb":
+		return importJSON
+	}
+	switch base {
+	case "int", "integer", "smallint", "bigint", "tinyint", "mediumint", "int2", "int4", "int8",
+		"serial", "smallserial", "bigserial", "serial4", "serial8":
+		return importInteger
+	case "numeric", "decimal", "dec", "fixed", "real", "float", "float4", "float8", "double", "double precision":
+		return importNumber
+	}
+	return importText
+}
+
+var importTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04",
+	time.DateOnly,
+}
+
+// convert turns a field of the file into the value loaded for its column.
+func (k importKind) convert(s string) (any, error) {
+	s = strings.TrimSpace(s)
+	switch k {
+	case importInteger:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", s)
+		}
+		return n, nil
+	case importNumber:
+		// Validated as a float but loaded as written, keeping its precision.
+		if _, err := strconv.ParseFloat(s, 64); err != nil && !errors.Is(err, strconv.ErrRange) {
+			return nil, fmt.Errorf("invalid number %q", s)
+		}
+		return s, nil
+	case importBool:
+		switch strings.ToLower(s) {
+		case "true", "t", "yes", "y", "1":
+			return true, nil
+		case "false", "f", "no", "n", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("invalid boolean %q", s)
+	case importDate:
+		t, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", s)
+		}
+		return t.Format(time.DateOnly), nil
+	case importTimestamp, importTimestampTZ:
+		for _, layout := range importTimeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				if k == importTimestampTZ {
+					return t.Format(time.RFC3339Nano), nil
+				}
+				return t.Format("2006-01-02 15:04:05.999999999"), nil
+			}
+		}
+		return nil, fmt.Errorf("invalid timestamp %q", s)
+	case importJSON:
+		if !json.Valid([]byte(s)) {
+			return nil, fmt.Errorf("invalid JSON %q", s)
+		}
+		return s, nil
+	}
+	return s, nil
+}
+
+// decodeCSV returns src decoded to UTF-8 from the named encoding or, if name
+// is empty, from the one detected: UTF-8 or UTF-16 by their byte order mark,
+// else UTF-8 if the start of the file is valid UTF-8, else Windows-1252. It
+// also returns the encoding's name.
+func decodeCSV(src io.Reader, name string) (io.Reader, string, error) {
+	br := bufio.NewReaderSize(src, csvSampleSize)
+	if name == "" {
+		sample, err := br.Peek(csvSampleSize)
+		if err != nil && err != io.EOF {
+			return nil, "", err
+		}
+		name = detectEncoding(sample)
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: unknown encoding %q", ErrInvalidImport, name)
+	}
+	name, _ = htmlindex.Name(enc)
+	return transform.NewReader(br, unicode.BOMOverride(enc.NewDecoder())), name, nil
+}
+
+func detectEncoding(sample []byte) string {
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xef, 0xbb, 0xbf}):
+		return "utf-8"
+	case bytes.HasPrefix(sample, []byte{0xff, 0xfe}):
+		return "utf-16le"
+	case bytes.HasPrefix(sample, []byte{0xfe, 0xff}):
+		return "utf-16be"
+	}
+	// Leave out a character cut off by the end of the sample.
+	end := len(sample)
+	for i := 1; i <= utf8.UTFMax && i <= end; i++ {
+		if utf8.RuneStart(sample[end-i]) {
+			if !utf8.FullRune(sample[end-i:]) {
+				end -= i
+			}
+			break
+		}
+	}
+	if utf8.Valid(sample[:end]) {
+		return "utf-8"
+	}
+	return "windows-1252"
+}
+
+// detectDelimiter picks the delimiter that splits the first line of sample
+// into several fields and the most of its other complete lines into as many,
+// or else ','.
+func detectDelimiter(sample []byte) rune {
+	if i := bytes.LastIndexByte(sample, '\n'); i >= 0 {
+		sample = sample[:i+1]
+	}
+	best, bestAgree, bestFields := ',', 0, 1
+	for _, delim := range csvDelimiters {
+		r := csv.NewReader(bytes.NewReader(sample))
+		r.Comma = delim
+		r.FieldsPerRecord = -1
+		r.LazyQuotes = true
+		agree, fields := 0, 0
+		for range 20 {
+			record, err := r.Read()
+			if err != nil {
+				break
+			}
+			if fields == 0 {
+				fields = len(record)
+			}
+			if len(record) == fields {
+				agree++
+			}
+		}
+		if fields > 1 && (agree > bestAgree || agree == bestAgree && fields > bestFields) {
+			best, bestAgree, bestFields = delim, agree, fields
+		}
+	}
+	return best
+}
+
+// detectHeader guesses whether first, the first row of a file, names its
+// columns: it does if it names a column of the table, or if it has text
+// where second, the next row, has numbers.
+func detectHeader(first, second []string, columns []model.Column) bool {
+	for _, name := range first {
+		if findColumn(columns, name) != "" {
+			return true
+		}
+	}
+	for i, s := range second {
+		if i >= len(first) {
+			break
+		}
+		_, errFirst := strconv.ParseFloat(strings.TrimSpace(first[i]), 64)
+		_, errSecond := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if errFirst != nil && strings.TrimSpace(first[i]) != "" && errSecond == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// findColumn returns the column of columns named name, ignoring case and
+// surrounding spaces if no name matches exactly, or "" if there is none.
+func findColumn(columns []model.Column, name string) string {
+	for _, col := range columns {
+		if col.Name == name {
+			return col.Name
+		}
+	}
+	name = strings.TrimSpace(name)
+	for _, col := range columns {
+		if strings.EqualFold(col.Name, name) {
+			return col.Name
+		}
+	}
+	return ""
+}
+
+// mapFields resolves which table column each file column is loaded into:
+// by mapping if given, else by header name, else by position. It also
+// returns the mapping used, by header name or 1-based position.
+func mapFields(header []string, fields int, columns []model.Column, mapping map[string]string) ([]importField, map[string]string, error) {
+	name := func(i int) string {
+		if header != nil {
+			return header[i]
+		}
+		return strconv.Itoa(i + 1)
+	}
+	kinds := make(map[string]importKind, len(columns))
+	for _, col := range columns {
+		kinds[col.Name] = columnKind(col.Type)
+	}
+
+	var result []importField
+	used := map[string]string{}
+	add := func(i int, column string) error {
+		if _, ok := kinds[column]; !ok {
+			return fmt.Errorf("%w %q", ErrUnknownColumn, column)
+		}
+		for _, f := range result {
+			if f.column == column {
+				return fmt.Errorf("%w: column %q is mapped twice", ErrInvalidImport, column)
+			}
+		}
+		result = append(result, importField{index: i, column: column, kind: kinds[column]})
+		used[name(i)] = column
+		return nil
+	}
+
+	switch {
+	case len(mapping) > 0:
+		for key, column := range mapping {
+			i := -1
+			for j := range header {
+				if header[j] == key {
+					i = j
+					break
+				}
+			}
+			if n, err := strconv.Atoi(key); i < 0 && err == nil && n >= 1 && n <= fields {
+				i = n - 1
+			}
+			if i < 0 {
+				return nil, nil, fmt.Errorf("%w: the file has no column %q", ErrInvalidImport, key)
+			}
+			if column == "" {
+				continue
+			}
+			if err := add(i, column); err != nil {
+				return nil, nil, err
+			}
+		}
+	case header != nil:
+		for i, h := range header {
+			if column := findColumn(columns, h); column != "" {
+				if err := add(i, column); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	default:
+		for i := 0; i < fields && i < len(columns); i++ {
+			if err := add(i, columns[i].Name); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if len(result) == 0 {
+		return nil, nil, fmt.Errorf("%w: no file column matches a table column; give a mapping", ErrInvalidImport)
+	}
+	return result, used, nil
+}
+
+// importCSV loads the CSV file src into a table with columns, in a new
+// transaction or under a savepoint when the client is bound to openTx. Rows
+// whose values cannot be converted are rejected and the rest loaded with
+// load; a failure to load rolls the whole import back.
+func importCSV(ctx context.Context, db *sql.DB, openTx *sql.Tx, columns []model.Column, src io.Reader, opts model.ImportOptions, load importLoader) (*model.ImportResponse, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%w: table not found", ErrInvalidImport)
+	}
+	if openTx != nil {
+		if _, err := openTx.ExecContext(ctx, "SAVEPOINT "+importSavepoint); err != nil {
+			return nil, err
+		}
+		resp, err := readCSV(ctx, openTx, columns, src, opts, load)
+		if err == nil {
+			_, err = openTx.ExecContext(ctx, "RELEASE SAVEPOINT "+importSavepoint)
+		} else if _, rbErr := openTx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+importSavepoint); rbErr != nil {
+			return nil, rbErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	resp, err := readCSV(ctx, tx, columns, src, opts, load)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// readCSV reads src and loads its rows inside tx, see importCSV.
+func readCSV(ctx context.Context, tx *sql.Tx, columns []model.Column, src io.Reader, opts model.ImportOptions, load importLoader) (*model.ImportResponse, error) {
+	decoded, encoding, err := decodeCSV(src, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReaderSize(decoded, csvSampleSize)
+	sample, err := br.Peek(csvSampleSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	// Peeked bytes only stay valid until the next read.
+	sample = bytes.Clone(sample)
+
+	delim := detectDelimiter(sample)
+	if opts.Delimiter != "" {
+		d, size := utf8.DecodeRuneInString(opts.Delimiter)
+		if size != len(opts.Delimiter) || d == '"' || d == '\r' || d == '\n' {
+			return nil, fmt.Errorf("%w: delimiter must be a single character other than a quote or newline", ErrInvalidImport)
+		}
+		delim = d
+	}
+	r := csv.NewReader(br)
+	r.Comma = delim
+	r.FieldsPerRecord = -1
+
+	first, err := r.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: the file is empty", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	var header []string
+	if opts.Header != nil {
+		if *opts.Header {
+			header = first
+		}
+	} else {
+		sr := csv.NewReader(bytes.NewReader(sample))
+		sr.Comma = delim
+		sr.FieldsPerRecord = -1
+		sr.LazyQuotes = true
+		sr.Read()
+		second, _ := sr.Read()
+		if detectHeader(first, second, columns) {
+			header = first
+		}
+	}
+
+	fields, mapping, err := mapFields(header, len(first), columns, opts.Mapping)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.column
+	}
+
+	resp := &model.ImportResponse{Encoding: encoding, Delimiter: string(delim), Header: header != nil, Mapping: mapping}
+	reject := func(line int, column, reason string) {
+		resp.RowsRejected++
+		if len(resp.Rejected) < maxImportRejections {
+			resp.Rejected = append(resp.Rejected, model.ImportRejection{Line: line, Column: column, Reason: reason})
+		}
+	}
+
+	var chunk [][]any
+	var lines []int
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		if err := load(ctx, tx, names, chunk); err != nil {
+			var rowErr *importRowError
+			if errors.As(err, &rowErr) && rowErr.row < len(lines) {
+				return fmt.Errorf("line %d: %w", lines[rowErr.row], rowErr.err)
+			}
+			return fmt.Errorf("lines %d-%d: %w", lines[0], lines[len(lines)-1], err)
+		}
+		resp.RowsImported += int64(len(chunk))
+		chunk, lines = chunk[:0], lines[:0]
+		return nil
+	}
+
+	record := first
+	if header != nil {
+		record, err = r.Read()
+	}
+next:
+	for ; err != io.EOF; record, err = r.Read() {
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		line, _ := r.FieldPos(0)
+		if len(record) != len(first) {
+			reject(line, "", fmt.Sprintf("expected %d fields, got %d", len(first), len(record)))
+			continue
+		}
+		row := make([]any, len(fields))
+		for i, f := range fields {
+			s := record[f.index]
+			if s == opts.Null {
+				continue
+			}
+			v, err := f.kind.convert(s)
+			if err != nil {
+				reject(line, f.column, err.Error())
+				continue next
+			}
+			row[i] = v
+		}
+		chunk = append(chunk, row)
+		lines = append(lines, line)
+		if len(chunk) == importChunkRows {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// insertRows returns the importLoader for table, the quoted table name,
+// that loads rows with multi-row INSERTs.
+func insertRows(d helper.SQLDialect, table string) importLoader {
+	return func(ctx context.Context, tx *sql.Tx, columns []string, rows [][]any) error {
+		quoted := make([]string, len(columns))
+		for i, name := range columns {
+			quoted[i] = helper.QuoteIdentifier(d, name)
+		}
+		prefix := "INSERT INTO " + table + " (" + strings.Join(quoted, ", ") + ") VALUES "
+
+		perStatement := max(1, maxInsertParams/len(columns))
+		for len(rows) > 0 {
+			n := min(perStatement, len(rows))
+			values := make([]string, n)
+			args := make([]any, 0, n*len(columns))
+			for i, row := range rows[:n] {
+				placeholders := make([]string, len(row))
+				for j := range row {
+					placeholders[j] = helper.Placeholder(d, len(args)+j+1)
+				}
+				values[i] = "(" + strings.Join(placeholders, ", ") + ")"
+				args = append(args, row...)
+			}
+			if _, err := tx.ExecContext(ctx, prefix+strings.Join(values, ", "), args...); err != nil {
+				return err
+			}
+			rows = rows[n:]
+		}
+		return nil
+	}
+}
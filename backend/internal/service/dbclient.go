@@ -1,23 +1,44 @@
 package service
 
-import "vind/backend/internal/model"
+import (
+	"context"
+	"io"
+	"vind/backend/helper"
+	"vind/backend/internal/model"
+)
 
+// DBClient is implemented by each supported database. Tables are named by a
+// helper.QualifiedName whose empty schema means the driver's default one.
 type DBClient interface {
 	Connect(dsn string) error
 	Disconnect() error
-	ListSchemas() ([]string, error)
-	ListTables(schema string) ([]string, error)
-	ListColumns(schema, table string) ([]model.Column, error)
-	ExecuteQuery(query string) ([]string, [][]any, error)
-	GetTableData(req model.TableDataRequest) ([]string, [][]any, error)
-	InsertRecord(schema, table string, data map[string]any) error
-	UpdateRecord(schema, table string, data, where map[string]any) (int64, error)
-	DeleteRecord(schema, table string, conditions map[string]any) (int64, error)
-	CreateTable(tableName string, columns []model.ColumnDef) error
-	AlterTable(tableName string, ops []model.AlterTableOperation) error
-	DropTable(tableName string, cascade bool) error
+	BeginTx(ctx context.Context) (Tx, error)
+	ListSchemas(ctx context.Context) ([]string, error)
+	ListTables(ctx context.Context, schema string) ([]string, error)
+	ListColumns(ctx context.Context, table helper.QualifiedName) ([]model.Column, error)
+	ExecuteQuery(ctx context.Context, query string, args ...any) (*model.QueryResponse, error)
+	StreamQuery(ctx context.Context, query string, w RowWriter, args ...any) (*model.QueryResponse, error)
+	ExecuteScript(ctx context.Context, statements []string, opts model.ScriptOptions) (*model.ScriptResponse, error)
+	Dialect() helper.SQLDialect
+	CancelQuery(ctx context.Context, backendID int64) error
+	GetTableData(ctx context.Context, req model.TableDataRequest) (*model.TableDataResponse, error)
+	StreamTableData(ctx context.Context, req model.TableDataRequest, w RowWriter) (*model.TableDataResponse, error)
+	GetCellValue(ctx context.Context, table helper.QualifiedName, column string, key map[string]any) (*model.CellValue, error)
+	// InsertRecord, UpsertRecord, UpdateRecord and DeleteRecord return the
+	// rows they wrote, as the database left them, and how many there were.
+	InsertRecord(ctx context.Context, table helper.QualifiedName, data map[string]any) (*model.QueryResponse, error)
+	UpsertRecord(ctx context.Context, table helper.QualifiedName, data map[string]any, upsert model.Upsert) (*model.QueryResponse, error)
+	UpdateRecord(ctx context.Context, table helper.QualifiedName, data, where map[string]any) (*model.QueryResponse, error)
+	DeleteRecord(ctx context.Context, table helper.QualifiedName, conditions map[string]any) (*model.QueryResponse, error)
+	UpdateRow(ctx context.Context, edit model.RowEdit) (*model.RowEditResult, error)
+	DeleteRow(ctx context.Context, edit model.RowEdit) error
+	ExecuteBatch(ctx context.Context, req model.BatchRequest) (*model.BatchResponse, error)
+	ImportCSV(ctx context.Context, table helper.QualifiedName, src io.Reader, opts model.ImportOptions) (*model.ImportResponse, error)
+	CreateTable(ctx context.Context, table helper.QualifiedName, columns []model.ColumnDef) error
+	AlterTable(ctx context.Context, table helper.QualifiedName, ops []model.AlterTableOperation) error
+	DropTable(ctx context.Context, table helper.QualifiedName, cascade bool) error
 
-	AddConstraint(params model.AddConstraintParams) error
-	DropConstraint(tableName, constraintName string, cascade bool) error
-	ListConstraints(tableName string) ([]model.ConstraintInfo, error)
+	AddConstraint(ctx context.Context, params model.AddConstraintParams) error
+	DropConstraint(ctx context.Context, table helper.QualifiedName, constraintName string, cascade bool) error
+	ListConstraints(ctx context.Context, table helper.QualifiedName) ([]model.ConstraintInfo, error)
 }
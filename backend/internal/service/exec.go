@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+	"vind/backend/helper"
+	"vind/backend/internal/model"
+)
+
+// queryer is the subset of *sql.DB, *sql.Conn and *sql.Tx used to run statements.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Tx is a DBClient whose methods all run inside one open transaction.
+type Tx interface {
+	DBClient
+	Commit() error
+	Rollback() error
+}
+
+var (
+	ErrNotInTransaction = errors.New("client is not bound to a transaction")
+	ErrInTransaction    = errors.New("a transaction is already open")
+)
+
+type backendIDReporterKey struct{}
+
+// WithBackendIDReporter asks the client to run the statement on a dedicated
+// connection and report that connection's server-side ID (the Postgres
+// backend PID or MySQL connection ID) to fn before executing it. The ID can
+// then be passed to DBClient.CancelQuery from another request.
+func WithBackendIDReporter(ctx context.Context, fn func(id int64)) context.Context {
+	return context.WithValue(ctx, backendIDReporterKey{}, fn)
+}
+
+// ReportBackendID passes id to the reporter registered on ctx, if any.
+func ReportBackendID(ctx context.Context, id int64) {
+	if fn, ok := ctx.Value(backendIDReporterKey{}).(func(int64)); ok {
+		fn(id)
+	}
+}
+
+// pinConnection returns db unchanged unless ctx carries a backend ID
+// reporter, in which case it reserves a connection, reports its ID using
+// idQuery and returns it. A client bound to tx always runs on tx, whose
+// connection is already reserved. The release func must always be called.
+func pinConnection(ctx context.Context, db *sql.DB, tx *sql.Tx, idQuery string) (queryer, func(), error) {
+	_, report := ctx.Value(backendIDReporterKey{}).(func(int64))
+	if tx != nil {
+		if report && idQuery != "" {
+			var id int64
+			if err := tx.QueryRowContext(ctx, idQuery).Scan(&id); err != nil {
+				return nil, nil, err
+			}
+			ReportBackendID(ctx, id)
+		}
+		return tx, func() {}, nil
+	}
+	if !report {
+		return db, func() {}, nil
+	}
+
+	conn, err := reserveConnection(ctx, db, idQuery)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, func() { conn.Close() }, nil
+}
+
+// reserveConnection takes a connection from the pool and, when ctx carries
+// a backend ID reporter and idQuery is set, reports its server-side ID.
+func reserveConnection(ctx context.Context, db *sql.DB, idQuery string) (*sql.Conn, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := ctx.Value(backendIDReporterKey{}).(func(int64)); ok && idQuery != "" {
+		var id int64
+		if err := conn.QueryRowContext(ctx, idQuery).Scan(&id); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		ReportBackendID(ctx, id)
+	}
+	return conn, nil
+}
+
+// runStatement executes a single statement on q. Statements that produce a
+// result set are run with QueryContext and their rows passed to w; others
+// are run with ExecContext and report rows affected when they are DML.
+func runStatement(ctx context.Context, q queryer, query string, args []any, d helper.SQLDialect, w RowWriter, convert columnConverter) (*model.QueryResponse, error) {
+	info := helper.ClassifySQL(query, d)
+	resp := &model.QueryResponse{Command: info.Command}
+
+	if info.ReturnsRows {
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		if err := scanRows(rows, w, convert); err != nil {
+			return resp, err
+		}
+		return resp, nil
+	}
+
+	res, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDML() {
+		if n, err := res.RowsAffected(); err == nil {
+			resp.RowsAffected = &n
+		}
+	}
+	return resp, nil
+}
+
+// collectQuery runs stream with a rowCollector and fills the response's
+// columns and rows from it.
+func collectQuery(stream func(w RowWriter) (*model.QueryResponse, error)) (*model.QueryResponse, error) {
+	var rc rowCollector
+	resp, err := stream(&rc)
+	if err != nil {
+		return nil, err
+	}
+	resp.Columns = rc.columns()
+	resp.Fields = rc.fields
+	resp.Rows = rc.rows
+	return resp, nil
+}
+
+// scriptSavepoint wraps scripts run with the Transaction option inside an
+// already open transaction.
+const scriptSavepoint = "vind_script"
+
+// readUnderSavepoint runs the reads of fn inside the transaction q under
+// the savepoint name and then rolls back to it, even if ctx is done, so that
+// a failed statement does not leave the transaction aborted on Postgres.
+func readUnderSavepoint(ctx context.Context, q queryer, name string, fn func() error) error {
+	if _, err := q.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+	err := fn()
+	ctx = context.WithoutCancel(ctx)
+	if _, rbErr := q.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+		return errors.Join(err, rbErr)
+	}
+	if _, relErr := q.ExecContext(ctx, "RELEASE SAVEPOINT "+name); relErr != nil {
+		return errors.Join(err, relErr)
+	}
+	return err
+}
+
+// runScript executes statements in order on a single connection, so that
+// session settings and temporary tables carry over between them. A client
+// bound to openTx runs them inside it, using a savepoint for opts.Transaction.
+func runScript(ctx context.Context, db *sql.DB, openTx *sql.Tx, idQuery string, statements []string, d helper.SQLDialect, convert columnConverter, opts model.ScriptOptions) (*model.ScriptResponse, error) {
+	if openTx != nil {
+		return runScriptInTx(ctx, openTx, idQuery, statements, d, convert, opts)
+	}
+
+	conn, err := reserveConnection(ctx, db, idQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if !opts.Transaction {
+		return runStatements(ctx, conn, statements, d, convert, opts), nil
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// On failure the deferred Rollback undoes the script; the server may
+	// already have aborted the transaction, so its error is not useful.
+	defer tx.Rollback()
+
+	resp := runStatements(ctx, tx, statements, d, convert, opts)
+	if resp.Transaction == "" {
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		resp.Transaction = "committed"
+	}
+	return resp, nil
+}
+
+func runScriptInTx(ctx context.Context, tx *sql.Tx, idQuery string, statements []string, d helper.SQLDialect, convert columnConverter, opts model.ScriptOptions) (*model.ScriptResponse, error) {
+	q, _, err := pinConnection(ctx, nil, tx, idQuery)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.Transaction {
+		return runStatements(ctx, q, statements, d, convert, opts), nil
+	}
+
+	if _, err := q.ExecContext(ctx, "SAVEPOINT "+scriptSavepoint); err != nil {
+		return nil, err
+	}
+	resp := runStatements(ctx, q, statements, d, convert, opts)
+	if resp.Transaction != "" {
+		_, err = q.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+scriptSavepoint)
+	} else {
+		_, err = q.ExecContext(ctx, "RELEASE SAVEPOINT "+scriptSavepoint)
+		resp.Transaction = "committed"
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// runStatements runs each statement on q and collects its outcome. With
+// opts.Transaction it stops at the first error and marks the response as
+// rolled back, leaving the rollback itself to the caller.
+func runStatements(ctx context.Context, q queryer, statements []string, d helper.SQLDialect, convert columnConverter, opts model.ScriptOptions) *model.ScriptResponse {
+	resp := &model.ScriptResponse{Results: make([]model.StatementResult, 0, len(statements))}
+	failed, stopped := false, false
+	for _, stmt := range statements {
+		result := model.StatementResult{SQL: stmt}
+		if stopped {
+			result.Skipped = true
+			resp.Results = append(resp.Results, result)
+			continue
+		}
+
+		var rc rowCollector
+		start := time.Now()
+		stmtResp, err := runStatement(ctx, q, stmt, nil, d, &rc, convert)
+		result.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+		if err != nil {
+			result.Error = err.Error()
+			failed = true
+			stopped = opts.StopOnError || opts.Transaction || ctx.Err() != nil
+		} else {
+			result.Command = stmtResp.Command
+			result.Columns = rc.columns()
+			result.Fields = rc.fields
+			result.Rows = rc.rows
+			result.RowsAffected = stmtResp.RowsAffected
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	if failed && opts.Transaction {
+		resp.Transaction = "rolled_back"
+	}
+	return resp
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"vind/backend/helper"
+	"vind/backend/internal/model"
+)
+
+// ErrInvalidCursor is returned for table-data cursors that cannot be decoded
+// or do not fit the request.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor is the decoded form of the opaque next_cursor and prev_cursor
+// strings: the key values of the row a page starts after, or before.
+type cursor struct {
+	OrderBy string `json:"o"`
+	Prev    bool   `json:"p,omitempty"`
+	Values  []any  `json:"v"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var c cursor
+	if err := dec.Decode(&c); err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	for i, v := range c.Values {
+		if n, ok := v.(json.Number); ok {
+			if n64, err := n.Int64(); err == nil {
+				c.Values[i] = n64
+			} else if f, err := n.Float64(); err == nil {
+				c.Values[i] = f
+			}
+		}
+	}
+	return c, nil
+}
+
+// keyset pages through a table by the values of its sort columns rather
+// than by offset. The requested sort order is extended with the primary key
+// so that every row has a distinct position. Tables without a primary key
+// are read by offset only.
+type keyset struct {
+	d       helper.SQLDialect
+	quote   func(string) string
+	orderBy string
+	terms   []helper.OrderTerm
+	enabled bool
+	seek    *cursor // nil on a page read by offset
+}
+
+// pageBounds parses the limit and offset of req. Requests for all rows
+// have no limit, returned as -1, and start at the first row.
+func pageBounds(req model.TableDataRequest) (limit, offset int, err error) {
+	if req.All {
+		return -1, 0, nil
+	}
+	limit, err = strconv.Atoi(req.Limit)
+	if err != nil || limit < 0 {
+		return 0, 0, fmt.Errorf("invalid limit")
+	}
+	offset, err = strconv.Atoi(req.Offset)
+	if err != nil || offset < 0 {
+		return 0, 0, fmt.Errorf("invalid offset")
+	}
+	return limit, offset, nil
+}
+
+// limitSQL returns the LIMIT and OFFSET of a page and the values to bind to
+// them, or nothing for a page without a limit. Postgres placeholders are
+// numbered from firstArg.
+func limitSQL(d helper.SQLDialect, limit, offset, firstArg int) (string, []any) {
+	if limit < 0 {
+		return "", nil
+	}
+	return " LIMIT " + helper.Placeholder(d, firstArg) + " OFFSET " + helper.Placeholder(d, firstArg+1), []any{limit, offset}
+}
+
+func newKeyset(req model.TableDataRequest, order []helper.OrderTerm, source map[string]sourceColumn, d helper.SQLDialect, quote func(string) string) (*keyset, error) {
+	k := &keyset{d: d, quote: quote, orderBy: req.OrderBy, terms: order}
+
+	pk := sortedColumns(source, func(col sourceColumn) bool { return col.PrimaryKey })
+	for _, name := range pk {
+		if !hasTerm(k.terms, name) {
+			k.terms = append(k.terms, helper.OrderTerm{Column: name})
+		}
+	}
+	k.enabled = len(pk) > 0
+
+	if req.Cursor == "" || req.All {
+		return k, nil
+	}
+	if !k.enabled {
+		return nil, fmt.Errorf("%w: table has no primary key", ErrInvalidCursor)
+	}
+	c, err := decodeCursor(req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	if c.OrderBy != req.OrderBy || len(c.Values) != len(k.terms) {
+		return nil, fmt.Errorf("%w: cursor does not match order_by", ErrInvalidCursor)
+	}
+	k.seek = &c
+	return k, nil
+}
+
+func hasTerm(terms []helper.OrderTerm, column string) bool {
+	for _, t := range terms {
+		if t.Column == column {
+			return true
+		}
+	}
+	return false
+}
+
+// scanOrder is the order rows are read in: reversed when paging backwards,
+// so that LIMIT keeps the rows closest to the cursor.
+func (k *keyset) scanOrder() []helper.OrderTerm {
+	if k.seek == nil || !k.seek.Prev {
+		return k.terms
+	}
+	reversed := make([]helper.OrderTerm, len(k.terms))
+	for i, t := range k.terms {
+		reversed[i] = t.Reverse(k.d)
+	}
+	return reversed
+}
+
+// seekSQL returns the condition selecting rows past the cursor, or "" on a
+// page read by offset.
+func (k *keyset) seekSQL(firstArg int) (string, []any) {
+	if k.seek == nil {
+		return "", nil
+	}
+	return helper.KeysetSQL(k.scanOrder(), k.seek.Values, k.d, k.quote, firstArg)
+}
+
+// wrap puts a backwards page, read in reverse, back into the requested order.
+func (k *keyset) wrap(query string) string {
+	if k.seek == nil || !k.seek.Prev {
+		return query
+	}
+	return "SELECT * FROM (" + query + ") page ORDER BY " + helper.OrderBySQL(k.terms, k.d, k.quote)
+}
+
+// writer wraps w to remember the key values of the first and last row.
+func (k *keyset) writer(w RowWriter) *keysetWriter {
+	return &keysetWriter{RowWriter: w, keyset: k}
+}
+
+// keysetWriter is a RowWriter that records the key values of the first and
+// last row written, from which the page's cursors are made.
+type keysetWriter struct {
+	RowWriter
+	*keyset
+	index       []int // positions of the key columns in the result
+	first, last []any
+	rows        int
+}
+
+func (w *keysetWriter) WriteColumns(columns []model.ColumnMeta) error {
+	if w.enabled {
+		positions := map[string]int{}
+		for i, col := range columns {
+			positions[col.Name] = i
+		}
+		for _, t := range w.terms {
+			i, ok := positions[t.Column]
+			if !ok {
+				w.index = nil
+				break
+			}
+			w.index = append(w.index, i)
+		}
+	}
+	return w.RowWriter.WriteColumns(columns)
+}
+
+func (w *keysetWriter) WriteRow(values []any) error {
+	if err := w.RowWriter.WriteRow(values); err != nil {
+		return err
+	}
+	if w.index != nil {
+		key := make([]any, len(w.index))
+		for i, pos := range w.index {
+			key[i] = values[pos]
+		}
+		if w.rows == 0 {
+			w.first = key
+		}
+		w.last = key
+	}
+	w.rows++
+	return nil
+}
+
+// page finishes a page scanned through w with the scan's error err, filling
+// in its cursors. A page read forwards has a next page if it was full or
+// cut short and a previous one unless it started at the top; a page read
+// backwards the other way round.
+func (w *keysetWriter) page(err error, limit, offset int) (*model.TableDataResponse, error) {
+	if err != nil && !errors.Is(err, ErrRowLimit) {
+		return nil, err
+	}
+	resp := &model.TableDataResponse{}
+	if w.index == nil || w.rows == 0 {
+		return resp, err
+	}
+
+	back := w.seek != nil && w.seek.Prev
+	full := (limit >= 0 && w.rows >= limit) || err != nil
+	if back || full {
+		resp.NextCursor = encodeCursor(cursor{OrderBy: w.orderBy, Values: w.last})
+	}
+	if (back && full) || (!back && (w.seek != nil || offset > 0)) {
+		resp.PrevCursor = encodeCursor(cursor{OrderBy: w.orderBy, Prev: true, Values: w.first})
+	}
+	return resp, err
+}
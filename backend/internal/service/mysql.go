@@ -0,0 +1,1173 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"vind/backend/helper"
+	"vind/backend/internal/model"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type MySQLClient struct {
+	db *sql.DB
+	tx *sql.Tx // set on clients returned by BeginTx
+}
+
+func NewMySQLClient() *MySQLClient {
+	return &MySQLClient{}
+}
+
+// quoteMySQLIdentifier wraps an identifier in backticks, doubling any embedded backtick.
+func quoteMySQLIdentifier(name string) string {
+	return helper.QuoteIdentifier(helper.DialectMySQL, name)
+}
+
+func quoteMySQLIdentifiers(cols []string) []string {
+	quoted := make([]string, len(cols))
+	for i, col := range cols {
+		quoted[i] = quoteMySQLIdentifier(col)
+	}
+	return quoted
+}
+
+func (m *MySQLClient) Connect(dsn string) error {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return err
+	}
+	m.db = db
+	return db.Ping()
+}
+
+func (m *MySQLClient) Disconnect() error {
+	if m.db != nil {
+		return m.db.Close()
+	}
+	return nil
+}
+
+// conn returns the transaction the client is bound to, or its pool.
+func (m *MySQLClient) conn() queryer {
+	if m.tx != nil {
+		return m.tx
+	}
+	return m.db
+}
+
+// BeginTx opens a transaction and returns a client whose methods run inside
+// it. ctx must outlive the transaction: cancelling it rolls the transaction back.
+func (m *MySQLClient) BeginTx(ctx context.Context) (Tx, error) {
+	if m.tx != nil {
+		return nil, ErrInTransaction
+	}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &MySQLClient{db: m.db, tx: tx}, nil
+}
+
+// inTx runs fn with m if it is bound to a transaction, or else with a client
+// bound to a new one that is committed if fn succeeds.
+func (m *MySQLClient) inTx(ctx context.Context, fn func(m *MySQLClient) error) error {
+	if m.tx != nil {
+		return fn(m)
+	}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&MySQLClient{db: m.db, tx: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *MySQLClient) Commit() error {
+	if m.tx == nil {
+		return ErrNotInTransaction
+	}
+	return m.tx.Commit()
+}
+
+func (m *MySQLClient) Rollback() error {
+	if m.tx == nil {
+		return ErrNotInTransaction
+	}
+	return m.tx.Rollback()
+}
+
+// schemaOrCurrent resolves an empty schema to the database selected in the DSN.
+func (m *MySQLClient) schemaOrCurrent(ctx context.Context, schema string) (string, error) {
+	if schema != "" {
+		return schema, nil
+	}
+
+	var current sql.NullString
+	if err := m.conn().QueryRowContext(ctx, `SELECT DATABASE()`).Scan(&current); err != nil {
+		return "", err
+	}
+	if !current.Valid {
+		return "", errors.New("no database selected; specify a schema")
+	}
+	return current.String, nil
+}
+
+// qualify resolves the schema of table with schemaOrCurrent and checks its
+// names.
+func (m *MySQLClient) qualify(ctx context.Context, table helper.QualifiedName) (helper.QualifiedName, error) {
+	schema, err := m.schemaOrCurrent(ctx, table.Schema)
+	if err != nil {
+		return table, err
+	}
+	table.Schema = schema
+	return table, table.Check(helper.DialectMySQL)
+}
+
+func (m *MySQLClient) ListSchemas(ctx context.Context) ([]string, error) {
+	rows, err := m.conn().QueryContext(ctx, `SELECT schema_name FROM information_schema.schemata`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schemas []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, name)
+	}
+	return schemas, nil
+}
+
+func (m *MySQLClient) ListTables(ctx context.Context, schema string) ([]string, error) {
+	schema, err := m.schemaOrCurrent(ctx, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT table_name FROM information_schema.tables WHERE table_schema = ? ORDER BY table_name`
+	rows, err := m.conn().QueryContext(ctx, query, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, nil
+}
+
+func (m *MySQLClient) ListColumns(ctx context.Context, table helper.QualifiedName) ([]model.Column, error) {
+	table, err := m.qualify(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			c.column_name,
+			c.column_type,
+			c.is_nullable,
+			c.column_default,
+			-- Check if column is part of a unique constraint
+			EXISTS (
+				SELECT 1 FROM information_schema.table_constraints tc
+				JOIN information_schema.key_column_usage kcu
+					ON tc.constraint_schema = kcu.constraint_schema
+					AND tc.constraint_name = kcu.constraint_name
+					AND tc.table_name = kcu.table_name
+				WHERE tc.constraint_type = 'UNIQUE'
+					AND tc.table_schema = c.table_schema
+					AND tc.table_name = c.table_name
+					AND kcu.column_name = c.column_name
+			) AS is_unique,
+			-- Get foreign key reference if any
+			(
+				SELECT CONCAT('FOREIGN KEY (', kcu.column_name, ') REFERENCES ',
+					kcu.referenced_table_name, '(', kcu.referenced_column_name, ')')
+				FROM information_schema.key_column_usage kcu
+				WHERE kcu.table_schema = c.table_schema
+					AND kcu.table_name = c.table_name
+					AND kcu.column_name = c.column_name
+					AND kcu.referenced_table_name IS NOT NULL
+				LIMIT 1
+			) AS foreign_key
+		FROM information_schema.columns c
+		WHERE c.table_schema = ? AND c.table_name = ?
+		ORDER BY c.ordinal_position
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, table.Schema, table.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []model.Column
+	for rows.Next() {
+		var col model.Column
+		var nullable string
+		var defaultVal sql.NullString
+		var isUnique sql.NullBool
+		var foreignKey sql.NullString
+
+		err := rows.Scan(&col.Name, &col.Type, &nullable, &defaultVal, &isUnique, &foreignKey)
+		if err != nil {
+			return nil, err
+		}
+
+		col.Nullable = nullable == "YES"
+		if defaultVal.Valid {
+			col.Default = defaultVal.String
+		}
+		col.IsUnique = isUnique.Valid && isUnique.Bool
+		if foreignKey.Valid {
+			col.ForeignKey = foreignKey.String
+		}
+
+		columns = append(columns, col)
+	}
+
+	return columns, nil
+}
+
+func (m *MySQLClient) ExecuteQuery(ctx context.Context, sql string, args ...any) (*model.QueryResponse, error) {
+	return collectQuery(func(w RowWriter) (*model.QueryResponse, error) { return m.StreamQuery(ctx, sql, w, args...) })
+}
+
+// StreamQuery runs sql with args bound to its placeholders and passes its
+// result set, if any, to w row by row.
+// The returned response carries the command and rows affected but no rows.
+func (m *MySQLClient) StreamQuery(ctx context.Context, sql string, w RowWriter, args ...any) (*model.QueryResponse, error) {
+	q, release, err := pinConnection(ctx, m.db, m.tx, "SELECT CONNECTION_ID()")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	convert := fromQuery(ctx, sql, helper.DialectMySQL, mysqlColumn, m.queryTable)
+	return runStatement(ctx, q, sql, args, helper.DialectMySQL, w, convert)
+}
+
+// ExecuteScript runs statements one after another on the same connection.
+func (m *MySQLClient) ExecuteScript(ctx context.Context, statements []string, opts model.ScriptOptions) (*model.ScriptResponse, error) {
+	return runScript(ctx, m.db, m.tx, "SELECT CONNECTION_ID()", statements, helper.DialectMySQL, mysqlColumn, opts)
+}
+
+func (m *MySQLClient) Dialect() helper.SQLDialect {
+	return helper.DialectMySQL
+}
+
+// CancelQuery kills the statement running on the connection with the given
+// ID, as reported through WithBackendIDReporter, leaving the connection open.
+func (m *MySQLClient) CancelQuery(ctx context.Context, backendID int64) error {
+	_, err := m.db.ExecContext(ctx, fmt.Sprintf("KILL QUERY %d", backendID))
+	return err
+}
+
+// queryTable resolves a table named in a query, see tableLookup.
+func (m *MySQLClient) queryTable(ctx context.Context, name helper.QualifiedName) (helper.QualifiedName, map[string]sourceColumn, bool) {
+	table, err := m.qualify(ctx, name)
+	if err != nil {
+		return table, nil, false
+	}
+	columns, err := m.sourceColumns(ctx, table.Schema, table.Name)
+	return table, columns, err == nil && len(columns) > 0
+}
+
+// sourceColumns describes each column of schema.table.
+func (m *MySQLClient) sourceColumns(ctx context.Context, schema, table string) (map[string]sourceColumn, error) {
+	query := `
+		SELECT column_name, data_type, is_nullable = 'YES', column_key = 'PRI', ordinal_position
+		FROM information_schema.columns
+		WHERE table_schema = ? AND table_name = ?
+	`
+	return scanSourceColumns(m.conn().QueryContext(ctx, query, schema, table))
+}
+
+// estimateRows returns the server's estimate of the rows of from matching
+// where: the table's TABLE_ROWS when unfiltered and the EXPLAIN row count,
+// scaled by its filtered percentage, otherwise.
+func (m *MySQLClient) estimateRows(schema, table, from, where string, args []any) rowEstimator {
+	return func(ctx context.Context) (int64, bool, error) {
+		if where == "" {
+			var n sql.NullInt64
+			err := m.conn().QueryRowContext(ctx, `SELECT table_rows FROM information_schema.tables WHERE table_schema = ? AND table_name = ?`, schema, table).Scan(&n)
+			return n.Int64, err == nil && n.Valid, err
+		}
+
+		rows, err := m.conn().QueryContext(ctx, "EXPLAIN SELECT 1 FROM "+from+" WHERE "+where, args...)
+		if err != nil {
+			return 0, false, err
+		}
+		defer rows.Close()
+		columns, err := rows.Columns()
+		if err != nil || !rows.Next() {
+			return 0, false, err
+		}
+		values := make([]sql.NullString, len(columns))
+		pointers := make([]any, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return 0, false, err
+		}
+
+		estimate, filtered, ok := 0.0, 100.0, false
+		for i, col := range columns {
+			switch strings.ToLower(col) {
+			case "rows":
+				estimate, err = strconv.ParseFloat(values[i].String, 64)
+				ok = err == nil
+			case "filtered":
+				if f, err := strconv.ParseFloat(values[i].String, 64); err == nil {
+					filtered = f
+				}
+			}
+		}
+		return int64(estimate * filtered / 100), ok, nil
+	}
+}
+
+func (m *MySQLClient) GetTableData(ctx context.Context, req model.TableDataRequest) (*model.TableDataResponse, error) {
+	return collectTable(func(w RowWriter) (*model.TableDataResponse, error) { return m.StreamTableData(ctx, req, w) })
+}
+
+// StreamTableData reads a page of table rows and passes them to w row by row.
+// The returned response carries the page's cursors and row count but no rows.
+func (m *MySQLClient) StreamTableData(ctx context.Context, req model.TableDataRequest, w RowWriter) (*model.TableDataResponse, error) {
+	table, err := m.qualify(ctx, helper.QualifiedName{Schema: req.Schema, Name: req.Table})
+	if err != nil {
+		return nil, err
+	}
+	schema := table.Schema
+
+	limitInt, offsetInt, err := pageBounds(req)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := m.sourceColumns(ctx, schema, req.Table)
+	if err != nil {
+		return nil, err
+	}
+	where, args, err := helper.FilterSQL(req.Filters, hasColumn(source), helper.DialectMySQL, quoteMySQLIdentifier, 1)
+	if err != nil {
+		return nil, err
+	}
+	order, err := helper.ParseOrderBy(req.OrderBy, hasColumn(source))
+	if err != nil {
+		return nil, err
+	}
+	page, err := newKeyset(req, order, source, helper.DialectMySQL, quoteMySQLIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	proj, err := newProjection(req, source, page.terms, quoteMySQLIdentifier, mysqlSize)
+	if err != nil {
+		return nil, err
+	}
+	countWhere, countArgs := where, args
+	if seek, seekArgs := page.seekSQL(len(args) + 1); seek != "" {
+		if where != "" {
+			where += " AND "
+		}
+		where += seek
+		args = append(args, seekArgs...)
+		offsetInt = 0
+	}
+
+	from := table.Quote(helper.DialectMySQL)
+	query := "SELECT " + proj.list + " FROM " + from
+	if where != "" {
+		query += " WHERE " + where
+	}
+	if order := page.scanOrder(); len(order) > 0 {
+		query += " ORDER BY " + helper.OrderBySQL(order, helper.DialectMySQL, quoteMySQLIdentifier)
+	}
+
+	limit, limitArgs := limitSQL(helper.DialectMySQL, limitInt, offsetInt, len(args)+1)
+	query += limit
+	args = append(args, limitArgs...)
+	query = page.wrap(query)
+
+	rows, err := m.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pw := page.writer(w)
+	err = scanRows(rows, pw, proj.converter(fromTable(mysqlColumn, schema, req.Table, source)))
+	rows.Close()
+	resp, err := pw.page(err, limitInt, offsetInt)
+	if resp == nil {
+		return nil, err
+	}
+
+	estimate := m.estimateRows(schema, req.Table, from, countWhere, countArgs)
+	if err := countRows(ctx, m.conn(), m.tx != nil, req.Count, from, countWhere, countArgs, estimate, resp); err != nil {
+		return nil, err
+	}
+	return resp, err
+}
+
+// rowTable describes schema.table for reading and editing single rows.
+func (m *MySQLClient) rowTable(ctx context.Context, table helper.QualifiedName) (*rowTable, error) {
+	table, err := m.qualify(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	source, err := m.sourceColumns(ctx, table.Schema, table.Name)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT index_name, column_name
+		FROM information_schema.statistics
+		WHERE table_schema = ? AND table_name = ? AND non_unique = 0
+		ORDER BY index_name, seq_in_index
+	`
+	uniques, err := scanUniqueKeys(m.conn().QueryContext(ctx, query, table.Schema, table.Name))
+	if err != nil {
+		return nil, err
+	}
+	return &rowTable{
+		q:      m.conn(),
+		schema: table.Schema,
+		from:   table.Quote(helper.DialectMySQL),
+		source: source,
+		key:    rowKey(source, uniques),
+		d:      helper.DialectMySQL,
+		quote:  quoteMySQLIdentifier,
+	}, nil
+}
+
+// GetCellValue reads one column of the row of schema.table whose primary
+// or unique key holds the values in key.
+func (m *MySQLClient) GetCellValue(ctx context.Context, table helper.QualifiedName, column string, key map[string]any) (*model.CellValue, error) {
+	t, err := m.rowTable(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	return t.readCell(ctx, column, key, fromTable(mysqlColumn, t.schema, table.Name, t.source))
+}
+
+// UpdateRow updates the single row edit identifies by its key.
+func (m *MySQLClient) UpdateRow(ctx context.Context, edit model.RowEdit) (*model.RowEditResult, error) {
+	t, err := m.rowTable(ctx, helper.QualifiedName{Schema: edit.Schema, Name: edit.Table})
+	if err != nil {
+		return nil, err
+	}
+	return t.update(ctx, edit)
+}
+
+// DeleteRow deletes the single row edit identifies by its key.
+func (m *MySQLClient) DeleteRow(ctx context.Context, edit model.RowEdit) error {
+	t, err := m.rowTable(ctx, helper.QualifiedName{Schema: edit.Schema, Name: edit.Table})
+	if err != nil {
+		return err
+	}
+	return t.delete(ctx, edit)
+}
+
+// mysqlSize measures the types OmitLarge leaves out.
+func mysqlSize(quoted string, col sourceColumn) string {
+	switch strings.ToLower(col.Type) {
+	case "blob", "mediumblob", "longblob", "text", "mediumtext", "longtext", "json":
+		return "LENGTH(" + quoted + ")"
+	}
+	return ""
+}
+
+// InsertRecord inserts data into table and returns the row as written.
+// MySQL cannot return the rows it writes, so the row is read back by its
+// key, see mysqlInsertRow.
+func (m *MySQLClient) InsertRecord(ctx context.Context, table helper.QualifiedName, data map[string]any) (*model.QueryResponse, error) {
+	t, err := m.rowTable(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := insertSQL(t.from, helper.DialectMySQL, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	return mysqlInsertRow(ctx, t, query, args, data, t.key, fromTable(mysqlColumn, t.schema, table.Name, t.source))
+}
+
+// UpdateRecord sets data on the rows matching where and returns them as
+// updated. MySQL cannot return the rows it writes, so the keys of the rows
+// are read, locking them, before the update, and the rows read back by them
+// after it, with the key columns data sets at their new values. The rows of
+// tables without a key are counted but not returned.
+func (m *MySQLClient) UpdateRecord(ctx context.Context, table helper.QualifiedName, data, where map[string]any) (*model.QueryResponse, error) {
+	var resp *model.QueryResponse
+	err := m.inTx(ctx, func(m *MySQLClient) error {
+		t, err := m.rowTable(ctx, table)
+		if err != nil {
+			return err
+		}
+		query, args, err := batchUpdate(t.from, helper.DialectMySQL, model.BatchUpdate{Data: data, Where: where})
+		if err != nil {
+			return err
+		}
+		keys, err := mysqlLockKeys(ctx, t, where)
+		if err != nil {
+			return err
+		}
+		res, err := t.q.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			for name := range key {
+				if v, ok := data[name]; ok {
+					key[name] = v
+				}
+			}
+		}
+		var rc rowCollector
+		if err := t.readRows(ctx, keys, &rc, fromTable(mysqlColumn, t.schema, table.Name, t.source)); err != nil {
+			return err
+		}
+		resp = writtenRows(&rc, n)
+		return nil
+	})
+	return resp, err
+}
+
+// DeleteRecord deletes the rows matching conditions and returns them. MySQL
+// cannot return the rows it writes, so they are read, locking them, before
+// the delete.
+func (m *MySQLClient) DeleteRecord(ctx context.Context, table helper.QualifiedName, conditions map[string]any) (*model.QueryResponse, error) {
+	var resp *model.QueryResponse
+	err := m.inTx(ctx, func(m *MySQLClient) error {
+		t, err := m.rowTable(ctx, table)
+		if err != nil {
+			return err
+		}
+		query, args, err := batchDelete(t.from, helper.DialectMySQL, conditions)
+		if err != nil {
+			return err
+		}
+
+		// The delete's WHERE clause and arguments select the rows it deletes.
+		rows, err := t.q.QueryContext(ctx, "SELECT * FROM "+t.from+strings.TrimPrefix(query, "DELETE FROM "+t.from)+" FOR UPDATE", args...)
+		if err != nil {
+			return err
+		}
+		var rc rowCollector
+		err = scanRows(rows, &rc, fromTable(mysqlColumn, t.schema, table.Name, t.source))
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		res, err := t.q.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		resp = writtenRows(&rc, n)
+		return nil
+	})
+	return resp, err
+}
+
+// mysqlInsertRow runs query, an insert of data into t, and reads the row
+// back by the key columns: by the values data gives them or, for one left
+// out, the auto-increment id MySQL generated. If the key is unknown no row
+// is returned.
+func mysqlInsertRow(ctx context.Context, t *rowTable, query string, args []any, data map[string]any, key []string, convert columnConverter) (*model.QueryResponse, error) {
+	res, err := t.q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	// ON DUPLICATE KEY UPDATE counts an updated row twice.
+	n = min(n, 1)
+
+	values := map[string]any{}
+	var missing []string
+	for _, name := range key {
+		if v := data[name]; v != nil {
+			values[name] = v
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 1 {
+		if id, err := res.LastInsertId(); err == nil && id != 0 {
+			values[missing[0]] = id
+			missing = nil
+		}
+	}
+
+	var rc rowCollector
+	if len(key) > 0 && len(missing) == 0 {
+		if err := t.readRows(ctx, []map[string]any{values}, &rc, convert); err != nil {
+			return nil, err
+		}
+	}
+	return writtenRows(&rc, n), nil
+}
+
+// mysqlLockKeys reads the key values of the rows of t matching where,
+// locking the rows until the transaction ends. It returns nil if t has no
+// key.
+func mysqlLockKeys(ctx context.Context, t *rowTable, where map[string]any) ([]map[string]any, error) {
+	if t.key == nil {
+		return nil, nil
+	}
+	cond, args, err := batchConditions(helper.DialectMySQL, where, 1)
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]string, len(t.key))
+	for i, name := range t.key {
+		columns[i] = t.quote(name)
+	}
+	rows, err := t.q.QueryContext(ctx, "SELECT "+strings.Join(columns, ", ")+" FROM "+t.from+" WHERE "+cond+" FOR UPDATE", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []map[string]any
+	for rows.Next() {
+		values := make([]any, len(t.key))
+		pointers := make([]any, len(t.key))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		key := make(map[string]any, len(t.key))
+		for i, name := range t.key {
+			key[name] = values[i]
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// keyConstraints returns the columns of each primary key and unique
+// constraint of table, and the name of its primary key, which MySQL always
+// calls PRIMARY.
+func (m *MySQLClient) keyConstraints(ctx context.Context, table helper.QualifiedName) (map[string][]string, string, error) {
+	query := `
+		SELECT tc.constraint_name, tc.constraint_type = 'PRIMARY KEY', kcu.column_name
+		FROM information_schema.table_constraints tc
+			JOIN information_schema.key_column_usage kcu
+				ON kcu.constraint_schema = tc.constraint_schema
+				AND kcu.constraint_name = tc.constraint_name
+				AND kcu.table_name = tc.table_name
+		WHERE tc.table_schema = ? AND tc.table_name = ? AND tc.constraint_type IN ('PRIMARY KEY', 'UNIQUE')
+		ORDER BY tc.constraint_name, kcu.ordinal_position
+	`
+	return scanConstraintColumns(m.conn().QueryContext(ctx, query, table.Schema, table.Name))
+}
+
+// ExecuteBatch runs the inserts, updates and deletes of req in one
+// transaction.
+func (m *MySQLClient) ExecuteBatch(ctx context.Context, req model.BatchRequest) (*model.BatchResponse, error) {
+	table, err := m.qualify(ctx, helper.QualifiedName{Schema: req.Schema, Name: req.Table})
+	if err != nil {
+		return nil, err
+	}
+	var upsert *upsertTarget
+	if req.Upsert != nil {
+		keys, primary, err := m.keyConstraints(ctx, table)
+		if err != nil {
+			return nil, err
+		}
+		if upsert, err = newMySQLUpsertTarget(keys, primary, *req.Upsert); err != nil {
+			return nil, err
+		}
+	}
+	return runBatch(ctx, m.db, m.tx, table.Quote(helper.DialectMySQL), helper.DialectMySQL, req, upsert)
+}
+
+// UpsertRecord inserts data, or updates the row it conflicts with on the key
+// chosen by upsert, and returns the row as written. The row is read back by
+// that key, so it is returned even if it was kept unchanged.
+func (m *MySQLClient) UpsertRecord(ctx context.Context, table helper.QualifiedName, data map[string]any, upsert model.Upsert) (*model.QueryResponse, error) {
+	t, err := m.rowTable(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	keys, primary, err := m.keyConstraints(ctx, helper.QualifiedName{Schema: t.schema, Name: table.Name})
+	if err != nil {
+		return nil, err
+	}
+	target, err := newMySQLUpsertTarget(keys, primary, upsert)
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := insertSQL(t.from, helper.DialectMySQL, data, target)
+	if err != nil {
+		return nil, err
+	}
+	return mysqlInsertRow(ctx, t, query, args, data, target.key, fromTable(mysqlColumn, t.schema, table.Name, t.source))
+}
+
+// ImportCSV loads the CSV file src into table with multi-row INSERTs, in
+// one transaction.
+func (m *MySQLClient) ImportCSV(ctx context.Context, table helper.QualifiedName, src io.Reader, opts model.ImportOptions) (*model.ImportResponse, error) {
+	table, err := m.qualify(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := m.ListColumns(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	return importCSV(ctx, m.db, m.tx, columns, src, opts, insertRows(helper.DialectMySQL, table.Quote(helper.DialectMySQL)))
+}
+
+func (m *MySQLClient) CreateTable(ctx context.Context, table helper.QualifiedName, columns []model.ColumnDef) error {
+	if table.Name == "" || len(columns) == 0 {
+		return fmt.Errorf("invalid table definition")
+	}
+	table, err := m.qualify(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	var colDefs []string
+	var pkCols []string
+
+	for _, col := range columns {
+		if err := helper.CheckIdentifiers(helper.DialectMySQL, col.Name); err != nil {
+			return err
+		}
+		colParts := []string{quoteMySQLIdentifier(col.Name), col.Type}
+		if col.NotNull {
+			colParts = append(colParts, "NOT NULL")
+		}
+		if col.Default != "" {
+			colParts = append(colParts, "DEFAULT "+col.Default)
+		}
+		colDefs = append(colDefs, strings.Join(colParts, " "))
+
+		if col.PrimaryKey {
+			pkCols = append(pkCols, quoteMySQLIdentifier(col.Name))
+		}
+	}
+
+	if len(pkCols) > 0 {
+		colDefs = append(colDefs, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(pkCols, ", ")))
+	}
+
+	query := fmt.Sprintf(
+		"CREATE TABLE %s (%s)",
+		table.Quote(helper.DialectMySQL),
+		strings.Join(colDefs, ", "),
+	)
+
+	_, err = m.conn().ExecContext(ctx, query)
+	return err
+}
+
+// mysqlColumnDefinition is what MODIFY COLUMN has to restate to keep a
+// column as it is, since MySQL replaces the whole definition.
+type mysqlColumnDefinition struct {
+	Type    string
+	NotNull bool
+	Default *string
+	// DefaultExpr marks a default that is an expression, such as
+	// CURRENT_TIMESTAMP, rather than a literal value.
+	DefaultExpr bool
+	// Extra holds the attributes information_schema lists in its extra
+	// column that MODIFY COLUMN would drop, such as AUTO_INCREMENT and
+	// ON UPDATE CURRENT_TIMESTAMP.
+	Extra   string
+	Comment string
+}
+
+// columnDefinition returns the current definition of a column.
+func (m *MySQLClient) columnDefinition(ctx context.Context, table helper.QualifiedName, columnName string) (mysqlColumnDefinition, error) {
+	var (
+		def      mysqlColumnDefinition
+		nullable string
+		extra    string
+		dflt     sql.NullString
+	)
+	err := m.conn().QueryRowContext(ctx, `
+		SELECT column_type, is_nullable, column_default, extra, column_comment
+		FROM information_schema.columns
+		WHERE table_schema = ? AND table_name = ? AND column_name = ?
+	`, table.Schema, table.Name, columnName).Scan(&def.Type, &nullable, &dflt, &extra, &def.Comment)
+	if errors.Is(err, sql.ErrNoRows) {
+		return def, fmt.Errorf("column %s not found in table %s", columnName, table)
+	}
+	if err != nil {
+		return def, err
+	}
+	def.NotNull = nullable == "NO"
+	if dflt.Valid {
+		def.Default = &dflt.String
+	}
+
+	def.DefaultExpr = strings.Contains(strings.ToUpper(extra), "DEFAULT_GENERATED")
+	def.Extra = mysqlColumnExtra(extra)
+	return def, nil
+}
+
+// modifyColumnClause returns the MODIFY COLUMN clause that applies op to a
+// column defined as def, keeping whatever op does not change.
+func modifyColumnClause(def mysqlColumnDefinition, op model.AlterTableOperation) string {
+	if op.Type != "" {
+		def.Type = op.Type
+	}
+	if op.NotNull != nil {
+		def.NotNull = *op.NotNull
+	}
+
+	stmt := fmt.Sprintf("MODIFY COLUMN %s %s", quoteMySQLIdentifier(op.ColumnName), def.Type)
+	if def.NotNull {
+		stmt += " NOT NULL"
+	} else {
+		stmt += " NULL"
+	}
+	switch {
+	case op.Default != "":
+		stmt += " DEFAULT " + op.Default
+	case def.Default == nil:
+	case def.DefaultExpr && isMySQLTimestampDefault(*def.Default):
+		stmt += " DEFAULT " + *def.Default
+	case def.DefaultExpr:
+		stmt += " DEFAULT (" + *def.Default + ")"
+	default:
+		stmt += " DEFAULT " + helper.QuoteLiteral(helper.DialectMySQL, *def.Default)
+	}
+	if def.Extra != "" {
+		stmt += " " + def.Extra
+	}
+	if def.Comment != "" {
+		stmt += " COMMENT " + helper.QuoteLiteral(helper.DialectMySQL, def.Comment)
+	}
+	return stmt
+}
+
+// mysqlColumnExtra picks the attributes out of information_schema's extra
+// column that a column definition can restate.
+func mysqlColumnExtra(extra string) string {
+	var kept []string
+	fields := strings.Fields(extra)
+	for i := 0; i < len(fields); i++ {
+		switch {
+		case strings.EqualFold(fields[i], "auto_increment"):
+			kept = append(kept, "AUTO_INCREMENT")
+		case strings.EqualFold(fields[i], "on") && i+2 < len(fields) && strings.EqualFold(fields[i+1], "update"):
+			kept = append(kept, "ON UPDATE "+fields[i+2])
+			i += 2
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// isMySQLTimestampDefault reports whether expr is CURRENT_TIMESTAMP or one
+// of its synonyms, the only expression defaults MySQL accepts without
+// parentheses.
+func isMySQLTimestampDefault(expr string) bool {
+	name, _, _ := strings.Cut(strings.ToUpper(expr), "(")
+	switch name {
+	case "CURRENT_TIMESTAMP", "NOW", "LOCALTIME", "LOCALTIMESTAMP":
+		return true
+	}
+	return false
+}
+
+func (m *MySQLClient) AlterTable(ctx context.Context, table helper.QualifiedName, ops []model.AlterTableOperation) error {
+	if table.Name == "" || len(ops) == 0 {
+		return fmt.Errorf("invalid alter table request")
+	}
+	table, err := m.qualify(ctx, table)
+	if err != nil {
+		return err
+	}
+	if err := checkAlterColumns(helper.DialectMySQL, ops); err != nil {
+		return err
+	}
+
+	var statements []string
+	for _, op := range ops {
+		switch op.Action {
+		case "add_column":
+			if op.ColumnName == "" || op.Type == "" {
+				return fmt.Errorf("add_column requires column_name and type")
+			}
+			stmt := fmt.Sprintf("ADD COLUMN %s %s", quoteMySQLIdentifier(op.ColumnName), op.Type)
+			statements = append(statements, stmt)
+
+		case "drop_column":
+			if op.ColumnName == "" {
+				return fmt.Errorf("drop_column requires column_name")
+			}
+			stmt := fmt.Sprintf("DROP COLUMN %s", quoteMySQLIdentifier(op.ColumnName))
+			statements = append(statements, stmt)
+
+		case "rename_column":
+			if op.ColumnName == "" || op.NewName == "" {
+				return fmt.Errorf("rename_column requires column_name and new_name")
+			}
+			stmt := fmt.Sprintf("RENAME COLUMN %s TO %s", quoteMySQLIdentifier(op.ColumnName), quoteMySQLIdentifier(op.NewName))
+			statements = append(statements, stmt)
+
+		case "alter_column":
+			if op.ColumnName == "" {
+				return fmt.Errorf("alter_column requires column_name")
+			}
+			if op.Type == "" && op.NotNull == nil {
+				if op.Default != "" {
+					statements = append(statements, fmt.Sprintf("ALTER COLUMN %s SET DEFAULT %s", quoteMySQLIdentifier(op.ColumnName), op.Default))
+				}
+				continue
+			}
+
+			def, err := m.columnDefinition(ctx, table, op.ColumnName)
+			if err != nil {
+				return err
+			}
+			statements = append(statements, modifyColumnClause(def, op))
+
+		default:
+			return fmt.Errorf("unsupported action: %s", op.Action)
+		}
+	}
+
+	if len(statements) == 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf("ALTER TABLE %s %s", table.Quote(helper.DialectMySQL), strings.Join(statements, ", "))
+	_, err = m.conn().ExecContext(ctx, query)
+	return err
+}
+
+func (m *MySQLClient) DropTable(ctx context.Context, table helper.QualifiedName, cascade bool) error {
+	if table.Name == "" {
+		return fmt.Errorf("table name is required")
+	}
+	table, err := m.qualify(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	// MySQL accepts CASCADE for portability but ignores it.
+	query := fmt.Sprintf("DROP TABLE %s", table.Quote(helper.DialectMySQL))
+	if cascade {
+		query += " CASCADE"
+	}
+
+	_, err = m.conn().ExecContext(ctx, query)
+	return err
+}
+
+func (m *MySQLClient) AddConstraint(ctx context.Context, params model.AddConstraintParams) error {
+	if params.TableName == "" || params.ConstraintName == "" || params.Type == "" {
+		return fmt.Errorf("table_name, constraint_name, and type are required")
+	}
+	table, err := m.qualify(ctx, helper.QualifiedName{Schema: params.Schema, Name: params.TableName})
+	if err != nil {
+		return err
+	}
+	if err := checkConstraintNames(helper.DialectMySQL, params); err != nil {
+		return err
+	}
+
+	var query string
+
+	switch strings.ToUpper(params.Type) {
+	case "PRIMARY KEY":
+		if len(params.Columns) == 0 {
+			return fmt.Errorf("columns are required for PRIMARY KEY")
+		}
+		// MySQL always names the primary key PRIMARY; the given name is ignored.
+		query = fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s PRIMARY KEY (%s)",
+			table.Quote(helper.DialectMySQL),
+			quoteMySQLIdentifier(params.ConstraintName),
+			strings.Join(quoteMySQLIdentifiers(params.Columns), ", "),
+		)
+	case "UNIQUE":
+		if len(params.Columns) == 0 {
+			return fmt.Errorf("columns are required for UNIQUE constraint")
+		}
+		query = fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s UNIQUE (%s)",
+			table.Quote(helper.DialectMySQL),
+			quoteMySQLIdentifier(params.ConstraintName),
+			strings.Join(quoteMySQLIdentifiers(params.Columns), ", "),
+		)
+	case "FOREIGN KEY":
+		if len(params.Columns) == 0 || params.RefTable == "" || len(params.RefColumns) == 0 {
+			return fmt.Errorf("columns, ref_table, and ref_columns are required for FOREIGN KEY")
+		}
+		ref := helper.QualifiedName{Schema: params.RefSchema, Name: params.RefTable}
+		if ref.Schema == "" {
+			ref.Schema = table.Schema
+		}
+		query = fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+			table.Quote(helper.DialectMySQL),
+			quoteMySQLIdentifier(params.ConstraintName),
+			strings.Join(quoteMySQLIdentifiers(params.Columns), ", "),
+			ref.Quote(helper.DialectMySQL),
+			strings.Join(quoteMySQLIdentifiers(params.RefColumns), ", "),
+		)
+		if params.OnDelete != "" {
+			query += fmt.Sprintf(" ON DELETE %s", params.OnDelete)
+		}
+		if params.OnUpdate != "" {
+			query += fmt.Sprintf(" ON UPDATE %s", params.OnUpdate)
+		}
+	case "CHECK":
+		if params.CheckExpr == "" {
+			return fmt.Errorf("check_expr is required for CHECK constraint")
+		}
+		query = fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s CHECK (%s)",
+			table.Quote(helper.DialectMySQL),
+			quoteMySQLIdentifier(params.ConstraintName),
+			params.CheckExpr,
+		)
+	default:
+		return fmt.Errorf("unsupported constraint type: %s", params.Type)
+	}
+
+	_, err = m.conn().ExecContext(ctx, query)
+	return err
+}
+
+func (m *MySQLClient) DropConstraint(ctx context.Context, table helper.QualifiedName, constraintName string, cascade bool) error {
+	if table.Name == "" || constraintName == "" {
+		return fmt.Errorf("table_name and constraint_name are required")
+	}
+	table, err := m.qualify(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	var constraintType string
+	err = m.conn().QueryRowContext(ctx, `
+		SELECT constraint_type
+		FROM information_schema.table_constraints
+		WHERE table_schema = ? AND table_name = ? AND constraint_name = ?
+	`, table.Schema, table.Name, constraintName).Scan(&constraintType)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("constraint %s not found on table %s", constraintName, table)
+	}
+	if err != nil {
+		return err
+	}
+
+	// MySQL has no generic DROP CONSTRAINT before 8.0.19 and no CASCADE, so
+	// the clause depends on what kind of constraint is being removed.
+	var clause string
+	switch constraintType {
+	case "PRIMARY KEY":
+		clause = "DROP PRIMARY KEY"
+	case "FOREIGN KEY":
+		clause = "DROP FOREIGN KEY " + quoteMySQLIdentifier(constraintName)
+	case "UNIQUE":
+		clause = "DROP INDEX " + quoteMySQLIdentifier(constraintName)
+	case "CHECK":
+		clause = "DROP CHECK " + quoteMySQLIdentifier(constraintName)
+	default:
+		return fmt.Errorf("unsupported constraint type: %s", constraintType)
+	}
+
+	query := fmt.Sprintf("ALTER TABLE %s %s", table.Quote(helper.DialectMySQL), clause)
+	_, err = m.conn().ExecContext(ctx, query)
+	return err
+}
+
+// mysqlConstraintCodes maps MySQL constraint types onto the single-letter
+// codes returned by PostgreSQL, so clients see the same values for every driver.
+var mysqlConstraintCodes = map[string]string{
+	"PRIMARY KEY": "p",
+	"UNIQUE":      "u",
+	"FOREIGN KEY": "f",
+	"CHECK":       "c",
+}
+
+func (m *MySQLClient) ListConstraints(ctx context.Context, table helper.QualifiedName) ([]model.ConstraintInfo, error) {
+	table, err := m.qualify(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT tc.constraint_name,
+		       tc.constraint_type,
+		       tc.table_name,
+		       CASE tc.constraint_type
+		           WHEN 'CHECK' THEN CONCAT('CHECK (', MAX(cc.check_clause), ')')
+		           WHEN 'FOREIGN KEY' THEN CONCAT('FOREIGN KEY (',
+		               GROUP_CONCAT(kcu.column_name ORDER BY kcu.ordinal_position SEPARATOR ', '),
+		               ') REFERENCES ', MAX(kcu.referenced_table_name), '(',
+		               GROUP_CONCAT(kcu.referenced_column_name ORDER BY kcu.ordinal_position SEPARATOR ', '), ')')
+		           ELSE CONCAT(tc.constraint_type, ' (',
+		               GROUP_CONCAT(kcu.column_name ORDER BY kcu.ordinal_position SEPARATOR ', '), ')')
+		       END AS definition
+		FROM information_schema.table_constraints tc
+			LEFT JOIN information_schema.key_column_usage kcu
+				ON kcu.constraint_schema = tc.constraint_schema
+				AND kcu.constraint_name = tc.constraint_name
+				AND kcu.table_name = tc.table_name
+			LEFT JOIN information_schema.check_constraints cc
+				ON cc.constraint_schema = tc.constraint_schema
+				AND cc.constraint_name = tc.constraint_name
+		WHERE tc.table_schema = ? AND tc.table_name = ?
+		GROUP BY tc.constraint_name, tc.constraint_type, tc.table_name
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, table.Schema, table.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var constraints []model.ConstraintInfo
+	for rows.Next() {
+		var cInfo model.ConstraintInfo
+		var definition sql.NullString
+		if err := rows.Scan(&cInfo.ConstraintName, &cInfo.ConstraintType, &cInfo.TableName, &definition); err != nil {
+			return nil, err
+		}
+		if code, ok := mysqlConstraintCodes[cInfo.ConstraintType]; ok {
+			cInfo.ConstraintType = code
+		}
+		cInfo.Definition = definition.String
+		constraints = append(constraints, cInfo)
+	}
+	return constraints, nil
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"testing"
+
+	"vind/backend/internal/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModifyColumnClause(t *testing.T) {
+	str := func(s string) *string { return &s }
+	notNull := false
+
+	tests := []struct {
+		name string
+		def  mysqlColumnDefinition
+		op   model.AlterTableOperation
+		want string
+	}{
+		{
+			name: "auto increment keeps its attributes after a type change",
+			def:  mysqlColumnDefinition{Type: "int", NotNull: true, Extra: mysqlColumnExtra("auto_increment"), Comment: "row id"},
+			op:   model.AlterTableOperation{ColumnName: "id", Type: "bigint"},
+			want: "MODIFY COLUMN `id` bigint NOT NULL AUTO_INCREMENT COMMENT 'row id'",
+		},
+		{
+			name: "literal default",
+			def:  mysqlColumnDefinition{Type: "varchar(20)", NotNull: true, Default: str("it's new")},
+			op:   model.AlterTableOperation{ColumnName: "status", Type: "varchar(40)"},
+			want: "MODIFY COLUMN `status` varchar(40) NOT NULL DEFAULT 'it''s new'",
+		},
+		{
+			name: "timestamp default and on update",
+			def: mysqlColumnDefinition{
+				Type: "timestamp", NotNull: true, Default: str("CURRENT_TIMESTAMP"),
+				DefaultExpr: true, Extra: mysqlColumnExtra("DEFAULT_GENERATED on update CURRENT_TIMESTAMP"),
+			},
+			op:   model.AlterTableOperation{ColumnName: "updated_at", NotNull: &notNull},
+			want: "MODIFY COLUMN `updated_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP",
+		},
+		{
+			name: "expression default",
+			def:  mysqlColumnDefinition{Type: "char(36)", Default: str("uuid()"), DefaultExpr: true, Extra: mysqlColumnExtra("DEFAULT_GENERATED")},
+			op:   model.AlterTableOperation{ColumnName: "token", Type: "varchar(36)"},
+			want: "MODIFY COLUMN `token` varchar(36) NULL DEFAULT (uuid())",
+		},
+		{
+			name: "requested default replaces the current one",
+			def:  mysqlColumnDefinition{Type: "int", Default: str("0")},
+			op:   model.AlterTableOperation{ColumnName: "qty", Type: "bigint", Default: "1"},
+			want: "MODIFY COLUMN `qty` bigint NULL DEFAULT 1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, modifyColumnClause(tc.def, tc.op))
+		})
+	}
+}
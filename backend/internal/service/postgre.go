@@ -1,9 +1,13 @@
 package service
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"regexp"
 	"strconv"
 	"strings"
 	"vind/backend/helper"
@@ -15,6 +19,7 @@ import (
 
 type PostgresClient struct {
 	db *sql.DB
+	tx *sql.Tx // set on clients returned by BeginTx
 }
 
 func NewPostgresClient() *PostgresClient {
@@ -37,8 +42,51 @@ func (p *PostgresClient) Disconnect() error {
 	return nil
 }
 
-func (p *PostgresClient) ListSchemas() ([]string, error) {
-	rows, err := p.db.Query(`SELECT schema_name FROM information_schema.schemata`)
+// conn returns the transaction the client is bound to, or its pool.
+func (p *PostgresClient) conn() queryer {
+	if p.tx != nil {
+		return p.tx
+	}
+	return p.db
+}
+
+// BeginTx opens a transaction and returns a client whose methods run inside
+// it. ctx must outlive the transaction: cancelling it rolls the transaction back.
+func (p *PostgresClient) BeginTx(ctx context.Context) (Tx, error) {
+	if p.tx != nil {
+		return nil, ErrInTransaction
+	}
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresClient{db: p.db, tx: tx}, nil
+}
+
+func (p *PostgresClient) Commit() error {
+	if p.tx == nil {
+		return ErrNotInTransaction
+	}
+	return p.tx.Commit()
+}
+
+func (p *PostgresClient) Rollback() error {
+	if p.tx == nil {
+		return ErrNotInTransaction
+	}
+	return p.tx.Rollback()
+}
+
+// qualify fills in the default schema of table and checks its names.
+func (p *PostgresClient) qualify(table helper.QualifiedName) (helper.QualifiedName, error) {
+	if table.Schema == "" {
+		table.Schema = "public"
+	}
+	return table, table.Check(helper.DialectPostgres)
+}
+
+func (p *PostgresClient) ListSchemas(ctx context.Context) ([]string, error) {
+	rows, err := p.conn().QueryContext(ctx, `SELECT schema_name FROM information_schema.schemata`)
 	if err != nil {
 		return nil, err
 	}
@@ -55,13 +103,13 @@ func (p *PostgresClient) ListSchemas() ([]string, error) {
 	return schemas, nil
 }
 
-func (p *PostgresClient) ListTables(schema string) ([]string, error) {
+func (p *PostgresClient) ListTables(ctx context.Context, schema string) ([]string, error) {
 	if schema == "" {
 		schema = "public"
 	}
 
 	query := `SELECT table_name FROM information_schema.tables WHERE table_schema = $1`
-	rows, err := p.db.Query(query, schema)
+	rows, err := p.conn().QueryContext(ctx, query, schema)
 	if err != nil {
 		return nil, err
 	}
@@ -78,7 +126,12 @@ func (p *PostgresClient) ListTables(schema string) ([]string, error) {
 	return tables, nil
 }
 
-func (p *PostgresClient) ListColumns(schema, table string) ([]model.Column, error) {
+func (p *PostgresClient) ListColumns(ctx context.Context, table helper.QualifiedName) ([]model.Column, error) {
+	table, err := p.qualify(table)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		SELECT 
 			c.column_name,
@@ -115,7 +168,7 @@ func (p *PostgresClient) ListColumns(schema, table string) ([]model.Column, erro
 		ORDER BY c.ordinal_position;
 	`
 
-	rows, err := p.db.Query(query, schema, table)
+	rows, err := p.conn().QueryContext(ctx, query, table.Schema, table.Name)
 	if err != nil {
 		return nil, err
 	}
@@ -149,250 +202,427 @@ func (p *PostgresClient) ListColumns(schema, table string) ([]model.Column, erro
 	return columns, nil
 }
 
-func (p *PostgresClient) ExecuteQuery(sql string) ([]string, [][]any, error) {
-	trimmed := strings.TrimSpace(strings.ToUpper(sql))
-	if !strings.HasPrefix(trimmed, "SELECT") {
-		// For non-SELECT queries, use Exec
-		_, err := p.db.Exec(sql)
-		if err != nil {
-			return nil, nil, err
-		}
-		// Return no columns or rows
-		return nil, nil, nil
+func (p *PostgresClient) ExecuteQuery(ctx context.Context, sql string, args ...any) (*model.QueryResponse, error) {
+	return collectQuery(func(w RowWriter) (*model.QueryResponse, error) { return p.StreamQuery(ctx, sql, w, args...) })
+}
+
+// StreamQuery runs sql with args bound to its placeholders and passes its
+// result set, if any, to w row by row.
+// The returned response carries the command and rows affected but no rows.
+func (p *PostgresClient) StreamQuery(ctx context.Context, sql string, w RowWriter, args ...any) (*model.QueryResponse, error) {
+	q, release, err := pinConnection(ctx, p.db, p.tx, "SELECT pg_backend_pid()")
+	if err != nil {
+		return nil, err
 	}
+	defer release()
+
+	convert := fromQuery(ctx, sql, helper.DialectPostgres, postgresColumn, p.queryTable)
+	return runStatement(ctx, q, sql, args, helper.DialectPostgres, w, convert)
+}
+
+// ExecuteScript runs statements one after another on the same connection.
+func (p *PostgresClient) ExecuteScript(ctx context.Context, statements []string, opts model.ScriptOptions) (*model.ScriptResponse, error) {
+	return runScript(ctx, p.db, p.tx, "SELECT pg_backend_pid()", statements, helper.DialectPostgres, postgresColumn, opts)
+}
 
-	// SELECT query
-	rows, err := p.db.Query(sql)
+func (p *PostgresClient) Dialect() helper.SQLDialect {
+	return helper.DialectPostgres
+}
+
+// CancelQuery asks the server to cancel whatever the backend with the given
+// PID is running, as reported through WithBackendIDReporter.
+func (p *PostgresClient) CancelQuery(ctx context.Context, backendID int64) error {
+	var cancelled bool
+	if err := p.db.QueryRowContext(ctx, `SELECT pg_cancel_backend($1)`, backendID).Scan(&cancelled); err != nil {
+		return err
+	}
+	if !cancelled {
+		return fmt.Errorf("backend %d is not running a query", backendID)
+	}
+	return nil
+}
+
+// queryTable resolves a table named in a query through the search path, as
+// the server does. Unlike a regclass cast, to_regclass does not fail for
+// unknown names, which would abort an open transaction.
+func (p *PostgresClient) queryTable(ctx context.Context, name helper.QualifiedName) (helper.QualifiedName, map[string]sourceColumn, bool) {
+	var table helper.QualifiedName
+	err := p.conn().QueryRowContext(ctx, `
+		SELECT n.nspname, c.relname
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.oid = to_regclass($1)
+	`, name.Quote(helper.DialectPostgres)).Scan(&table.Schema, &table.Name)
 	if err != nil {
-		return nil, nil, err
+		return table, nil, false
 	}
-	defer rows.Close()
+	columns, err := p.sourceColumns(ctx, table.Schema, table.Name)
+	return table, columns, err == nil && len(columns) > 0
+}
 
-	columns, err := rows.Columns()
+// sourceColumns describes each column of schema.table. It looks the table
+// up with to_regclass, since a failed regclass cast would abort the
+// transaction of a transaction session.
+func (p *PostgresClient) sourceColumns(ctx context.Context, schema, table string) (map[string]sourceColumn, error) {
+	var oid sql.NullInt64
+	err := p.conn().QueryRowContext(ctx, `SELECT to_regclass(format('%I.%I', $1::text, $2::text))::oid`, schema, table).Scan(&oid)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if !oid.Valid {
+		return nil, fmt.Errorf("%w: %s.%s", ErrTableNotFound, schema, table)
 	}
+	query := `
+		SELECT
+			a.attname,
+			format_type(a.atttypid, NULL),
+			NOT a.attnotnull,
+			COALESCE(a.attnum = ANY(i.indkey), false),
+			a.attnum
+		FROM pg_attribute a
+		LEFT JOIN pg_index i ON i.indrelid = a.attrelid AND i.indisprimary
+		WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped
+	`
+	return scanSourceColumns(p.conn().QueryContext(ctx, query, oid.Int64))
+}
 
-	var results [][]any
-	for rows.Next() {
-		values := make([]any, len(columns))
-		pointers := make([]any, len(columns))
-		for i := range values {
-			pointers[i] = &values[i]
+// estimateRows returns the planner's estimate of the rows of from matching
+// where: the table's reltuples when unfiltered and the EXPLAIN row count
+// otherwise.
+func (p *PostgresClient) estimateRows(schema, table, from, where string, args []any) rowEstimator {
+	return func(ctx context.Context) (int64, bool, error) {
+		if where == "" {
+			var n float64
+			err := p.conn().QueryRowContext(ctx, `SELECT reltuples FROM pg_class WHERE oid = format('%I.%I', $1::text, $2::text)::regclass`, schema, table).Scan(&n)
+			// reltuples is -1, or 0 before Postgres 14, until the table is first analyzed.
+			return int64(n), err == nil && n > 0, err
+		}
+
+		var plan []byte
+		if err := p.conn().QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) SELECT 1 FROM "+from+" WHERE "+where, args...).Scan(&plan); err != nil {
+			return 0, false, err
+		}
+		var explained []struct {
+			Plan struct {
+				Rows float64 `json:"Plan Rows"`
+			}
 		}
-		if err := rows.Scan(pointers...); err != nil {
-			return nil, nil, err
+		if err := json.Unmarshal(plan, &explained); err != nil || len(explained) == 0 {
+			return 0, false, err
 		}
-		results = append(results, values)
+		return int64(explained[0].Plan.Rows), true, nil
 	}
+}
 
-	return columns, results, nil
+func (p *PostgresClient) GetTableData(ctx context.Context, req model.TableDataRequest) (*model.TableDataResponse, error) {
+	return collectTable(func(w RowWriter) (*model.TableDataResponse, error) { return p.StreamTableData(ctx, req, w) })
 }
 
-func (p *PostgresClient) GetTableData(req model.TableDataRequest) ([]string, [][]any, error) {
-	if !helper.IsValidIdentifier(req.Schema) || !helper.IsValidIdentifier(req.Table) {
-		return nil, nil, errors.New("invalid schema or table name")
+// StreamTableData reads a page of table rows and passes them to w row by row.
+// The returned response carries the page's cursors and row count but no rows.
+func (p *PostgresClient) StreamTableData(ctx context.Context, req model.TableDataRequest, w RowWriter) (*model.TableDataResponse, error) {
+	table, err := p.qualify(helper.QualifiedName{Schema: req.Schema, Name: req.Table})
+	if err != nil {
+		return nil, err
 	}
+	req.Schema = table.Schema
 
-	limitInt, err := strconv.Atoi(req.Limit)
-	if err != nil || limitInt < 0 {
-		return nil, nil, fmt.Errorf("invalid limit")
+	limitInt, offsetInt, err := pageBounds(req)
+	if err != nil {
+		return nil, err
 	}
 
-	offsetInt, err := strconv.Atoi(req.Offset)
-	if err != nil || offsetInt < 0 {
-		return nil, nil, fmt.Errorf("invalid offset")
+	source, err := p.sourceColumns(ctx, req.Schema, req.Table)
+	if err != nil {
+		return nil, err
 	}
-
-	query := fmt.Sprintf(`SELECT * FROM "%s"."%s"`, req.Schema, req.Table)
-	args := []any{}
-	conditions := []string{}
-
-	for _, f := range req.Filters {
-		parts := strings.SplitN(f, ":", 3)
-		if len(parts) != 3 {
-			continue
-		}
-		col := pq.QuoteIdentifier(parts[0])
-		op := strings.ToUpper(parts[1])
-		val := parts[2]
-
-		switch op {
-		case "LIKE", "=", ">", "<", ">=", "<=":
-			conditions = append(conditions, fmt.Sprintf("%s %s $%d", col, op, len(args)+1))
-			args = append(args, val)
-		default:
-			continue // skip unsupported operator
+	where, args, err := helper.FilterSQL(req.Filters, hasColumn(source), helper.DialectPostgres, pq.QuoteIdentifier, 1)
+	if err != nil {
+		return nil, err
+	}
+	order, err := helper.ParseOrderBy(req.OrderBy, hasColumn(source))
+	if err != nil {
+		return nil, err
+	}
+	page, err := newKeyset(req, order, source, helper.DialectPostgres, pq.QuoteIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	proj, err := newProjection(req, source, page.terms, pq.QuoteIdentifier, postgresSize)
+	if err != nil {
+		return nil, err
+	}
+	countWhere, countArgs := where, args
+	if seek, seekArgs := page.seekSQL(len(args) + 1); seek != "" {
+		if where != "" {
+			where += " AND "
 		}
+		where += seek
+		args = append(args, seekArgs...)
+		offsetInt = 0
 	}
 
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
+	from := table.Quote(helper.DialectPostgres)
+	query := "SELECT " + proj.list + " FROM " + from
+	if where != "" {
+		query += " WHERE " + where
 	}
-
-	if req.OrderBy != "" && helper.IsValidIdentifier(req.OrderBy) {
-		query += " ORDER BY " + pq.QuoteIdentifier(req.OrderBy)
+	if order := page.scanOrder(); len(order) > 0 {
+		query += " ORDER BY " + helper.OrderBySQL(order, helper.DialectPostgres, pq.QuoteIdentifier)
 	}
 
-	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
-	args = append(args, limitInt, offsetInt)
+	limit, limitArgs := limitSQL(helper.DialectPostgres, limitInt, offsetInt, len(args)+1)
+	query += limit
+	args = append(args, limitArgs...)
+	query = page.wrap(query)
 
-	rows, err := p.db.Query(query, args...)
+	rows, err := p.conn().QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	defer rows.Close()
 
-	columns, err := rows.Columns()
-	if err != nil {
-		return nil, nil, err
+	pw := page.writer(w)
+	err = scanRows(rows, pw, proj.converter(fromTable(postgresColumn, req.Schema, req.Table, source)))
+	rows.Close()
+	resp, err := pw.page(err, limitInt, offsetInt)
+	if resp == nil {
+		return nil, err
 	}
 
-	var results [][]any
-	for rows.Next() {
-		values := make([]any, len(columns))
-		pointers := make([]any, len(columns))
-		for i := range values {
-			pointers[i] = &values[i]
-		}
-		if err := rows.Scan(pointers...); err != nil {
-			return nil, nil, err
-		}
-		results = append(results, values)
+	estimate := p.estimateRows(req.Schema, req.Table, from, countWhere, countArgs)
+	if err := countRows(ctx, p.conn(), p.tx != nil, req.Count, from, countWhere, countArgs, estimate, resp); err != nil {
+		return nil, err
 	}
+	return resp, err
+}
 
-	return columns, results, nil
+// rowTable describes schema.table for reading and editing single rows.
+func (p *PostgresClient) rowTable(ctx context.Context, table helper.QualifiedName) (*rowTable, error) {
+	table, err := p.qualify(table)
+	if err != nil {
+		return nil, err
+	}
+	source, err := p.sourceColumns(ctx, table.Schema, table.Name)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT i.indexrelid::text, a.attname
+		FROM pg_index i
+		CROSS JOIN LATERAL unnest(i.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord)
+		LEFT JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+		WHERE i.indrelid = to_regclass(format('%I.%I', $1::text, $2::text))
+			AND i.indisunique AND i.indpred IS NULL
+		ORDER BY i.indexrelid, k.ord
+	`
+	uniques, err := scanUniqueKeys(p.conn().QueryContext(ctx, query, table.Schema, table.Name))
+	if err != nil {
+		return nil, err
+	}
+	return &rowTable{
+		q:       p.conn(),
+		schema:  table.Schema,
+		from:    table.Quote(helper.DialectPostgres),
+		source:  source,
+		key:     rowKey(source, uniques),
+		d:       helper.DialectPostgres,
+		quote:   pq.QuoteIdentifier,
+		version: "xmin::text",
+	}, nil
 }
 
-func (p *PostgresClient) InsertRecord(schema, table string, data map[string]any) error {
-	if len(data) == 0 {
-		return errors.New("no data to insert")
+// GetCellValue reads one column of the row of schema.table whose primary
+// or unique key holds the values in key.
+func (p *PostgresClient) GetCellValue(ctx context.Context, table helper.QualifiedName, column string, key map[string]any) (*model.CellValue, error) {
+	t, err := p.rowTable(ctx, table)
+	if err != nil {
+		return nil, err
 	}
+	return t.readCell(ctx, column, key, fromTable(postgresColumn, t.schema, table.Name, t.source))
+}
 
-	columns := []string{}
-	placeholders := []string{}
-	values := []any{}
+// UpdateRow updates the single row edit identifies by its key.
+func (p *PostgresClient) UpdateRow(ctx context.Context, edit model.RowEdit) (*model.RowEditResult, error) {
+	t, err := p.rowTable(ctx, helper.QualifiedName{Schema: edit.Schema, Name: edit.Table})
+	if err != nil {
+		return nil, err
+	}
+	return t.update(ctx, edit)
+}
 
-	i := 1
-	for col, val := range data {
-		columns = append(columns, col)
-		placeholders = append(placeholders, fmt.Sprintf("$%d", i))
-		values = append(values, val)
-		i++
+// DeleteRow deletes the single row edit identifies by its key.
+func (p *PostgresClient) DeleteRow(ctx context.Context, edit model.RowEdit) error {
+	t, err := p.rowTable(ctx, helper.QualifiedName{Schema: edit.Schema, Name: edit.Table})
+	if err != nil {
+		return err
 	}
+	return t.delete(ctx, edit)
+}
 
-	query := fmt.Sprintf(
-		`INSERT INTO "%s"."%s" (%s) VALUES (%s)`,
-		schema,
-		table,
-		strings.Join(columns, ", "),
-		strings.Join(placeholders, ", "),
-	)
+// postgresSize measures the types OmitLarge leaves out.
+func postgresSize(quoted string, col sourceColumn) string {
+	switch col.Type {
+	case "bytea":
+		return "octet_length(" + quoted + ")"
+	case "json", "jsonb", "xml":
+		return "octet_length(" + quoted + "::text)"
+	}
+	return ""
+}
 
-	_, err := p.db.Exec(query, values...)
-	return err
+// InsertRecord inserts data into table and returns the row as written, with
+// generated and default values filled in.
+func (p *PostgresClient) InsertRecord(ctx context.Context, table helper.QualifiedName, data map[string]any) (*model.QueryResponse, error) {
+	return p.writeRecords(ctx, table, func(t *rowTable) (string, []any, error) {
+		return insertSQL(t.from, helper.DialectPostgres, data, nil)
+	})
+}
+
+// UpdateRecord sets data on the rows matching where and returns them as
+// updated.
+func (p *PostgresClient) UpdateRecord(ctx context.Context, table helper.QualifiedName, data, where map[string]any) (*model.QueryResponse, error) {
+	return p.writeRecords(ctx, table, func(t *rowTable) (string, []any, error) {
+		return batchUpdate(t.from, helper.DialectPostgres, model.BatchUpdate{Data: data, Where: where})
+	})
+}
+
+// DeleteRecord deletes the rows matching conditions and returns them.
+func (p *PostgresClient) DeleteRecord(ctx context.Context, table helper.QualifiedName, conditions map[string]any) (*model.QueryResponse, error) {
+	return p.writeRecords(ctx, table, func(t *rowTable) (string, []any, error) {
+		return batchDelete(t.from, helper.DialectPostgres, conditions)
+	})
 }
 
-func (p *PostgresClient) UpdateRecord(schema, table string, data, where map[string]any) (int64, error) {
-	if len(data) == 0 {
-		return 0, errors.New("no fields to update")
+// writeRecords runs the INSERT, UPDATE or DELETE on table that build returns
+// and returns the rows it wrote.
+func (p *PostgresClient) writeRecords(ctx context.Context, table helper.QualifiedName, build func(t *rowTable) (string, []any, error)) (*model.QueryResponse, error) {
+	t, err := p.rowTable(ctx, table)
+	if err != nil {
+		return nil, err
 	}
-	if len(where) == 0 {
-		return 0, errors.New("missing WHERE clause — dangerous update prevented")
+	query, args, err := build(t)
+	if err != nil {
+		return nil, err
 	}
+	return t.returning(ctx, query, args, fromTable(postgresColumn, t.schema, table.Name, t.source))
+}
 
-	setClauses := []string{}
-	whereClauses := []string{}
-	values := []any{}
-	i := 1
+// keyConstraints returns the columns of each primary key and unique
+// constraint of table, and the name of its primary key.
+func (p *PostgresClient) keyConstraints(ctx context.Context, table helper.QualifiedName) (map[string][]string, string, error) {
+	query := `
+		SELECT con.conname, con.contype = 'p', a.attname
+		FROM pg_constraint con
+			JOIN pg_class tbl ON tbl.oid = con.conrelid
+			JOIN pg_namespace ns ON ns.oid = tbl.relnamespace
+			CROSS JOIN LATERAL unnest(con.conkey) WITH ORDINALITY AS k(attnum, ord)
+			JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+		WHERE ns.nspname = $1 AND tbl.relname = $2 AND con.contype IN ('p', 'u')
+		ORDER BY con.conname, k.ord
+	`
+	return scanConstraintColumns(p.conn().QueryContext(ctx, query, table.Schema, table.Name))
+}
 
-	// Build SET clause
-	for col, val := range data {
-		if !helper.IsValidIdentifier(col) {
-			return 0, fmt.Errorf("invalid column name: %s", col)
-		}
-		setClauses = append(setClauses, fmt.Sprintf(`"%s" = $%d`, col, i))
-		values = append(values, val)
-		i++
+// ExecuteBatch runs the inserts, updates and deletes of req in one
+// transaction.
+func (p *PostgresClient) ExecuteBatch(ctx context.Context, req model.BatchRequest) (*model.BatchResponse, error) {
+	table, err := p.qualify(helper.QualifiedName{Schema: req.Schema, Name: req.Table})
+	if err != nil {
+		return nil, err
 	}
-
-	// Build WHERE clause
-	for col, val := range where {
-		if !helper.IsValidIdentifier(col) {
-			return 0, fmt.Errorf("invalid column name: %s", col)
+	var upsert *upsertTarget
+	if req.Upsert != nil {
+		keys, primary, err := p.keyConstraints(ctx, table)
+		if err != nil {
+			return nil, err
+		}
+		if upsert, err = newUpsertTarget(keys, primary, *req.Upsert); err != nil {
+			return nil, err
 		}
-		whereClauses = append(whereClauses, fmt.Sprintf(`"%s" = $%d`, col, i))
-		values = append(values, val)
-		i++
 	}
+	return runBatch(ctx, p.db, p.tx, table.Quote(helper.DialectPostgres), helper.DialectPostgres, req, upsert)
+}
 
-	query := fmt.Sprintf(
-		`UPDATE "%s"."%s" SET %s WHERE %s`,
-		schema,
-		table,
-		strings.Join(setClauses, ", "),
-		strings.Join(whereClauses, " AND "),
-	)
+// UpsertRecord inserts data, or updates the row it conflicts with on the key
+// chosen by upsert, and returns the row as written. No row is returned if
+// the existing row is kept.
+func (p *PostgresClient) UpsertRecord(ctx context.Context, table helper.QualifiedName, data map[string]any, upsert model.Upsert) (*model.QueryResponse, error) {
+	return p.writeRecords(ctx, table, func(t *rowTable) (string, []any, error) {
+		keys, primary, err := p.keyConstraints(ctx, helper.QualifiedName{Schema: t.schema, Name: table.Name})
+		if err != nil {
+			return "", nil, err
+		}
+		target, err := newUpsertTarget(keys, primary, upsert)
+		if err != nil {
+			return "", nil, err
+		}
+		return insertSQL(t.from, helper.DialectPostgres, data, target)
+	})
+}
 
-	result, err := p.db.Exec(query, values...)
+// ImportCSV loads the CSV file src into table with COPY, in one transaction.
+func (p *PostgresClient) ImportCSV(ctx context.Context, table helper.QualifiedName, src io.Reader, opts model.ImportOptions) (*model.ImportResponse, error) {
+	table, err := p.qualify(table)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-
-	rowsAffected, err := result.RowsAffected()
+	columns, err := p.ListColumns(ctx, table)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-
-	return rowsAffected, nil
+	return importCSV(ctx, p.db, p.tx, columns, src, opts, func(ctx context.Context, tx *sql.Tx, names []string, rows [][]any) error {
+		return copyRows(ctx, tx, table, names, rows)
+	})
 }
 
-func (p *PostgresClient) DeleteRecord(schema, table string, conditions map[string]any) (int64, error) {
-	if schema == "" {
-		schema = "public"
-	}
+// copyLine finds the line of the data a COPY error occurred on in the
+// error's context, e.g. "COPY items, line 3, column id: ...".
+var copyLine = regexp.MustCompile(`^COPY .*, line (\d+)`)
 
-	if table == "" || len(conditions) == 0 {
-		return 0, fmt.Errorf("table name and conditions are required")
+// copyRows loads rows into table with COPY FROM STDIN. An error names the
+// row it occurred on where the server reports it.
+func copyRows(ctx context.Context, tx *sql.Tx, table helper.QualifiedName, columns []string, rows [][]any) error {
+	stmt, err := tx.PrepareContext(ctx, pq.CopyInSchema(table.Schema, table.Name, columns...))
+	if err != nil {
+		return err
 	}
+	defer stmt.Close()
 
-	query := fmt.Sprintf(`DELETE FROM "%s"."%s" WHERE `, schema, table)
-
-	var args []any
-	var conds []string
-	i := 1
-	for col, val := range conditions {
-		if !helper.IsValidIdentifier(col) {
-			return 0, fmt.Errorf("invalid column name: %s", col)
+	for _, row := range rows {
+		if _, err = stmt.ExecContext(ctx, row...); err != nil {
+			break
 		}
-		conds = append(conds, fmt.Sprintf(`"%s" = $%d`, col, i))
-		args = append(args, val)
-		i++
 	}
-	query += strings.Join(conds, " AND ")
-
-	result, err := p.db.Exec(query, args...)
-	if err != nil {
-		return 0, fmt.Errorf("failed to execute delete: %w", err)
+	if err == nil {
+		_, err = stmt.ExecContext(ctx)
 	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return 0, err
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		if m := copyLine.FindStringSubmatch(pqErr.Where); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			return &importRowError{row: n - 1, err: err}
+		}
 	}
-
-	return rowsAffected, nil
+	return err
 }
 
-func (c *PostgresClient) CreateTable(tableName string, columns []model.ColumnDef) error {
-	if tableName == "" || len(columns) == 0 {
+func (c *PostgresClient) CreateTable(ctx context.Context, table helper.QualifiedName, columns []model.ColumnDef) error {
+	if table.Name == "" || len(columns) == 0 {
 		return fmt.Errorf("invalid table definition")
 	}
+	table, err := c.qualify(table)
+	if err != nil {
+		return err
+	}
 
 	var colDefs []string
 	var pkCols []string
 
 	for _, col := range columns {
+		if err := helper.CheckIdentifiers(helper.DialectPostgres, col.Name); err != nil {
+			return err
+		}
 		colParts := []string{pq.QuoteIdentifier(col.Name), col.Type}
 		if col.NotNull {
 			colParts = append(colParts, "NOT NULL")
@@ -413,18 +643,25 @@ func (c *PostgresClient) CreateTable(tableName string, columns []model.ColumnDef
 
 	query := fmt.Sprintf(
 		"CREATE TABLE %s (%s);",
-		pq.QuoteIdentifier(tableName),
+		table.Quote(helper.DialectPostgres),
 		strings.Join(colDefs, ", "),
 	)
 
-	_, err := c.db.Exec(query)
+	_, err = c.conn().ExecContext(ctx, query)
 	return err
 }
 
-func (c *PostgresClient) AlterTable(tableName string, ops []model.AlterTableOperation) error {
-	if tableName == "" || len(ops) == 0 {
+func (c *PostgresClient) AlterTable(ctx context.Context, table helper.QualifiedName, ops []model.AlterTableOperation) error {
+	if table.Name == "" || len(ops) == 0 {
 		return fmt.Errorf("invalid alter table request")
 	}
+	table, err := c.qualify(table)
+	if err != nil {
+		return err
+	}
+	if err := checkAlterColumns(helper.DialectPostgres, ops); err != nil {
+		return err
+	}
 
 	var statements []string
 	for _, op := range ops {
@@ -474,23 +711,27 @@ func (c *PostgresClient) AlterTable(tableName string, ops []model.AlterTableOper
 		}
 	}
 
-	query := fmt.Sprintf("ALTER TABLE %s %s;", pq.QuoteIdentifier(tableName), strings.Join(statements, ", "))
-	_, err := c.db.Exec(query)
+	query := fmt.Sprintf("ALTER TABLE %s %s;", table.Quote(helper.DialectPostgres), strings.Join(statements, ", "))
+	_, err = c.conn().ExecContext(ctx, query)
 	return err
 }
 
-func (c *PostgresClient) DropTable(tableName string, cascade bool) error {
-	if tableName == "" {
+func (c *PostgresClient) DropTable(ctx context.Context, table helper.QualifiedName, cascade bool) error {
+	if table.Name == "" {
 		return fmt.Errorf("table name is required")
 	}
+	table, err := c.qualify(table)
+	if err != nil {
+		return err
+	}
 
-	query := fmt.Sprintf("DROP TABLE %s", pq.QuoteIdentifier(tableName))
+	query := fmt.Sprintf("DROP TABLE %s", table.Quote(helper.DialectPostgres))
 	if cascade {
 		query += " CASCADE"
 	}
 	query += ";"
 
-	_, err := c.db.Exec(query)
+	_, err = c.conn().ExecContext(ctx, query)
 	return err
 }
 
@@ -502,10 +743,17 @@ func quoteIdentifiers(cols []string) []string {
 	return quoted
 }
 
-func (c *PostgresClient) AddConstraint(params model.AddConstraintParams) error {
+func (c *PostgresClient) AddConstraint(ctx context.Context, params model.AddConstraintParams) error {
 	if params.TableName == "" || params.ConstraintName == "" || params.Type == "" {
 		return fmt.Errorf("table_name, constraint_name, and type are required")
 	}
+	table, err := c.qualify(helper.QualifiedName{Schema: params.Schema, Name: params.TableName})
+	if err != nil {
+		return err
+	}
+	if err := checkConstraintNames(helper.DialectPostgres, params); err != nil {
+		return err
+	}
 
 	var query string
 
@@ -515,7 +763,7 @@ func (c *PostgresClient) AddConstraint(params model.AddConstraintParams) error {
 			return fmt.Errorf("columns are required for PRIMARY KEY")
 		}
 		query = fmt.Sprintf(`ALTER TABLE %s ADD CONSTRAINT %s PRIMARY KEY (%s);`,
-			pq.QuoteIdentifier(params.TableName),
+			table.Quote(helper.DialectPostgres),
 			pq.QuoteIdentifier(params.ConstraintName),
 			strings.Join(quoteIdentifiers(params.Columns), ", "),
 		)
@@ -524,7 +772,7 @@ func (c *PostgresClient) AddConstraint(params model.AddConstraintParams) error {
 			return fmt.Errorf("columns are required for UNIQUE constraint")
 		}
 		query = fmt.Sprintf(`ALTER TABLE %s ADD CONSTRAINT %s UNIQUE (%s);`,
-			pq.QuoteIdentifier(params.TableName),
+			table.Quote(helper.DialectPostgres),
 			pq.QuoteIdentifier(params.ConstraintName),
 			strings.Join(quoteIdentifiers(params.Columns), ", "),
 		)
@@ -532,11 +780,15 @@ func (c *PostgresClient) AddConstraint(params model.AddConstraintParams) error {
 		if len(params.Columns) == 0 || params.RefTable == "" || len(params.RefColumns) == 0 {
 			return fmt.Errorf("columns, ref_table, and ref_columns are required for FOREIGN KEY")
 		}
+		ref := helper.QualifiedName{Schema: params.RefSchema, Name: params.RefTable}
+		if ref.Schema == "" {
+			ref.Schema = table.Schema
+		}
 		query = fmt.Sprintf(`ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)`,
-			pq.QuoteIdentifier(params.TableName),
+			table.Quote(helper.DialectPostgres),
 			pq.QuoteIdentifier(params.ConstraintName),
 			strings.Join(quoteIdentifiers(params.Columns), ", "),
-			pq.QuoteIdentifier(params.RefTable),
+			ref.Quote(helper.DialectPostgres),
 			strings.Join(quoteIdentifiers(params.RefColumns), ", "),
 		)
 		if params.OnDelete != "" {
@@ -551,7 +803,7 @@ func (c *PostgresClient) AddConstraint(params model.AddConstraintParams) error {
 			return fmt.Errorf("check_expr is required for CHECK constraint")
 		}
 		query = fmt.Sprintf(`ALTER TABLE %s ADD CONSTRAINT %s CHECK (%s);`,
-			pq.QuoteIdentifier(params.TableName),
+			table.Quote(helper.DialectPostgres),
 			pq.QuoteIdentifier(params.ConstraintName),
 			params.CheckExpr,
 		)
@@ -559,17 +811,21 @@ func (c *PostgresClient) AddConstraint(params model.AddConstraintParams) error {
 		return fmt.Errorf("unsupported constraint type: %s", params.Type)
 	}
 
-	_, err := c.db.Exec(query)
+	_, err = c.conn().ExecContext(ctx, query)
 	return err
 }
 
-func (c *PostgresClient) DropConstraint(tableName, constraintName string, cascade bool) error {
-	if tableName == "" || constraintName == "" {
+func (c *PostgresClient) DropConstraint(ctx context.Context, table helper.QualifiedName, constraintName string, cascade bool) error {
+	if table.Name == "" || constraintName == "" {
 		return fmt.Errorf("table_name and constraint_name are required")
 	}
+	table, err := c.qualify(table)
+	if err != nil {
+		return err
+	}
 
 	query := fmt.Sprintf(`ALTER TABLE %s DROP CONSTRAINT %s`,
-		pq.QuoteIdentifier(tableName),
+		table.Quote(helper.DialectPostgres),
 		pq.QuoteIdentifier(constraintName),
 	)
 	if cascade {
@@ -577,11 +833,16 @@ func (c *PostgresClient) DropConstraint(tableName, constraintName string, cascad
 	}
 	query += ";"
 
-	_, err := c.db.Exec(query)
+	_, err = c.conn().ExecContext(ctx, query)
 	return err
 }
 
-func (c *PostgresClient) ListConstraints(tableName string) ([]model.ConstraintInfo, error) {
+func (c *PostgresClient) ListConstraints(ctx context.Context, table helper.QualifiedName) ([]model.ConstraintInfo, error) {
+	table, err := c.qualify(table)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		SELECT con.conname AS constraint_name,
 		       con.contype AS constraint_type,
@@ -590,10 +851,10 @@ func (c *PostgresClient) ListConstraints(tableName string) ([]model.ConstraintIn
 		FROM pg_constraint con
 			JOIN pg_class tbl ON con.conrelid = tbl.oid
 			JOIN pg_namespace ns ON ns.oid = tbl.relnamespace
-		WHERE tbl.relname = $1;
+		WHERE ns.nspname = $1 AND tbl.relname = $2;
 	`
 
-	rows, err := c.db.Query(query, tableName)
+	rows, err := c.conn().QueryContext(ctx, query, table.Schema, table.Name)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,403 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"vind/backend/internal/model"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrProfileNotFound = errors.New("profile not found")
+	ErrInvalidProfile  = errors.New("invalid profile")
+)
+
+// profileKeyIterations is the PBKDF2 work factor for new stores; stores
+// keep the count they were created with.
+const profileKeyIterations = 600_000
+
+// storedProfile is the on-disk form of a profile, with the password
+// encrypted under the store's master key.
+type storedProfile struct {
+	model.ConnectionProfile
+	EncryptedPassword string `json:"encrypted_password,omitempty"`
+}
+
+// profileFile is the on-disk form of a store: the salt and iteration count
+// its key is derived with, and its profiles.
+type profileFile struct {
+	Salt       []byte          `json:"salt"`
+	Iterations int             `json:"iterations"`
+	Profiles   []storedProfile `json:"profiles"`
+}
+
+// ProfileStore persists connection profiles as a JSON file. Passwords are
+// encrypted with AES-256-GCM, bound to their profile's ID, using a key
+// derived from the master key, and are only decrypted when a DSN is built
+// for connecting.
+type ProfileStore struct {
+	mu         sync.Mutex
+	path       string
+	salt       []byte
+	iterations int
+	aead       cipher.AEAD
+	profiles   map[string]storedProfile
+}
+
+// NewProfileStore opens the store at path, creating it on first save.
+// masterKey may be any secret string; it is stretched to a 256-bit key with
+// PBKDF2-SHA256 and a random salt kept in the store.
+func NewProfileStore(path, masterKey string) (*ProfileStore, error) {
+	if masterKey == "" {
+		return nil, errors.New("master key is required")
+	}
+
+	file := profileFile{Iterations: profileKeyIterations}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		file.Salt = make([]byte, 16)
+		if _, err := rand.Read(file.Salt); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to read profiles from %s: %w", path, err)
+		}
+		if len(file.Salt) == 0 || file.Iterations <= 0 {
+			return nil, fmt.Errorf("failed to read profiles from %s: missing key salt", path)
+		}
+	}
+
+	key, err := pbkdf2.Key(sha256.New, masterKey, file.Salt, file.Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ProfileStore{
+		path:       path,
+		salt:       file.Salt,
+		iterations: file.Iterations,
+		aead:       aead,
+		profiles:   map[string]storedProfile{},
+	}
+	for _, p := range file.Profiles {
+		s.profiles[p.ID] = p
+	}
+	return s, nil
+}
+
+// encrypt seals plaintext with the profile ID as additional data, so that
+// it cannot be moved to another profile.
+func (s *ProfileStore) encrypt(id, plaintext string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(plaintext), []byte(id))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *ProfileStore) decrypt(id, encoded string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < s.aead.NonceSize() {
+		return "", errors.New("encrypted password is truncated")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return "", errors.New("failed to decrypt password; was the master key changed?")
+	}
+	return string(plaintext), nil
+}
+
+// save writes all profiles to a temporary file and renames it into place,
+// so a crash never leaves a half-written store behind.
+func (s *ProfileStore) save() error {
+	list := make([]storedProfile, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	data, err := json.MarshalIndent(profileFile{Salt: s.salt, Iterations: s.iterations, Profiles: list}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".profiles-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// public strips the credentials from a stored profile.
+func (p storedProfile) public() model.ConnectionProfile {
+	profile := p.ConnectionProfile
+	profile.Password = ""
+	profile.HasPassword = p.EncryptedPassword != ""
+	return profile
+}
+
+func validateProfile(p model.ConnectionProfile) error {
+	if p.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidProfile)
+	}
+	switch p.Driver {
+	case "postgres", "mysql", "mariadb", "sqlite", "sqlite3":
+	default:
+		return fmt.Errorf("%w: unsupported driver %q", ErrInvalidProfile, p.Driver)
+	}
+	if p.Database == "" {
+		return fmt.Errorf("%w: database is required", ErrInvalidProfile)
+	}
+	if p.Port < 0 || p.Port > 65535 {
+		return fmt.Errorf("%w: port out of range", ErrInvalidProfile)
+	}
+	return nil
+}
+
+func (s *ProfileStore) List() []model.ConnectionProfile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]model.ConnectionProfile, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		list = append(list, p.public())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func (s *ProfileStore) Get(id string) (model.ConnectionProfile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.profiles[id]
+	if !ok {
+		return model.ConnectionProfile{}, ErrProfileNotFound
+	}
+	return p.public(), nil
+}
+
+func (s *ProfileStore) Create(p model.ConnectionProfile) (model.ConnectionProfile, error) {
+	if err := validateProfile(p); err != nil {
+		return model.ConnectionProfile{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return model.ConnectionProfile{}, err
+	}
+	p.ID = hex.EncodeToString(id)
+
+	stored := storedProfile{ConnectionProfile: p}
+	if p.Password != "" {
+		encrypted, err := s.encrypt(p.ID, p.Password)
+		if err != nil {
+			return model.ConnectionProfile{}, err
+		}
+		stored.EncryptedPassword = encrypted
+	}
+	stored.Password = ""
+
+	s.profiles[p.ID] = stored
+	if err := s.save(); err != nil {
+		delete(s.profiles, p.ID)
+		return model.ConnectionProfile{}, err
+	}
+	return stored.public(), nil
+}
+
+// Update replaces the profile's settings. An empty password keeps the
+// stored one, so clients can edit a profile without re-entering it.
+func (s *ProfileStore) Update(id string, p model.ConnectionProfile) (model.ConnectionProfile, error) {
+	if err := validateProfile(p); err != nil {
+		return model.ConnectionProfile{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.profiles[id]
+	if !ok {
+		return model.ConnectionProfile{}, ErrProfileNotFound
+	}
+
+	p.ID = id
+	stored := storedProfile{ConnectionProfile: p, EncryptedPassword: existing.EncryptedPassword}
+	if p.Password != "" {
+		encrypted, err := s.encrypt(id, p.Password)
+		if err != nil {
+			return model.ConnectionProfile{}, err
+		}
+		stored.EncryptedPassword = encrypted
+	}
+	stored.Password = ""
+
+	s.profiles[id] = stored
+	if err := s.save(); err != nil {
+		s.profiles[id] = existing
+		return model.ConnectionProfile{}, err
+	}
+	return stored.public(), nil
+}
+
+func (s *ProfileStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.profiles[id]
+	if !ok {
+		return ErrProfileNotFound
+	}
+
+	delete(s.profiles, id)
+	if err := s.save(); err != nil {
+		s.profiles[id] = existing
+		return err
+	}
+	return nil
+}
+
+// DSN decrypts the profile's password and returns its driver and connection string.
+func (s *ProfileStore) DSN(id string) (model.ConnectionProfile, string, error) {
+	s.mu.Lock()
+	stored, ok := s.profiles[id]
+	s.mu.Unlock()
+	if !ok {
+		return model.ConnectionProfile{}, "", ErrProfileNotFound
+	}
+
+	profile := stored.ConnectionProfile
+	if stored.EncryptedPassword != "" {
+		password, err := s.decrypt(id, stored.EncryptedPassword)
+		if err != nil {
+			return model.ConnectionProfile{}, "", err
+		}
+		profile.Password = password
+	}
+
+	dsn, err := BuildDSN(profile)
+	if err != nil {
+		return model.ConnectionProfile{}, "", err
+	}
+	return stored.public(), dsn, nil
+}
+
+// BuildDSN formats a profile as a connection string for its driver.
+func BuildDSN(p model.ConnectionProfile) (string, error) {
+	switch p.Driver {
+	case "postgres":
+		host := p.Host
+		if host == "" {
+			host = "localhost"
+		}
+		if p.Port != 0 {
+			host = net.JoinHostPort(host, strconv.Itoa(p.Port))
+		}
+		u := url.URL{Scheme: "postgres", Host: host, Path: "/" + p.Database}
+		if p.User != "" {
+			if p.Password != "" {
+				u.User = url.UserPassword(p.User, p.Password)
+			} else {
+				u.User = url.User(p.User)
+			}
+		}
+		query := url.Values{}
+		for k, v := range p.Options {
+			query.Set(k, v)
+		}
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+
+	case "mysql", "mariadb":
+		cfg := mysql.NewConfig()
+		cfg.User = p.User
+		cfg.Passwd = p.Password
+		cfg.Net = "tcp"
+		host := p.Host
+		if host == "" {
+			host = "localhost"
+		}
+		port := p.Port
+		if port == 0 {
+			port = 3306
+		}
+		cfg.Addr = net.JoinHostPort(host, strconv.Itoa(port))
+		cfg.DBName = p.Database
+		if len(p.Options) > 0 {
+			cfg.Params = map[string]string{}
+			for k, v := range p.Options {
+				cfg.Params[k] = v
+			}
+		}
+		return cfg.FormatDSN(), nil
+
+	case "sqlite", "sqlite3":
+		// The driver cuts even plain paths at "?", so always pass a URI
+		// with the path escaped. Unless the options say otherwise, it
+		// opens an existing file only, as plain paths do, so that a typo
+		// doesn't silently create an empty database.
+		query := url.Values{}
+		for k, v := range p.Options {
+			query.Set(k, v)
+		}
+		if !query.Has("mode") && p.Database != ":memory:" {
+			query.Set("mode", "rw")
+		}
+		dsn := "file:" + (&url.URL{Path: p.Database}).EscapedPath()
+		if len(query) > 0 {
+			dsn += "?" + query.Encode()
+		}
+		return dsn, nil
+	}
+
+	return "", fmt.Errorf("%w: unsupported driver %q", ErrInvalidProfile, p.Driver)
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"vind/backend/helper"
+	"vind/backend/internal/model"
+)
+
+// projection is the select list of a table-data page.
+type projection struct {
+	list    string
+	omitted map[string]bool // large columns read as their size in bytes
+}
+
+// sizeExpr returns an expression for the size in bytes of column, quoted as
+// quoted, if col is a large type whose values OmitLarge leaves out, or "".
+type sizeExpr func(quoted string, col sourceColumn) string
+
+// newProjection selects req.Columns, or every column, from source. The
+// keyset columns keys are always selected, so that the page can be paged
+// through and its rows edited, and are never omitted.
+func newProjection(req model.TableDataRequest, source map[string]sourceColumn, keys []helper.OrderTerm, quote func(string) string, size sizeExpr) (*projection, error) {
+	p := &projection{list: "*", omitted: map[string]bool{}}
+	if len(req.Columns) == 0 && !req.OmitLarge {
+		return p, nil
+	}
+
+	names := req.Columns
+	if len(names) == 0 {
+		names = sortedColumns(source, func(sourceColumn) bool { return true })
+	}
+	selected := map[string]bool{}
+	var list []string
+	add := func(name string, omit bool) {
+		if selected[name] {
+			return
+		}
+		selected[name] = true
+		if omit {
+			if expr := size(quote(name), source[name]); expr != "" {
+				list = append(list, expr+" AS "+quote(name))
+				p.omitted[name] = true
+				return
+			}
+		}
+		list = append(list, quote(name))
+	}
+
+	isKey := map[string]bool{}
+	for _, t := range keys {
+		isKey[t.Column] = true
+	}
+	for _, name := range names {
+		if _, ok := source[name]; !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownColumn, name)
+		}
+		add(name, req.OmitLarge && !isKey[name])
+	}
+	for _, t := range keys {
+		add(t.Column, false)
+	}
+
+	p.list = strings.Join(list, ", ")
+	return p, nil
+}
+
+// converter wraps convert to mark omitted columns and pass their sizes
+// through as numbers.
+func (p *projection) converter(convert columnConverter) columnConverter {
+	return func(ct *sql.ColumnType) (model.ColumnMeta, func(v any) any) {
+		meta, fn := convert(ct)
+		if !p.omitted[meta.Name] {
+			return meta, fn
+		}
+		meta.Omitted, meta.Binary = true, false
+		return meta, func(v any) any {
+			if b, ok := v.([]byte); ok {
+				return json.Number(b)
+			}
+			return v
+		}
+	}
+}
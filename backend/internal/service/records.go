@@ -0,0 +1,353 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"vind/backend/helper"
+	"vind/backend/internal/model"
+)
+
+var (
+	ErrUnknownColumn = errors.New("unknown column")
+	ErrNoPrimaryKey  = errors.New("table has no primary or unique key")
+	ErrInvalidKey    = errors.New("invalid row key")
+	ErrRowNotFound   = errors.New("row not found")
+	ErrTableNotFound = errors.New("table not found")
+	ErrRowConflict   = errors.New("row was changed since it was read")
+	ErrAmbiguousRow  = errors.New("row key matches more than one row")
+	ErrNoRowVersion  = errors.New("database has no row versions")
+)
+
+// rowTable is a table whose rows are read and edited one at a time, each
+// identified by the values of its key columns.
+type rowTable struct {
+	q       queryer
+	schema  string
+	from    string // quoted, schema-qualified table name
+	source  map[string]sourceColumn
+	key     []string // nil if the table has no primary or usable unique key
+	d       helper.SQLDialect
+	quote   func(string) string
+	version string // expression for a row's version, or "" if the database has none
+}
+
+// rowKey returns the columns identifying a row of source: its primary key,
+// or else the first of the unique keys uniques that has no nullable column.
+func rowKey(source map[string]sourceColumn, uniques [][]string) []string {
+	if pk := sortedColumns(source, func(col sourceColumn) bool { return col.PrimaryKey }); len(pk) > 0 {
+		return pk
+	}
+next:
+	for _, cols := range uniques {
+		for _, name := range cols {
+			if col, ok := source[name]; !ok || col.Nullable {
+				continue next
+			}
+		}
+		return cols
+	}
+	return nil
+}
+
+// scanUniqueKeys reads rows of (index, column), ordered by index and then
+// column position, as returned by the drivers' unique key queries. Indexes
+// with a NULL column, which is an expression, are left out.
+func scanUniqueKeys(rows *sql.Rows, err error) ([][]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var order []string
+	columns := map[string][]string{}
+	unusable := map[string]bool{}
+	for rows.Next() {
+		var index string
+		var column sql.NullString
+		if err := rows.Scan(&index, &column); err != nil {
+			return nil, err
+		}
+		if _, ok := columns[index]; !ok {
+			order = append(order, index)
+		}
+		columns[index] = append(columns[index], column.String)
+		if !column.Valid {
+			unusable[index] = true
+		}
+	}
+
+	var keys [][]string
+	for _, index := range order {
+		if !unusable[index] {
+			keys = append(keys, columns[index])
+		}
+	}
+	return keys, rows.Err()
+}
+
+// columnCondition returns the condition matching rows whose columns hold the
+// values in values, with NULL matching NULL, and the values to bind to it.
+func (t *rowTable) columnCondition(values map[string]any, firstArg int) (string, []any, error) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if _, ok := t.source[name]; !ok {
+			return "", nil, fmt.Errorf("%w %q", ErrUnknownColumn, name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	conds := make([]string, len(names))
+	var args []any
+	for i, name := range names {
+		if values[name] == nil {
+			conds[i] = t.quote(name) + " IS NULL"
+			continue
+		}
+		args = append(args, values[name])
+		conds[i] = t.quote(name) + " = " + helper.Placeholder(t.d, firstArg+len(args)-1)
+	}
+	return strings.Join(conds, " AND "), args, nil
+}
+
+// keyCondition returns the condition selecting the row whose key columns
+// hold the values in key, and the values to bind to it. key must name every
+// key column and nothing else.
+func (t *rowTable) keyCondition(key map[string]any, firstArg int) (string, []any, error) {
+	if t.key == nil {
+		return "", nil, ErrNoPrimaryKey
+	}
+	invalid := fmt.Errorf("%w: expected values for %s", ErrInvalidKey, strings.Join(t.key, ", "))
+	if len(key) != len(t.key) {
+		return "", nil, invalid
+	}
+	for _, name := range t.key {
+		if v, ok := key[name]; !ok || v == nil {
+			return "", nil, invalid
+		}
+	}
+	return t.columnCondition(key, firstArg)
+}
+
+// target returns the condition selecting the row edit applies to, and the
+// values to bind to it. The row is found by key or, with Force on a table
+// without one, by the columns of edit.Key as long as they match at most one
+// row. With checks the row must also still hold edit's expected values and
+// version.
+func (t *rowTable) target(ctx context.Context, edit model.RowEdit, firstArg int, checks bool) (string, []any, error) {
+	var where string
+	var args []any
+	var err error
+	if t.key == nil && edit.Force {
+		if len(edit.Key) == 0 {
+			return "", nil, fmt.Errorf("%w: no columns given", ErrInvalidKey)
+		}
+		if where, args, err = t.columnCondition(edit.Key, 1); err != nil {
+			return "", nil, err
+		}
+		var n int
+		err = t.q.QueryRowContext(ctx, "SELECT COUNT(*) FROM (SELECT 1 FROM "+t.from+" WHERE "+where+" LIMIT 2) matched", args...).Scan(&n)
+		if err != nil {
+			return "", nil, err
+		}
+		if n > 1 {
+			return "", nil, ErrAmbiguousRow
+		}
+		where, args, err = t.columnCondition(edit.Key, firstArg)
+	} else {
+		where, args, err = t.keyCondition(edit.Key, firstArg)
+	}
+	if err != nil || !checks {
+		return where, args, err
+	}
+
+	if len(edit.Expected) > 0 {
+		cond, expected, err := t.columnCondition(edit.Expected, firstArg+len(args))
+		if err != nil {
+			return "", nil, err
+		}
+		where += " AND " + cond
+		args = append(args, expected...)
+	}
+	if edit.Version != "" {
+		if t.version == "" {
+			return "", nil, ErrNoRowVersion
+		}
+		where += " AND " + t.version + " = " + helper.Placeholder(t.d, firstArg+len(args))
+		args = append(args, edit.Version)
+	}
+	return where, args, nil
+}
+
+// unmatched explains why edit changed no row: ErrRowNotFound if no row has
+// its key and ErrRowConflict if the row no longer holds the expected values.
+// It returns nil if the row matches but was left unchanged, which MySQL
+// reports as no rows affected.
+func (t *rowTable) unmatched(ctx context.Context, edit model.RowEdit) error {
+	for _, checks := range []bool{true, false} {
+		where, args, err := t.target(ctx, edit, 1, checks)
+		if err != nil {
+			return err
+		}
+		var one int
+		err = t.q.QueryRowContext(ctx, "SELECT 1 FROM "+t.from+" WHERE "+where+" LIMIT 1", args...).Scan(&one)
+		switch {
+		case err == nil && checks:
+			return nil
+		case err == nil:
+			return ErrRowConflict
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+	}
+	return ErrRowNotFound
+}
+
+// update sets edit.Data on the row edit identifies.
+func (t *rowTable) update(ctx context.Context, edit model.RowEdit) (*model.RowEditResult, error) {
+	if len(edit.Data) == 0 {
+		return nil, errors.New("no fields to update")
+	}
+	names := make([]string, 0, len(edit.Data))
+	for name := range edit.Data {
+		if _, ok := t.source[name]; !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownColumn, name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	set := make([]string, len(names))
+	args := make([]any, len(names))
+	for i, name := range names {
+		set[i] = t.quote(name) + " = " + helper.Placeholder(t.d, i+1)
+		args[i] = edit.Data[name]
+	}
+	where, whereArgs, err := t.target(ctx, edit, len(args)+1, true)
+	if err != nil {
+		return nil, err
+	}
+	query := "UPDATE " + t.from + " SET " + strings.Join(set, ", ") + " WHERE " + where
+	args = append(args, whereArgs...)
+
+	result := &model.RowEditResult{}
+	var n int64
+	if t.version != "" {
+		err = t.q.QueryRowContext(ctx, query+" RETURNING "+t.version, args...).Scan(&result.Version)
+		if err == nil {
+			n = 1
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	} else {
+		res, err := t.q.ExecContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		if n, err = res.RowsAffected(); err != nil {
+			return nil, err
+		}
+	}
+	if n == 0 {
+		if err := t.unmatched(ctx, edit); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// delete deletes the row edit identifies.
+func (t *rowTable) delete(ctx context.Context, edit model.RowEdit) error {
+	where, args, err := t.target(ctx, edit, 1, true)
+	if err != nil {
+		return err
+	}
+	res, err := t.q.ExecContext(ctx, "DELETE FROM "+t.from+" WHERE "+where, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return t.unmatched(ctx, edit)
+	}
+	return nil
+}
+
+// readCell reads column of the row identified by key.
+func (t *rowTable) readCell(ctx context.Context, column string, key map[string]any, convert columnConverter) (*model.CellValue, error) {
+	if _, ok := t.source[column]; !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownColumn, column)
+	}
+	where, args, err := t.keyCondition(key, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := t.q.QueryContext(ctx, "SELECT "+t.quote(column)+" FROM "+t.from+" WHERE "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rc rowCollector
+	if err := scanRows(rows, &rc, convert); err != nil {
+		return nil, err
+	}
+	if len(rc.rows) == 0 {
+		return nil, ErrRowNotFound
+	}
+	return &model.CellValue{Field: rc.fields[0], Value: rc.rows[0][0]}, nil
+}
+
+// returning runs query, an INSERT, UPDATE or DELETE on t, with RETURNING *
+// and returns the rows it wrote as the database left them.
+func (t *rowTable) returning(ctx context.Context, query string, args []any, convert columnConverter) (*model.QueryResponse, error) {
+	rows, err := t.q.QueryContext(ctx, query+" RETURNING *", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rc rowCollector
+	if err := scanRows(rows, &rc, convert); err != nil {
+		return nil, err
+	}
+	return writtenRows(&rc, int64(len(rc.rows))), nil
+}
+
+// readRows adds to rc the rows of t whose columns hold the values of each
+// of keys, for databases that cannot return the rows they write.
+func (t *rowTable) readRows(ctx context.Context, keys []map[string]any, rc *rowCollector, convert columnConverter) error {
+	for _, key := range keys {
+		where, args, err := t.columnCondition(key, 1)
+		if err != nil {
+			return err
+		}
+		rows, err := t.q.QueryContext(ctx, "SELECT * FROM "+t.from+" WHERE "+where, args...)
+		if err != nil {
+			return err
+		}
+		err = scanRows(rows, rc, convert)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writtenRows returns the response to a write of n rows, holding the rows
+// collected in rc. Columns are left out if no rows could be read back.
+func writtenRows(rc *rowCollector, n int64) *model.QueryResponse {
+	if rc.fields != nil && rc.rows == nil {
+		rc.rows = [][]any{}
+	}
+	return &model.QueryResponse{Columns: rc.columns(), Fields: rc.fields, Rows: rc.rows, RowsAffected: &n}
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"vind/backend/helper"
+	"vind/backend/internal/model"
+)
+
+// ErrRowLimit may be returned by a RowWriter to stop reading rows early
+// without treating the query as failed.
+var ErrRowLimit = errors.New("row limit reached")
+
+// RowWriter receives a result set as it is read from the database. Columns
+// are written once, before any rows; statements that return no result set
+// write neither.
+type RowWriter interface {
+	WriteColumns(columns []model.ColumnMeta) error
+	WriteRow(values []any) error
+}
+
+// rowCollector buffers a result set for callers that need all of it at once.
+type rowCollector struct {
+	fields []model.ColumnMeta
+	rows   [][]any
+}
+
+func (r *rowCollector) WriteColumns(columns []model.ColumnMeta) error {
+	r.fields = columns
+	return nil
+}
+
+func (r *rowCollector) WriteRow(values []any) error {
+	r.rows = append(r.rows, values)
+	return nil
+}
+
+// columns returns the collected column names, or nil if no result set was written.
+func (r *rowCollector) columns() []string {
+	if r.fields == nil {
+		return nil
+	}
+	names := make([]string, len(r.fields))
+	for i, f := range r.fields {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// collectTable runs stream with a rowCollector and fills the response's
+// columns and rows from it.
+func collectTable(stream func(w RowWriter) (*model.TableDataResponse, error)) (*model.TableDataResponse, error) {
+	var rc rowCollector
+	resp, err := stream(&rc)
+	if err != nil {
+		return nil, err
+	}
+	resp.Columns = rc.columns()
+	resp.Fields = rc.fields
+	resp.Rows = rc.rows
+	return resp, nil
+}
+
+// columnConverter describes a result column and returns the function that
+// turns its scanned values into JSON-friendly ones. Each driver has its own.
+type columnConverter func(ct *sql.ColumnType) (model.ColumnMeta, func(v any) any)
+
+// sourceColumn describes a column of a base table that results are read from.
+type sourceColumn struct {
+	Type       string // declared type, e.g. "jsonb" or "longtext"
+	Nullable   bool
+	PrimaryKey bool
+	Position   int
+}
+
+// fromTable wraps convert to mark columns found in columns as read from
+// schema.table. Their nullability comes from the table definition, which
+// some drivers do not report for result columns.
+func fromTable(convert columnConverter, schema, table string, columns map[string]sourceColumn) columnConverter {
+	return fromColumns(convert, schema, table, func(name string) (sourceColumn, bool) {
+		col, ok := columns[name]
+		return col, ok
+	})
+}
+
+// fromColumns is fromTable with the table column each result column reads
+// looked up by source.
+func fromColumns(convert columnConverter, schema, table string, source func(name string) (sourceColumn, bool)) columnConverter {
+	return func(ct *sql.ColumnType) (model.ColumnMeta, func(v any) any) {
+		meta, fn := convert(ct)
+		if col, ok := source(meta.Name); ok {
+			meta.Schema, meta.Table = schema, table
+			meta.Nullable = &col.Nullable
+			meta.PrimaryKey = col.PrimaryKey
+		}
+		return meta, fn
+	}
+}
+
+// tableLookup resolves a table named in a query to its schema-qualified
+// name and columns, reporting false if it is not a table.
+type tableLookup func(ctx context.Context, name helper.QualifiedName) (helper.QualifiedName, map[string]sourceColumn, bool)
+
+// fromQuery wraps convert to mark the result columns of query that are
+// plain references to a column of the single table it reads, as recognised
+// by helper.ParseSelectSource, with that table. None of the drivers report
+// where a result column comes from, so the columns of other queries, such
+// as joins, carry no table.
+func fromQuery(ctx context.Context, query string, d helper.SQLDialect, convert columnConverter, lookup tableLookup) columnConverter {
+	src, ok := helper.ParseSelectSource(query, d)
+	if !ok {
+		return convert
+	}
+	table, columns, ok := lookup(ctx, src.Table)
+	if !ok {
+		return convert
+	}
+	return fromColumns(convert, table.Schema, table.Name, func(name string) (sourceColumn, bool) {
+		column, ok := src.Column(name)
+		if !ok {
+			return sourceColumn{}, false
+		}
+		if col, ok := columns[column]; ok || d == helper.DialectPostgres {
+			return col, ok
+		}
+		// MySQL and SQLite match column names regardless of case.
+		for other, col := range columns {
+			if strings.EqualFold(other, column) {
+				return col, true
+			}
+		}
+		return sourceColumn{}, false
+	})
+}
+
+// sortedColumns returns the names of columns in table order, keeping only
+// those keep accepts.
+func sortedColumns(columns map[string]sourceColumn, keep func(col sourceColumn) bool) []string {
+	var names []string
+	for name, col := range columns {
+		if keep(col) {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return columns[names[i]].Position < columns[names[j]].Position })
+	return names
+}
+
+// hasColumn reports whether a column is one of columns.
+func hasColumn(columns map[string]sourceColumn) func(name string) bool {
+	return func(name string) bool {
+		_, ok := columns[name]
+		return ok
+	}
+}
+
+// scanSourceColumns reads rows of (name, type, nullable, primary key,
+// position) as returned by the drivers' sourceColumns queries.
+func scanSourceColumns(rows *sql.Rows, err error) (map[string]sourceColumn, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := map[string]sourceColumn{}
+	for rows.Next() {
+		var name string
+		var col sourceColumn
+		if err := rows.Scan(&name, &col.Type, &col.Nullable, &col.PrimaryKey, &col.Position); err != nil {
+			return nil, err
+		}
+		columns[name] = col
+	}
+	return columns, rows.Err()
+}
+
+// scanRows passes rows to w one at a time, converting each value with the
+// converter convert returns for its column. A result without columns, as
+// some drivers return for statements that produce no rows, is not written.
+func scanRows(rows *sql.Rows, w RowWriter, convert columnConverter) error {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	if len(types) == 0 {
+		return rows.Err()
+	}
+
+	fields := make([]model.ColumnMeta, len(types))
+	converters := make([]func(v any) any, len(types))
+	for i, ct := range types {
+		fields[i], converters[i] = convert(ct)
+	}
+	if err := w.WriteColumns(fields); err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		values := make([]any, len(types))
+		pointers := make([]any, len(types))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+		for i, v := range values {
+			if v != nil {
+				values[i] = converters[i](v)
+			}
+		}
+		if err := w.WriteRow(values); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"vind/backend/helper"
+	"vind/backend/internal/model"
+)
+
+// checkAlterColumns checks the column names the operations of an ALTER
+// TABLE request refer to.
+func checkAlterColumns(d helper.SQLDialect, ops []model.AlterTableOperation) error {
+	for _, op := range ops {
+		if op.ColumnName != "" {
+			if err := helper.CheckIdentifiers(d, op.ColumnName); err != nil {
+				return err
+			}
+		}
+		if op.NewName != "" {
+			if err := helper.CheckIdentifiers(d, op.NewName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkConstraintNames checks the constraint, column and referenced names
+// of a new constraint.
+func checkConstraintNames(d helper.SQLDialect, params model.AddConstraintParams) error {
+	if err := helper.CheckIdentifiers(d, params.ConstraintName); err != nil {
+		return err
+	}
+	if err := helper.CheckIdentifiers(d, params.Columns...); err != nil {
+		return err
+	}
+	if params.RefTable == "" {
+		return nil
+	}
+	ref := helper.QualifiedName{Schema: params.RefSchema, Name: params.RefTable}
+	if err := ref.Check(d); err != nil {
+		return err
+	}
+	return helper.CheckIdentifiers(d, params.RefColumns...)
+}
@@ -0,0 +1,836 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"vind/backend/helper"
+	"vind/backend/internal/model"
+
+	_ "modernc.org/sqlite"
+)
+
+type SQLiteClient struct {
+	db *sql.DB
+	tx *sql.Tx // set on clients returned by BeginTx
+}
+
+func NewSQLiteClient() *SQLiteClient {
+	return &SQLiteClient{}
+}
+
+// quoteSQLiteIdentifier wraps an identifier in double quotes, doubling any embedded quote.
+func quoteSQLiteIdentifier(name string) string {
+	return helper.QuoteIdentifier(helper.DialectSQLite, name)
+}
+
+func quoteSQLiteIdentifiers(cols []string) []string {
+	quoted := make([]string, len(cols))
+	for i, col := range cols {
+		quoted[i] = quoteSQLiteIdentifier(col)
+	}
+	return quoted
+}
+
+func isSQLiteMemoryDSN(dsn string) bool {
+	return dsn == ":memory:" || strings.HasPrefix(dsn, "file::memory:") || strings.Contains(dsn, "mode=memory")
+}
+
+// Connect opens the database file at dsn. Plain paths must point to an
+// existing file so that a typo doesn't silently create an empty database;
+// "file:" URIs and in-memory databases are passed to the driver as-is.
+func (s *SQLiteClient) Connect(dsn string) error {
+	if !isSQLiteMemoryDSN(dsn) && !strings.HasPrefix(dsn, "file:") {
+		if _, err := os.Stat(dsn); err != nil {
+			return fmt.Errorf("database file not found: %s", dsn)
+		}
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return err
+	}
+	// Every connection to an in-memory database gets its own empty database,
+	// so keep the pool to a single connection.
+	if isSQLiteMemoryDSN(dsn) {
+		db.SetMaxOpenConns(1)
+	}
+	s.db = db
+	return db.Ping()
+}
+
+func (s *SQLiteClient) Disconnect() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
+
+// conn returns the transaction the client is bound to, or its pool.
+func (s *SQLiteClient) conn() queryer {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+// BeginTx opens a transaction and returns a client whose methods run inside
+// it. ctx must outlive the transaction: cancelling it rolls the transaction back.
+func (s *SQLiteClient) BeginTx(ctx context.Context) (Tx, error) {
+	if s.tx != nil {
+		return nil, ErrInTransaction
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteClient{db: s.db, tx: tx}, nil
+}
+
+func (s *SQLiteClient) Commit() error {
+	if s.tx == nil {
+		return ErrNotInTransaction
+	}
+	return s.tx.Commit()
+}
+
+func (s *SQLiteClient) Rollback() error {
+	if s.tx == nil {
+		return ErrNotInTransaction
+	}
+	return s.tx.Rollback()
+}
+
+// qualify fills in the default schema of table and checks its names.
+func (s *SQLiteClient) qualify(table helper.QualifiedName) (helper.QualifiedName, error) {
+	if table.Schema == "" {
+		table.Schema = "main"
+	}
+	return table, table.Check(helper.DialectSQLite)
+}
+
+func (s *SQLiteClient) ListSchemas(ctx context.Context) ([]string, error) {
+	rows, err := s.conn().QueryContext(ctx, `SELECT name FROM pragma_database_list`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schemas []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, name)
+	}
+	return schemas, nil
+}
+
+func (s *SQLiteClient) ListTables(ctx context.Context, schema string) ([]string, error) {
+	if schema == "" {
+		schema = "main"
+	}
+
+	query := fmt.Sprintf(
+		`SELECT name FROM %s.sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%%' ORDER BY name`,
+		quoteSQLiteIdentifier(schema),
+	)
+	rows, err := s.conn().QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, nil
+}
+
+func (s *SQLiteClient) ListColumns(ctx context.Context, table helper.QualifiedName) ([]model.Column, error) {
+	table, err := s.qualify(table)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			c.name,
+			c.type,
+			c."notnull",
+			c.dflt_value,
+			-- Check if column is covered by a single-column unique constraint
+			EXISTS (
+				SELECT 1 FROM pragma_index_list(?2, ?1) il
+				JOIN pragma_index_info(il.name, ?1) ii
+				WHERE il."unique" = 1 AND il.origin = 'u' AND ii.name = c.name
+			) AS is_unique,
+			-- Get foreign key reference if any
+			(
+				SELECT 'FOREIGN KEY (' || fk."from" || ') REFERENCES ' || fk."table" || '(' || IFNULL(fk."to", '') || ')'
+				FROM pragma_foreign_key_list(?2, ?1) fk
+				WHERE fk."from" = c.name
+				LIMIT 1
+			) AS foreign_key
+		FROM pragma_table_info(?2, ?1) c
+		ORDER BY c.cid
+	`
+
+	rows, err := s.conn().QueryContext(ctx, query, table.Schema, table.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []model.Column
+	for rows.Next() {
+		var col model.Column
+		var notNull bool
+		var defaultVal sql.NullString
+		var isUnique sql.NullBool
+		var foreignKey sql.NullString
+
+		err := rows.Scan(&col.Name, &col.Type, &notNull, &defaultVal, &isUnique, &foreignKey)
+		if err != nil {
+			return nil, err
+		}
+
+		col.Nullable = !notNull
+		if defaultVal.Valid {
+			col.Default = defaultVal.String
+		}
+		col.IsUnique = isUnique.Valid && isUnique.Bool
+		if foreignKey.Valid {
+			col.ForeignKey = foreignKey.String
+		}
+
+		columns = append(columns, col)
+	}
+
+	return columns, nil
+}
+
+func (s *SQLiteClient) ExecuteQuery(ctx context.Context, sql string, args ...any) (*model.QueryResponse, error) {
+	return collectQuery(func(w RowWriter) (*model.QueryResponse, error) { return s.StreamQuery(ctx, sql, w, args...) })
+}
+
+// StreamQuery runs sql with args bound to its placeholders and passes its
+// result set, if any, to w row by row.
+// The returned response carries the command and rows affected but no rows.
+func (s *SQLiteClient) StreamQuery(ctx context.Context, sql string, w RowWriter, args ...any) (*model.QueryResponse, error) {
+	convert := fromQuery(ctx, sql, helper.DialectSQLite, sqliteColumn, s.queryTable)
+	return runStatement(ctx, s.conn(), sql, args, helper.DialectSQLite, w, convert)
+}
+
+// ExecuteScript runs statements one after another on the same connection.
+func (s *SQLiteClient) ExecuteScript(ctx context.Context, statements []string, opts model.ScriptOptions) (*model.ScriptResponse, error) {
+	return runScript(ctx, s.db, s.tx, "", statements, helper.DialectSQLite, sqliteColumn, opts)
+}
+
+func (s *SQLiteClient) Dialect() helper.SQLDialect {
+	return helper.DialectSQLite
+}
+
+// CancelQuery is a no-op: SQLite runs in-process, so statements are
+// interrupted by cancelling their context instead.
+func (s *SQLiteClient) CancelQuery(ctx context.Context, backendID int64) error {
+	return nil
+}
+
+// queryTable resolves a table named in a query, see tableLookup. Names
+// match regardless of case, and unqualified ones are looked up in temp,
+// then main, then attached databases, as SQLite does.
+func (s *SQLiteClient) queryTable(ctx context.Context, name helper.QualifiedName) (helper.QualifiedName, map[string]sourceColumn, bool) {
+	var table helper.QualifiedName
+	err := s.conn().QueryRowContext(ctx, `
+		SELECT schema, name FROM pragma_table_list
+		WHERE name = ?1 COLLATE NOCASE AND (?2 = '' OR schema = ?2 COLLATE NOCASE) AND type IN ('table', 'view')
+		ORDER BY schema = 'temp' DESC, schema = 'main' DESC
+		LIMIT 1
+	`, name.Name, name.Schema).Scan(&table.Schema, &table.Name)
+	if err != nil {
+		return table, nil, false
+	}
+	columns, err := s.sourceColumns(ctx, table.Schema, table.Name)
+	return table, columns, err == nil && len(columns) > 0
+}
+
+// sourceColumns describes each column of schema.table.
+func (s *SQLiteClient) sourceColumns(ctx context.Context, schema, table string) (map[string]sourceColumn, error) {
+	query := `SELECT name, type, NOT "notnull", pk > 0, cid FROM pragma_table_info(?2, ?1)`
+	return scanSourceColumns(s.conn().QueryContext(ctx, query, schema, table))
+}
+
+func (s *SQLiteClient) GetTableData(ctx context.Context, req model.TableDataRequest) (*model.TableDataResponse, error) {
+	return collectTable(func(w RowWriter) (*model.TableDataResponse, error) { return s.StreamTableData(ctx, req, w) })
+}
+
+// StreamTableData reads a page of table rows and passes them to w row by row.
+// The returned response carries the page's cursors and row count but no rows.
+func (s *SQLiteClient) StreamTableData(ctx context.Context, req model.TableDataRequest, w RowWriter) (*model.TableDataResponse, error) {
+	table, err := s.qualify(helper.QualifiedName{Schema: req.Schema, Name: req.Table})
+	if err != nil {
+		return nil, err
+	}
+	req.Schema = table.Schema
+
+	limitInt, offsetInt, err := pageBounds(req)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := s.sourceColumns(ctx, req.Schema, req.Table)
+	if err != nil {
+		return nil, err
+	}
+	where, args, err := helper.FilterSQL(req.Filters, hasColumn(source), helper.DialectSQLite, quoteSQLiteIdentifier, 1)
+	if err != nil {
+		return nil, err
+	}
+	order, err := helper.ParseOrderBy(req.OrderBy, hasColumn(source))
+	if err != nil {
+		return nil, err
+	}
+	page, err := newKeyset(req, order, source, helper.DialectSQLite, quoteSQLiteIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	proj, err := newProjection(req, source, page.terms, quoteSQLiteIdentifier, sqliteSize)
+	if err != nil {
+		return nil, err
+	}
+	countWhere, countArgs := where, args
+	if seek, seekArgs := page.seekSQL(len(args) + 1); seek != "" {
+		if where != "" {
+			where += " AND "
+		}
+		where += seek
+		args = append(args, seekArgs...)
+		offsetInt = 0
+	}
+
+	from := table.Quote(helper.DialectSQLite)
+	query := "SELECT " + proj.list + " FROM " + from
+	if where != "" {
+		query += " WHERE " + where
+	}
+	if order := page.scanOrder(); len(order) > 0 {
+		query += " ORDER BY " + helper.OrderBySQL(order, helper.DialectSQLite, quoteSQLiteIdentifier)
+	}
+
+	limit, limitArgs := limitSQL(helper.DialectSQLite, limitInt, offsetInt, len(args)+1)
+	query += limit
+	args = append(args, limitArgs...)
+	query = page.wrap(query)
+
+	rows, err := s.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pw := page.writer(w)
+	err = scanRows(rows, pw, proj.converter(fromTable(sqliteColumn, req.Schema, req.Table, source)))
+	rows.Close()
+	resp, err := pw.page(err, limitInt, offsetInt)
+	if resp == nil {
+		return nil, err
+	}
+
+	estimate := noRowEstimate
+	if err := countRows(ctx, s.conn(), s.tx != nil, req.Count, from, countWhere, countArgs, estimate, resp); err != nil {
+		return nil, err
+	}
+	return resp, err
+}
+
+// rowTable describes schema.table for reading and editing single rows.
+func (s *SQLiteClient) rowTable(ctx context.Context, table helper.QualifiedName) (*rowTable, error) {
+	table, err := s.qualify(table)
+	if err != nil {
+		return nil, err
+	}
+	source, err := s.sourceColumns(ctx, table.Schema, table.Name)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT il.name, ii.name
+		FROM pragma_index_list(?2, ?1) il, pragma_index_info(il.name, ?1) ii
+		WHERE il."unique" AND NOT il.partial
+		ORDER BY il.seq, ii.seqno
+	`
+	uniques, err := scanUniqueKeys(s.conn().QueryContext(ctx, query, table.Schema, table.Name))
+	if err != nil {
+		return nil, err
+	}
+	return &rowTable{
+		q:      s.conn(),
+		schema: table.Schema,
+		from:   table.Quote(helper.DialectSQLite),
+		source: source,
+		key:    rowKey(source, uniques),
+		d:      helper.DialectSQLite,
+		quote:  quoteSQLiteIdentifier,
+	}, nil
+}
+
+// GetCellValue reads one column of the row of schema.table whose primary
+// or unique key holds the values in key.
+func (s *SQLiteClient) GetCellValue(ctx context.Context, table helper.QualifiedName, column string, key map[string]any) (*model.CellValue, error) {
+	t, err := s.rowTable(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	return t.readCell(ctx, column, key, fromTable(sqliteColumn, t.schema, table.Name, t.source))
+}
+
+// UpdateRow updates the single row edit identifies by its key.
+func (s *SQLiteClient) UpdateRow(ctx context.Context, edit model.RowEdit) (*model.RowEditResult, error) {
+	t, err := s.rowTable(ctx, helper.QualifiedName{Schema: edit.Schema, Name: edit.Table})
+	if err != nil {
+		return nil, err
+	}
+	return t.update(ctx, edit)
+}
+
+// DeleteRow deletes the single row edit identifies by its key.
+func (s *SQLiteClient) DeleteRow(ctx context.Context, edit model.RowEdit) error {
+	t, err := s.rowTable(ctx, helper.QualifiedName{Schema: edit.Schema, Name: edit.Table})
+	if err != nil {
+		return err
+	}
+	return t.delete(ctx, edit)
+}
+
+// sqliteSize measures the types OmitLarge leaves out. Column types are only
+// declarations, so blobs and JSON are recognised by name.
+func sqliteSize(quoted string, col sourceColumn) string {
+	switch typ := strings.ToUpper(col.Type); {
+	case strings.Contains(typ, "BLOB"), typ == "JSON":
+		return "length(CAST(" + quoted + " AS BLOB))"
+	}
+	return ""
+}
+
+// InsertRecord inserts data into table and returns the row as written, with
+// generated and default values filled in.
+func (s *SQLiteClient) InsertRecord(ctx context.Context, table helper.QualifiedName, data map[string]any) (*model.QueryResponse, error) {
+	return s.writeRecords(ctx, table, func(t *rowTable) (string, []any, error) {
+		return insertSQL(t.from, helper.DialectSQLite, data, nil)
+	})
+}
+
+// UpdateRecord sets data on the rows matching where and returns them as
+// updated.
+func (s *SQLiteClient) UpdateRecord(ctx context.Context, table helper.QualifiedName, data, where map[string]any) (*model.QueryResponse, error) {
+	return s.writeRecords(ctx, table, func(t *rowTable) (string, []any, error) {
+		return batchUpdate(t.from, helper.DialectSQLite, model.BatchUpdate{Data: data, Where: where})
+	})
+}
+
+// DeleteRecord deletes the rows matching conditions and returns them.
+func (s *SQLiteClient) DeleteRecord(ctx context.Context, table helper.QualifiedName, conditions map[string]any) (*model.QueryResponse, error) {
+	return s.writeRecords(ctx, table, func(t *rowTable) (string, []any, error) {
+		return batchDelete(t.from, helper.DialectSQLite, conditions)
+	})
+}
+
+// writeRecords runs the INSERT, UPDATE or DELETE on table that build returns
+// and returns the rows it wrote.
+func (s *SQLiteClient) writeRecords(ctx context.Context, table helper.QualifiedName, build func(t *rowTable) (string, []any, error)) (*model.QueryResponse, error) {
+	t, err := s.rowTable(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := build(t)
+	if err != nil {
+		return nil, err
+	}
+	return t.returning(ctx, query, args, fromTable(sqliteColumn, t.schema, table.Name, t.source))
+}
+
+// keyConstraints returns the columns of each primary key and unique
+// constraint of table under the names ListConstraints reports, and the name
+// of its primary key.
+func (s *SQLiteClient) keyConstraints(ctx context.Context, table helper.QualifiedName) (map[string][]string, string, error) {
+	createSQL, err := sqliteTableSQL(ctx, s.conn(), table)
+	if err != nil {
+		return nil, "", err
+	}
+	def, err := parseSQLiteTable(table.Name, createSQL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keys := map[string][]string{}
+	var primary string
+	for _, ref := range def.constraints() {
+		switch ref.info.ConstraintType {
+		case "p":
+			primary = ref.info.ConstraintName
+			keys[primary] = ref.columns
+		case "u":
+			keys[ref.info.ConstraintName] = ref.columns
+		}
+	}
+	return keys, primary, nil
+}
+
+// ExecuteBatch runs the inserts, updates and deletes of req in one
+// transaction.
+func (s *SQLiteClient) ExecuteBatch(ctx context.Context, req model.BatchRequest) (*model.BatchResponse, error) {
+	table, err := s.qualify(helper.QualifiedName{Schema: req.Schema, Name: req.Table})
+	if err != nil {
+		return nil, err
+	}
+	var upsert *upsertTarget
+	if req.Upsert != nil {
+		keys, primary, err := s.keyConstraints(ctx, table)
+		if err != nil {
+			return nil, err
+		}
+		if upsert, err = newUpsertTarget(keys, primary, *req.Upsert); err != nil {
+			return nil, err
+		}
+	}
+	return runBatch(ctx, s.db, s.tx, table.Quote(helper.DialectSQLite), helper.DialectSQLite, req, upsert)
+}
+
+// UpsertRecord inserts data, or updates the row it conflicts with on the key
+// chosen by upsert, and returns the row as written. No row is returned if
+// the existing row is kept.
+func (s *SQLiteClient) UpsertRecord(ctx context.Context, table helper.QualifiedName, data map[string]any, upsert model.Upsert) (*model.QueryResponse, error) {
+	return s.writeRecords(ctx, table, func(t *rowTable) (string, []any, error) {
+		keys, primary, err := s.keyConstraints(ctx, helper.QualifiedName{Schema: t.schema, Name: table.Name})
+		if err != nil {
+			return "", nil, err
+		}
+		target, err := newUpsertTarget(keys, primary, upsert)
+		if err != nil {
+			return "", nil, err
+		}
+		return insertSQL(t.from, helper.DialectSQLite, data, target)
+	})
+}
+
+// ImportCSV loads the CSV file src into table with multi-row INSERTs, in
+// one transaction.
+func (s *SQLiteClient) ImportCSV(ctx context.Context, table helper.QualifiedName, src io.Reader, opts model.ImportOptions) (*model.ImportResponse, error) {
+	table, err := s.qualify(table)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := s.ListColumns(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	return importCSV(ctx, s.db, s.tx, columns, src, opts, insertRows(helper.DialectSQLite, table.Quote(helper.DialectSQLite)))
+}
+
+func (s *SQLiteClient) CreateTable(ctx context.Context, table helper.QualifiedName, columns []model.ColumnDef) error {
+	if table.Name == "" || len(columns) == 0 {
+		return fmt.Errorf("invalid table definition")
+	}
+	table, err := s.qualify(table)
+	if err != nil {
+		return err
+	}
+
+	var colDefs []string
+	var pkCols []string
+
+	for _, col := range columns {
+		if err := helper.CheckIdentifiers(helper.DialectSQLite, col.Name); err != nil {
+			return err
+		}
+		colParts := []string{quoteSQLiteIdentifier(col.Name), col.Type}
+		if col.NotNull {
+			colParts = append(colParts, "NOT NULL")
+		}
+		if col.Default != "" {
+			colParts = append(colParts, "DEFAULT "+col.Default)
+		}
+		colDefs = append(colDefs, strings.Join(colParts, " "))
+
+		if col.PrimaryKey {
+			pkCols = append(pkCols, quoteSQLiteIdentifier(col.Name))
+		}
+	}
+
+	if len(pkCols) > 0 {
+		colDefs = append(colDefs, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(pkCols, ", ")))
+	}
+
+	query := fmt.Sprintf(
+		"CREATE TABLE %s (%s)",
+		table.Quote(helper.DialectSQLite),
+		strings.Join(colDefs, ", "),
+	)
+
+	_, err = s.conn().ExecContext(ctx, query)
+	return err
+}
+
+// alterTableSavepoint scopes an AlterTable run inside an open transaction.
+const alterTableSavepoint = "vind_alter_table"
+
+// AlterTable applies the operations in a single transaction. Adding, dropping
+// and renaming columns use SQLite's native ALTER TABLE; changing a column's
+// type, nullability or default is not supported natively and rebuilds the table.
+func (s *SQLiteClient) AlterTable(ctx context.Context, table helper.QualifiedName, ops []model.AlterTableOperation) error {
+	if table.Name == "" || len(ops) == 0 {
+		return fmt.Errorf("invalid alter table request")
+	}
+	table, err := s.qualify(table)
+	if err != nil {
+		return err
+	}
+	if err := checkAlterColumns(helper.DialectSQLite, ops); err != nil {
+		return err
+	}
+
+	for _, op := range ops {
+		switch op.Action {
+		case "add_column":
+			if op.ColumnName == "" || op.Type == "" {
+				return fmt.Errorf("add_column requires column_name and type")
+			}
+		case "drop_column":
+			if op.ColumnName == "" {
+				return fmt.Errorf("drop_column requires column_name")
+			}
+		case "rename_column":
+			if op.ColumnName == "" || op.NewName == "" {
+				return fmt.Errorf("rename_column requires column_name and new_name")
+			}
+		case "alter_column":
+			if op.ColumnName == "" {
+				return fmt.Errorf("alter_column requires column_name")
+			}
+		default:
+			return fmt.Errorf("unsupported action: %s", op.Action)
+		}
+	}
+
+	apply := func(tx *sql.Tx) error {
+		quoted := table.Quote(helper.DialectSQLite)
+		for _, op := range ops {
+			var err error
+			switch op.Action {
+			case "add_column":
+				_, err = tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quoted, quoteSQLiteIdentifier(op.ColumnName), op.Type))
+			case "drop_column":
+				_, err = tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", quoted, quoteSQLiteIdentifier(op.ColumnName)))
+			case "rename_column":
+				_, err = tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", quoted, quoteSQLiteIdentifier(op.ColumnName), quoteSQLiteIdentifier(op.NewName)))
+			case "alter_column":
+				op := op
+				err = rebuildSQLiteTable(ctx, tx, table, func(def *sqliteTableDef) error {
+					col := def.column(op.ColumnName)
+					if col == nil {
+						return fmt.Errorf("column %s not found in table %s", op.ColumnName, table)
+					}
+					if op.Type != "" {
+						col.typeName = op.Type
+					}
+					if op.NotNull != nil {
+						col.removeSegments("notnull", "null")
+						if *op.NotNull {
+							col.segments = append(col.segments, &sqliteSegment{kind: "notnull", text: "NOT NULL"})
+						}
+					}
+					if op.Default != "" {
+						col.removeSegments("default")
+						col.segments = append(col.segments, &sqliteSegment{kind: "default", text: "DEFAULT " + op.Default})
+					}
+					return nil
+				})
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if s.tx == nil {
+		return s.withSchemaChange(ctx, apply)
+	}
+
+	// Only alter_column rebuilds the table; the other actions can run in the
+	// open transaction, under a savepoint so that a failed request leaves
+	// none of its actions applied.
+	for _, op := range ops {
+		if op.Action == "alter_column" {
+			return errors.New("alter_column rebuilds the table and cannot run inside a transaction")
+		}
+	}
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT "+alterTableSavepoint); err != nil {
+		return err
+	}
+	if err := apply(s.tx); err != nil {
+		ctx = context.WithoutCancel(ctx)
+		if _, rbErr := s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+alterTableSavepoint); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		_, relErr := s.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+alterTableSavepoint)
+		return errors.Join(err, relErr)
+	}
+	_, err = s.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+alterTableSavepoint)
+	return err
+}
+
+func (s *SQLiteClient) DropTable(ctx context.Context, table helper.QualifiedName, cascade bool) error {
+	if table.Name == "" {
+		return fmt.Errorf("table name is required")
+	}
+	table, err := s.qualify(table)
+	if err != nil {
+		return err
+	}
+
+	// SQLite has no DROP TABLE ... CASCADE; dependent objects are handled by
+	// the foreign_keys pragma instead, so the flag is ignored.
+	query := fmt.Sprintf("DROP TABLE %s", table.Quote(helper.DialectSQLite))
+
+	_, err = s.conn().ExecContext(ctx, query)
+	return err
+}
+
+// AddConstraint rebuilds the table with the new table-level constraint, since
+// SQLite's ALTER TABLE cannot add constraints to an existing table.
+func (s *SQLiteClient) AddConstraint(ctx context.Context, params model.AddConstraintParams) error {
+	if params.TableName == "" || params.ConstraintName == "" || params.Type == "" {
+		return fmt.Errorf("table_name, constraint_name, and type are required")
+	}
+	table, err := s.qualify(helper.QualifiedName{Schema: params.Schema, Name: params.TableName})
+	if err != nil {
+		return err
+	}
+	if err := checkConstraintNames(helper.DialectSQLite, params); err != nil {
+		return err
+	}
+
+	var kind, body string
+
+	switch strings.ToUpper(params.Type) {
+	case "PRIMARY KEY":
+		if len(params.Columns) == 0 {
+			return fmt.Errorf("columns are required for PRIMARY KEY")
+		}
+		kind = "p"
+		body = fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(quoteSQLiteIdentifiers(params.Columns), ", "))
+	case "UNIQUE":
+		if len(params.Columns) == 0 {
+			return fmt.Errorf("columns are required for UNIQUE constraint")
+		}
+		kind = "u"
+		body = fmt.Sprintf("UNIQUE (%s)", strings.Join(quoteSQLiteIdentifiers(params.Columns), ", "))
+	case "FOREIGN KEY":
+		if len(params.Columns) == 0 || params.RefTable == "" || len(params.RefColumns) == 0 {
+			return fmt.Errorf("columns, ref_table, and ref_columns are required for FOREIGN KEY")
+		}
+		// A foreign key can only reference a table in its own schema, so
+		// REFERENCES takes a bare name.
+		if params.RefSchema != "" && params.RefSchema != table.Schema {
+			return fmt.Errorf("foreign key cannot reference a table in another schema")
+		}
+		kind = "f"
+		body = fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)",
+			strings.Join(quoteSQLiteIdentifiers(params.Columns), ", "),
+			quoteSQLiteIdentifier(params.RefTable),
+			strings.Join(quoteSQLiteIdentifiers(params.RefColumns), ", "),
+		)
+		if params.OnDelete != "" {
+			body += fmt.Sprintf(" ON DELETE %s", params.OnDelete)
+		}
+		if params.OnUpdate != "" {
+			body += fmt.Sprintf(" ON UPDATE %s", params.OnUpdate)
+		}
+	case "CHECK":
+		if params.CheckExpr == "" {
+			return fmt.Errorf("check_expr is required for CHECK constraint")
+		}
+		kind = "c"
+		body = fmt.Sprintf("CHECK (%s)", params.CheckExpr)
+	default:
+		return fmt.Errorf("unsupported constraint type: %s", params.Type)
+	}
+
+	return s.withSchemaChange(ctx, func(tx *sql.Tx) error {
+		return rebuildSQLiteTable(ctx, tx, table, func(def *sqliteTableDef) error {
+			for _, ref := range def.constraints() {
+				if strings.EqualFold(ref.info.ConstraintName, params.ConstraintName) {
+					return fmt.Errorf("constraint %s already exists on table %s", params.ConstraintName, table)
+				}
+			}
+			def.tableConstraints = append(def.tableConstraints, &sqliteTableConstraint{
+				name:    params.ConstraintName,
+				kind:    kind,
+				columns: params.Columns,
+				text:    fmt.Sprintf("CONSTRAINT %s %s", quoteSQLiteIdentifier(params.ConstraintName), body),
+			})
+			return nil
+		})
+	})
+}
+
+// DropConstraint rebuilds the table without the named constraint. Names are
+// the ones reported by ListConstraints, including generated names for
+// constraints that were declared without one.
+func (s *SQLiteClient) DropConstraint(ctx context.Context, table helper.QualifiedName, constraintName string, cascade bool) error {
+	if table.Name == "" || constraintName == "" {
+		return fmt.Errorf("table_name and constraint_name are required")
+	}
+	table, err := s.qualify(table)
+	if err != nil {
+		return err
+	}
+
+	return s.withSchemaChange(ctx, func(tx *sql.Tx) error {
+		return rebuildSQLiteTable(ctx, tx, table, func(def *sqliteTableDef) error {
+			for _, ref := range def.constraints() {
+				if ref.info.ConstraintName == constraintName {
+					ref.remove()
+					return nil
+				}
+			}
+			return fmt.Errorf("constraint %s not found on table %s", constraintName, table)
+		})
+	})
+}
+
+// ListConstraints reports the constraints declared in the table's CREATE
+// TABLE statement in sqlite_master, using the same single-letter type codes
+// as PostgreSQL.
+func (s *SQLiteClient) ListConstraints(ctx context.Context, table helper.QualifiedName) ([]model.ConstraintInfo, error) {
+	table, err := s.qualify(table)
+	if err != nil {
+		return nil, err
+	}
+	createSQL, err := sqliteTableSQL(ctx, s.conn(), table)
+	if err != nil {
+		return nil, err
+	}
+
+	def, err := parseSQLiteTable(table.Name, createSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	var constraints []model.ConstraintInfo
+	for _, ref := range def.constraints() {
+		constraints = append(constraints, ref.info)
+	}
+	return constraints, nil
+}
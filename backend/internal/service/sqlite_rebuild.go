@@ -0,0 +1,613 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"vind/backend/helper"
+	"vind/backend/internal/model"
+)
+
+// SQLite can only add, drop and rename columns in place. Every other schema
+// change follows the procedure from https://www.sqlite.org/lang_altertable.html:
+// the CREATE TABLE statement stored in sqlite_master is parsed, modified,
+// and used to create a replacement table that the data is copied into.
+
+// sqlToken is a token of helper.TokenizeSQL or, with its text starting with
+// "(", a whole parenthesised group.
+type sqlToken struct {
+	text  string
+	start int
+	end   int
+	kind  helper.SQLTokenKind
+}
+
+// word returns the upper-cased token text for unquoted words, or "" otherwise,
+// so keywords can be compared without matching quoted identifiers.
+func (t sqlToken) word() string {
+	if t.kind != helper.SQLWord {
+		return ""
+	}
+	return strings.ToUpper(t.text)
+}
+
+// tokenizeSQLite splits s into the tokens of helper.TokenizeSQL, folding
+// each parenthesised group into a single token. An unclosed group runs to
+// the end of s.
+func tokenizeSQLite(s string) []sqlToken {
+	lexed := helper.TokenizeSQL(s, helper.DialectSQLite)
+	tokens := make([]sqlToken, 0, len(lexed))
+	for i := 0; i < len(lexed); i++ {
+		t := lexed[i]
+		end := t.End
+		if t.Kind == helper.SQLPunct && t.Text == "(" {
+			end = len(s)
+			for depth := 0; i < len(lexed); i++ {
+				if lexed[i].Kind != helper.SQLPunct {
+					continue
+				}
+				if lexed[i].Text == "(" {
+					depth++
+				} else if lexed[i].Text == ")" {
+					if depth--; depth == 0 {
+						end = lexed[i].End
+						break
+					}
+				}
+			}
+		}
+		tokens = append(tokens, sqlToken{text: s[t.Start:end], start: t.Start, end: end, kind: t.Kind})
+	}
+	return tokens
+}
+
+// splitSQLTokens splits tokens on top-level commas.
+func splitSQLTokens(tokens []sqlToken) [][]sqlToken {
+	var parts [][]sqlToken
+	var current []sqlToken
+	for _, tok := range tokens {
+		if tok.text == "," {
+			parts = append(parts, current)
+			current = nil
+			continue
+		}
+		current = append(current, tok)
+	}
+	return append(parts, current)
+}
+
+func unquoteSQLiteIdentifier(s string) string {
+	if len(s) >= 2 {
+		switch first, last := s[0], s[len(s)-1]; {
+		case first == '"' && last == '"':
+			return strings.ReplaceAll(s[1:len(s)-1], `""`, `"`)
+		case first == '`' && last == '`':
+			return strings.ReplaceAll(s[1:len(s)-1], "``", "`")
+		case first == '\'' && last == '\'':
+			return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+		case first == '[' && last == ']':
+			return s[1 : len(s)-1]
+		}
+	}
+	return s
+}
+
+// groupColumns returns the column names listed in a "(a, b DESC)" group.
+func groupColumns(group string) []string {
+	if len(group) < 2 {
+		return nil
+	}
+	var cols []string
+	for _, part := range splitSQLTokens(tokenizeSQLite(group[1 : len(group)-1])) {
+		if len(part) > 0 {
+			cols = append(cols, unquoteSQLiteIdentifier(part[0].text))
+		}
+	}
+	return cols
+}
+
+// sqliteSegment is one column constraint, e.g. "NOT NULL" or
+// "CONSTRAINT fk REFERENCES users (id) ON DELETE CASCADE".
+type sqliteSegment struct {
+	name string // from CONSTRAINT <name>, empty when unnamed
+	kind string // "p", "u", "f", "c", "notnull", "null", "default", "collate" or "generated"
+	text string
+}
+
+// body returns the segment text without its CONSTRAINT <name> prefix.
+func (s *sqliteSegment) body() string {
+	tokens := tokenizeSQLite(s.text)
+	if len(tokens) > 2 && tokens[0].word() == "CONSTRAINT" {
+		return s.text[tokens[2].start:]
+	}
+	return s.text
+}
+
+type sqliteColumnDef struct {
+	name     string
+	nameText string
+	typeName string
+	segments []*sqliteSegment
+}
+
+func (c *sqliteColumnDef) removeSegments(kinds ...string) {
+	c.segments = slices.DeleteFunc(c.segments, func(s *sqliteSegment) bool {
+		return slices.Contains(kinds, s.kind)
+	})
+}
+
+func (c *sqliteColumnDef) generated() bool {
+	return slices.ContainsFunc(c.segments, func(s *sqliteSegment) bool { return s.kind == "generated" })
+}
+
+func (c *sqliteColumnDef) String() string {
+	parts := []string{c.nameText}
+	if c.typeName != "" {
+		parts = append(parts, c.typeName)
+	}
+	for _, s := range c.segments {
+		parts = append(parts, s.text)
+	}
+	return strings.Join(parts, " ")
+}
+
+type sqliteTableConstraint struct {
+	name    string
+	kind    string
+	columns []string
+	text    string
+}
+
+type sqliteTableDef struct {
+	name             string
+	columns          []*sqliteColumnDef
+	tableConstraints []*sqliteTableConstraint
+	suffix           string // table options such as WITHOUT ROWID or STRICT
+}
+
+var sqliteSegmentKinds = map[string]string{
+	"PRIMARY":    "p",
+	"UNIQUE":     "u",
+	"REFERENCES": "f",
+	"CHECK":      "c",
+	"NOT":        "notnull",
+	"NULL":       "null",
+	"DEFAULT":    "default",
+	"COLLATE":    "collate",
+	"GENERATED":  "generated",
+	"AS":         "generated",
+}
+
+// startsSegment reports whether tokens[j] begins a new column constraint
+// within a segment that started at tokens[start].
+func startsSegment(tokens []sqlToken, start, j int) bool {
+	word := tokens[j].word()
+	if word != "CONSTRAINT" && sqliteSegmentKinds[word] == "" {
+		return false
+	}
+	if j > 0 {
+		prev := tokens[j-1].word()
+		switch {
+		case prev == "CONSTRAINT":
+			return false // the constraint's name
+		case j == start+2 && tokens[start].word() == "CONSTRAINT":
+			return false // the keyword after CONSTRAINT <name>
+		case word == "NULL" && (prev == "NOT" || prev == "SET"):
+			return false
+		case word == "DEFAULT" && prev == "SET":
+			return false
+		case word == "AS" && prev == "ALWAYS":
+			return false
+		}
+	}
+	if word == "NOT" && j+1 < len(tokens) && tokens[j+1].word() == "DEFERRABLE" {
+		return false
+	}
+	return true
+}
+
+func parseSQLiteColumn(src string, tokens []sqlToken) *sqliteColumnDef {
+	col := &sqliteColumnDef{
+		name:     unquoteSQLiteIdentifier(tokens[0].text),
+		nameText: tokens[0].text,
+	}
+
+	i := 1
+	for i < len(tokens) && !startsSegment(tokens, i, i) {
+		i++
+	}
+	if i > 1 {
+		col.typeName = src[tokens[1].start:tokens[i-1].end]
+	}
+
+	for i < len(tokens) {
+		start := i
+		i++
+		for i < len(tokens) && !startsSegment(tokens, start, i) {
+			i++
+		}
+
+		seg := &sqliteSegment{text: src[tokens[start].start:tokens[i-1].end]}
+		keyword := tokens[start].word()
+		if keyword == "CONSTRAINT" && start+2 < i {
+			seg.name = unquoteSQLiteIdentifier(tokens[start+1].text)
+			keyword = tokens[start+2].word()
+		}
+		seg.kind = sqliteSegmentKinds[keyword]
+		col.segments = append(col.segments, seg)
+	}
+	return col
+}
+
+func parseSQLiteTableConstraint(src string, tokens []sqlToken) *sqliteTableConstraint {
+	tc := &sqliteTableConstraint{text: src[tokens[0].start:tokens[len(tokens)-1].end]}
+
+	rest := tokens
+	if rest[0].word() == "CONSTRAINT" && len(rest) > 2 {
+		tc.name = unquoteSQLiteIdentifier(rest[1].text)
+		rest = rest[2:]
+	}
+
+	switch rest[0].word() {
+	case "PRIMARY":
+		tc.kind = "p"
+	case "UNIQUE":
+		tc.kind = "u"
+	case "FOREIGN":
+		tc.kind = "f"
+	case "CHECK":
+		tc.kind = "c"
+	}
+
+	if tc.kind != "c" {
+		for _, tok := range rest {
+			if strings.HasPrefix(tok.text, "(") {
+				tc.columns = groupColumns(tok.text)
+				break
+			}
+		}
+	}
+	return tc
+}
+
+func parseSQLiteTable(name, createSQL string) (*sqliteTableDef, error) {
+	tokens := tokenizeSQLite(createSQL)
+	idx := slices.IndexFunc(tokens, func(t sqlToken) bool { return strings.HasPrefix(t.text, "(") })
+	if idx < 0 {
+		return nil, fmt.Errorf("cannot parse definition of table %s", name)
+	}
+
+	group := tokens[idx]
+	body := group.text[1 : len(group.text)-1]
+	def := &sqliteTableDef{
+		name:   name,
+		suffix: strings.TrimSpace(createSQL[group.end:]),
+	}
+
+	for _, item := range splitSQLTokens(tokenizeSQLite(body)) {
+		if len(item) == 0 {
+			continue
+		}
+		switch item[0].word() {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			def.tableConstraints = append(def.tableConstraints, parseSQLiteTableConstraint(body, item))
+		default:
+			def.columns = append(def.columns, parseSQLiteColumn(body, item))
+		}
+	}
+	return def, nil
+}
+
+func (d *sqliteTableDef) column(name string) *sqliteColumnDef {
+	for _, col := range d.columns {
+		if strings.EqualFold(col.name, name) {
+			return col
+		}
+	}
+	return nil
+}
+
+// createSQL returns the CREATE TABLE statement for the table named by the
+// quoted name table.
+func (d *sqliteTableDef) createSQL(table string) string {
+	var items []string
+	for _, col := range d.columns {
+		items = append(items, col.String())
+	}
+	for _, tc := range d.tableConstraints {
+		items = append(items, tc.text)
+	}
+
+	query := fmt.Sprintf("CREATE TABLE %s (\n\t%s\n)", table, strings.Join(items, ",\n\t"))
+	if d.suffix != "" {
+		query += " " + d.suffix
+	}
+	return query
+}
+
+type sqliteConstraintRef struct {
+	info    model.ConstraintInfo
+	columns []string // constrained columns; empty for table CHECK constraints
+	remove  func()
+}
+
+// constraints lists every PRIMARY KEY, UNIQUE, FOREIGN KEY and CHECK
+// constraint in the definition, whether declared on a column or on the table.
+// Unnamed constraints get PostgreSQL-style generated names so they can be
+// referred to by DropConstraint.
+func (d *sqliteTableDef) constraints() []sqliteConstraintRef {
+	used := map[string]bool{}
+	nameFor := func(given, kind string, cols []string) string {
+		if given != "" {
+			used[given] = true
+			return given
+		}
+		var base string
+		switch kind {
+		case "p":
+			base = d.name + "_pkey"
+		case "u":
+			base = d.name + "_" + strings.Join(cols, "_") + "_key"
+		case "f":
+			base = d.name + "_" + strings.Join(cols, "_") + "_fkey"
+		default:
+			base = strings.Join(append([]string{d.name}, cols...), "_") + "_check"
+		}
+		name := base
+		for n := 1; used[name]; n++ {
+			name = fmt.Sprintf("%s%d", base, n)
+		}
+		used[name] = true
+		return name
+	}
+
+	var refs []sqliteConstraintRef
+	for _, col := range d.columns {
+		for _, seg := range col.segments {
+			var definition string
+			quotedCol := quoteSQLiteIdentifier(col.name)
+			switch seg.kind {
+			case "p":
+				definition = fmt.Sprintf("PRIMARY KEY (%s)", quotedCol)
+			case "u":
+				definition = fmt.Sprintf("UNIQUE (%s)", quotedCol)
+			case "f":
+				definition = fmt.Sprintf("FOREIGN KEY (%s) %s", quotedCol, seg.body())
+			case "c":
+				definition = seg.body()
+			default:
+				continue
+			}
+
+			col, seg := col, seg
+			refs = append(refs, sqliteConstraintRef{
+				info: model.ConstraintInfo{
+					ConstraintName: nameFor(seg.name, seg.kind, []string{col.name}),
+					ConstraintType: seg.kind,
+					TableName:      d.name,
+					Definition:     definition,
+				},
+				columns: []string{col.name},
+				remove: func() {
+					col.segments = slices.DeleteFunc(col.segments, func(s *sqliteSegment) bool { return s == seg })
+				},
+			})
+		}
+	}
+
+	for _, tc := range d.tableConstraints {
+		if tc.kind == "" {
+			continue
+		}
+		definition := tc.text
+		if tokens := tokenizeSQLite(tc.text); tc.name != "" && len(tokens) > 2 {
+			definition = tc.text[tokens[2].start:]
+		}
+
+		tc := tc
+		refs = append(refs, sqliteConstraintRef{
+			info: model.ConstraintInfo{
+				ConstraintName: nameFor(tc.name, tc.kind, tc.columns),
+				ConstraintType: tc.kind,
+				TableName:      d.name,
+				Definition:     definition,
+			},
+			columns: tc.columns,
+			remove: func() {
+				d.tableConstraints = slices.DeleteFunc(d.tableConstraints, func(c *sqliteTableConstraint) bool { return c == tc })
+			},
+		})
+	}
+	return refs
+}
+
+type sqliteRowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func sqliteTableSQL(ctx context.Context, q sqliteRowQueryer, table helper.QualifiedName) (string, error) {
+	var createSQL string
+	query := fmt.Sprintf(`SELECT sql FROM %s.sqlite_master WHERE type = 'table' AND name = ?`, quoteSQLiteIdentifier(table.Schema))
+	err := q.QueryRowContext(ctx, query, table.Name).Scan(&createSQL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("table %s not found", table)
+	}
+	return createSQL, err
+}
+
+// qualifySQLiteObject qualifies the name of the index, trigger or view that
+// objSQL creates with schema. SQLite stores these statements without the
+// schema they were created in, so they would otherwise be restored in main.
+func qualifySQLiteObject(schema, objSQL string) string {
+	if schema == "main" {
+		return objSQL
+	}
+	tokens := tokenizeSQLite(objSQL)
+	for _, tok := range tokens {
+		switch tok.word() {
+		case "CREATE", "UNIQUE", "TEMP", "TEMPORARY", "INDEX", "TRIGGER", "VIEW", "IF", "NOT", "EXISTS":
+			continue
+		}
+		return objSQL[:tok.start] + quoteSQLiteIdentifier(schema) + "." + objSQL[tok.start:]
+	}
+	return objSQL
+}
+
+// withSchemaChange runs fn in a transaction on a dedicated connection with
+// foreign key enforcement switched off, as the rebuild procedure requires,
+// and verifies foreign keys before committing.
+func (s *SQLiteClient) withSchemaChange(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if s.tx != nil {
+		// Foreign key enforcement cannot be switched off inside a transaction,
+		// and dropping the old table with it on would cascade to other tables.
+		return errors.New("this schema change rebuilds the table and cannot run inside a transaction")
+	}
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var foreignKeys bool
+	if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		return err
+	}
+	if foreignKeys {
+		if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+			return err
+		}
+		defer restoreForeignKeys(ctx, conn)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if foreignKeys {
+		rows, err := tx.QueryContext(ctx, "PRAGMA foreign_key_check")
+		if err != nil {
+			return err
+		}
+		violated := rows.Next()
+		rows.Close()
+		if violated {
+			return errors.New("schema change would violate foreign key constraints")
+		}
+	}
+
+	return tx.Commit()
+}
+
+// restoreForeignKeys switches foreign key enforcement back on for conn, even
+// if ctx is done. A connection it cannot be switched on for is discarded
+// rather than returned to the pool, where it would skip the checks.
+func restoreForeignKeys(ctx context.Context, conn *sql.Conn) {
+	if _, err := conn.ExecContext(context.WithoutCancel(ctx), "PRAGMA foreign_keys = ON"); err != nil {
+		conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+}
+
+// rebuildSQLiteTable recreates table from its modified definition inside tx,
+// copying the data and restoring its indexes, triggers and dependent views.
+func rebuildSQLiteTable(ctx context.Context, tx *sql.Tx, table helper.QualifiedName, mutate func(def *sqliteTableDef) error) error {
+	createSQL, err := sqliteTableSQL(ctx, tx, table)
+	if err != nil {
+		return err
+	}
+	def, err := parseSQLiteTable(table.Name, createSQL)
+	if err != nil {
+		return err
+	}
+
+	oldColumns := map[string]bool{}
+	for _, col := range def.columns {
+		if !col.generated() {
+			oldColumns[strings.ToLower(col.name)] = true
+		}
+	}
+
+	if err := mutate(def); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`
+		SELECT type, name, sql FROM %s.sqlite_master
+		WHERE sql IS NOT NULL
+			AND ((type IN ('index', 'trigger') AND tbl_name = ?) OR type = 'view')
+	`, quoteSQLiteIdentifier(table.Schema)), table.Name)
+	if err != nil {
+		return err
+	}
+	var dependents, views []string
+	for rows.Next() {
+		var objType, name, objSQL string
+		if err := rows.Scan(&objType, &name, &objSQL); err != nil {
+			rows.Close()
+			return err
+		}
+		if objType != "view" {
+			dependents = append(dependents, qualifySQLiteObject(table.Schema, objSQL))
+		} else if strings.Contains(strings.ToLower(objSQL), strings.ToLower(table.Name)) {
+			views = append(views, name)
+			dependents = append(dependents, qualifySQLiteObject(table.Schema, objSQL))
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, view := range views {
+		dropSQL := "DROP VIEW " + helper.QualifiedName{Schema: table.Schema, Name: view}.Quote(helper.DialectSQLite)
+		if _, err := tx.ExecContext(ctx, dropSQL); err != nil {
+			return err
+		}
+	}
+
+	tmp := helper.QualifiedName{Schema: table.Schema, Name: "vind_rebuild_" + table.Name}
+	if _, err := tx.ExecContext(ctx, def.createSQL(tmp.Quote(helper.DialectSQLite))); err != nil {
+		return fmt.Errorf("failed to create rebuilt table: %w", err)
+	}
+
+	var copyColumns []string
+	for _, col := range def.columns {
+		if !col.generated() && oldColumns[strings.ToLower(col.name)] {
+			copyColumns = append(copyColumns, quoteSQLiteIdentifier(col.name))
+		}
+	}
+	if len(copyColumns) > 0 {
+		cols := strings.Join(copyColumns, ", ")
+		copySQL := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s",
+			tmp.Quote(helper.DialectSQLite), cols, cols, table.Quote(helper.DialectSQLite))
+		if _, err := tx.ExecContext(ctx, copySQL); err != nil {
+			return fmt.Errorf("failed to copy data into rebuilt table: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DROP TABLE "+table.Quote(helper.DialectSQLite)); err != nil {
+		return err
+	}
+	// RENAME TO takes a bare name; the table stays in its schema.
+	renameSQL := fmt.Sprintf("ALTER TABLE %s RENAME TO %s", tmp.Quote(helper.DialectSQLite), quoteSQLiteIdentifier(table.Name))
+	if _, err := tx.ExecContext(ctx, renameSQL); err != nil {
+		return err
+	}
+
+	for _, objSQL := range dependents {
+		if _, err := tx.ExecContext(ctx, objSQL); err != nil {
+			return fmt.Errorf("failed to restore %q: %w", objSQL, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,730 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vind/backend/helper"
+	"vind/backend/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSQLiteClient(t *testing.T, setup ...string) *SQLiteClient {
+	t.Helper()
+	s := NewSQLiteClient()
+	require.NoError(t, s.Connect(":memory:"))
+	t.Cleanup(func() { s.Disconnect() })
+	for _, stmt := range setup {
+		_, err := s.db.Exec(stmt)
+		require.NoError(t, err)
+	}
+	return s
+}
+
+func TestSQLiteGetTableDataFields(t *testing.T) {
+	s := newTestSQLiteClient(t,
+		`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, avatar BLOB, meta JSON)`,
+		`INSERT INTO users VALUES (1, 'Alice', x'00ff', '{"admin":true}')`,
+	)
+
+	resp, err := s.GetTableData(context.Background(), model.TableDataRequest{Table: "users", Limit: "10", Offset: "0"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"id", "name", "avatar", "meta"}, resp.Columns)
+	require.Len(t, resp.Fields, 4)
+
+	id, name, avatar := resp.Fields[0], resp.Fields[1], resp.Fields[2]
+	assert.Equal(t, "main", id.Schema)
+	assert.Equal(t, "users", id.Table)
+	assert.True(t, id.PrimaryKey)
+	assert.False(t, name.PrimaryKey)
+	require.NotNil(t, name.Nullable)
+	assert.False(t, *name.Nullable)
+	require.NotNil(t, avatar.Nullable)
+	assert.True(t, *avatar.Nullable)
+	assert.True(t, avatar.Binary)
+
+	require.Len(t, resp.Rows, 1)
+	assert.Equal(t, "Alice", resp.Rows[0][1])
+	assert.Equal(t, "00ff", resp.Rows[0][2])
+	meta, err := json.Marshal(resp.Rows[0][3])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"admin":true}`, string(meta))
+}
+
+func TestSQLiteGetTableDataFilters(t *testing.T) {
+	s := newTestSQLiteClient(t,
+		`CREATE TABLE tickets (id INTEGER PRIMARY KEY, status TEXT, title TEXT, deleted_at TEXT)`,
+		`INSERT INTO tickets VALUES (1, 'open', 'Login fails', NULL), (2, 'closed', 'Slow page', NULL),
+			(3, 'pending', 'LOGIN loop', NULL), (4, 'open', 'Old', '2024-01-01')`,
+	)
+
+	tests := []struct {
+		name    string
+		filters []string
+		wantIDs []any
+	}{
+		{"in and is null", []string{"status:in:open,pending", "deleted_at:is_null"}, []any{int64(1), int64(3)}},
+		{"or group", []string{"status:=:closed|title:ilike:login%"}, []any{int64(1), int64(2), int64(3)}},
+		{"between and not like", []string{"id:between:2,4", "title:not like:%o%"}, []any{}},
+		{"is not null", []string{"deleted_at:is not null"}, []any{int64(4)}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := s.GetTableData(context.Background(), model.TableDataRequest{
+				Table: "tickets", Limit: "10", Offset: "0", OrderBy: "id", Filters: tc.filters,
+			})
+			require.NoError(t, err)
+			ids := []any{}
+			for _, row := range resp.Rows {
+				ids = append(ids, row[0])
+			}
+			assert.Equal(t, tc.wantIDs, ids)
+		})
+	}
+
+	// SQLite would read an unknown double-quoted column as a string.
+	_, err := s.GetTableData(context.Background(), model.TableDataRequest{
+		Table: "tickets", Limit: "10", Offset: "0", Filters: []string{"state:=:state"},
+	})
+	var filterErr *helper.FilterError
+	assert.ErrorAs(t, err, &filterErr)
+	assert.EqualError(t, err, `invalid filter "state:=:state": unknown column "state"`)
+}
+
+func TestSQLiteGetTableDataOrderBy(t *testing.T) {
+	s := newTestSQLiteClient(t,
+		`CREATE TABLE events (id INTEGER PRIMARY KEY, kind TEXT, at TEXT)`,
+		`INSERT INTO events VALUES (1, 'a', '2024-01-02'), (2, 'b', NULL), (3, 'a', '2024-01-03'), (4, 'b', '2024-01-01')`,
+	)
+
+	resp, err := s.GetTableData(context.Background(), model.TableDataRequest{
+		Table: "events", Limit: "10", Offset: "0", OrderBy: "kind:asc,at:desc:nulls_first",
+	})
+	require.NoError(t, err)
+	ids := []any{}
+	for _, row := range resp.Rows {
+		ids = append(ids, row[0])
+	}
+	assert.Equal(t, []any{int64(3), int64(1), int64(2), int64(4)}, ids)
+
+	_, err = s.GetTableData(context.Background(), model.TableDataRequest{
+		Table: "events", Limit: "10", Offset: "0", OrderBy: "created_at:desc",
+	})
+	assert.EqualError(t, err, `invalid order_by "created_at:desc": unknown column "created_at"`)
+}
+
+func TestSQLiteGetTableDataCursor(t *testing.T) {
+	s := newTestSQLiteClient(t,
+		`CREATE TABLE logs (id INTEGER PRIMARY KEY, at TEXT)`,
+		`INSERT INTO logs VALUES (1, '2024-01-03'), (2, NULL), (3, '2024-01-01'), (4, '2024-01-03'), (5, '2024-01-02')`,
+	)
+	ctx := context.Background()
+	page := func(cursor string) *model.TableDataResponse {
+		t.Helper()
+		resp, err := s.GetTableData(ctx, model.TableDataRequest{
+			Table: "logs", Limit: "2", Offset: "0", OrderBy: "at:desc:nulls_last", Cursor: cursor,
+		})
+		require.NoError(t, err)
+		return resp
+	}
+	ids := func(resp *model.TableDataResponse) []any {
+		ids := []any{}
+		for _, row := range resp.Rows {
+			ids = append(ids, row[0])
+		}
+		return ids
+	}
+
+	first := page("")
+	assert.Equal(t, []any{int64(1), int64(4)}, ids(first))
+	assert.Empty(t, first.PrevCursor)
+	require.NotNil(t, first.Total)
+	assert.Equal(t, int64(5), *first.Total)
+	assert.Equal(t, TotalExact, first.TotalKind)
+
+	second := page(first.NextCursor)
+	assert.Equal(t, []any{int64(5), int64(3)}, ids(second))
+
+	last := page(second.NextCursor)
+	assert.Equal(t, []any{int64(2)}, ids(last))
+	assert.Empty(t, last.NextCursor)
+
+	back := page(last.PrevCursor)
+	assert.Equal(t, []any{int64(5), int64(3)}, ids(back))
+	assert.Equal(t, []any{int64(1), int64(4)}, ids(page(back.PrevCursor)))
+
+	_, err := s.GetTableData(ctx, model.TableDataRequest{Table: "logs", Limit: "2", Offset: "0", Cursor: first.NextCursor})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, err = s.GetTableData(ctx, model.TableDataRequest{Table: "logs", Limit: "2", Offset: "0", Cursor: "not a cursor"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestSQLiteGetTableDataAll(t *testing.T) {
+	s := newTestSQLiteClient(t,
+		`CREATE TABLE logs (id INTEGER PRIMARY KEY, level TEXT)`,
+		`INSERT INTO logs VALUES (1, 'info'), (2, 'error'), (3, 'info'), (4, 'info')`,
+	)
+
+	resp, err := s.GetTableData(context.Background(), model.TableDataRequest{
+		Table: "logs", Limit: "1", Offset: "2", OrderBy: "id:desc", Filters: []string{"level:=:info"}, Count: "none", All: true,
+	})
+	require.NoError(t, err)
+	ids := []any{}
+	for _, row := range resp.Rows {
+		ids = append(ids, row[0])
+	}
+	assert.Equal(t, []any{int64(4), int64(3), int64(1)}, ids)
+	assert.Empty(t, resp.NextCursor)
+	assert.Empty(t, resp.PrevCursor)
+}
+
+func TestSQLiteExecuteQueryFields(t *testing.T) {
+	s := newTestSQLiteClient(t)
+
+	resp, err := s.ExecuteQuery(context.Background(), "SELECT 1 AS n")
+	require.NoError(t, err)
+
+	require.Len(t, resp.Fields, 1)
+	assert.Equal(t, "n", resp.Fields[0].Name)
+	assert.Empty(t, resp.Fields[0].Table)
+}
+
+func TestSQLiteExecuteQuerySource(t *testing.T) {
+	s := newTestSQLiteClient(t,
+		`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, email TEXT)`,
+		`CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER)`,
+	)
+	ctx := context.Background()
+
+	resp, err := s.ExecuteQuery(ctx, "SELECT u.ID, u.name AS label, upper(email) AS email FROM Users u WHERE id > 0")
+	require.NoError(t, err)
+	require.Len(t, resp.Fields, 3)
+	id, label, email := resp.Fields[0], resp.Fields[1], resp.Fields[2]
+	assert.Equal(t, "main", id.Schema)
+	assert.Equal(t, "users", id.Table)
+	assert.True(t, id.PrimaryKey)
+	assert.Equal(t, "users", label.Table)
+	require.NotNil(t, label.Nullable)
+	assert.False(t, *label.Nullable)
+	assert.Empty(t, email.Table)
+
+	resp, err = s.ExecuteQuery(ctx, "SELECT * FROM users")
+	require.NoError(t, err)
+	for _, f := range resp.Fields {
+		assert.Equal(t, "users", f.Table, f.Name)
+	}
+
+	resp, err = s.ExecuteQuery(ctx, "SELECT users.id FROM users JOIN posts ON posts.user_id = users.id")
+	require.NoError(t, err)
+	assert.Empty(t, resp.Fields[0].Table)
+	assert.False(t, resp.Fields[0].PrimaryKey)
+}
+
+func TestSQLiteGetTableDataColumns(t *testing.T) {
+	s := newTestSQLiteClient(t,
+		`CREATE TABLE files (id INTEGER PRIMARY KEY, name TEXT, data BLOB, meta JSON)`,
+		`INSERT INTO files VALUES (1, 'a.bin', x'00ff10', '{"a":1}')`,
+	)
+	ctx := context.Background()
+
+	resp, err := s.GetTableData(ctx, model.TableDataRequest{
+		Table: "files", Limit: "10", Offset: "0", Columns: []string{"name", "data"}, OmitLarge: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "data", "id"}, resp.Columns)
+	require.Len(t, resp.Fields, 3)
+	assert.False(t, resp.Fields[0].Omitted)
+	assert.True(t, resp.Fields[1].Omitted)
+	assert.False(t, resp.Fields[1].Binary)
+	require.Len(t, resp.Rows, 1)
+	assert.Equal(t, []any{"a.bin", int64(3), int64(1)}, resp.Rows[0])
+
+	_, err = s.GetTableData(ctx, model.TableDataRequest{Table: "files", Limit: "10", Offset: "0", Columns: []string{"size"}})
+	assert.ErrorIs(t, err, ErrUnknownColumn)
+}
+
+func TestSQLiteGetCellValue(t *testing.T) {
+	s := newTestSQLiteClient(t,
+		`CREATE TABLE files (id INTEGER PRIMARY KEY, data BLOB)`,
+		`INSERT INTO files VALUES (1, x'00ff10')`,
+	)
+	ctx := context.Background()
+
+	cell, err := s.GetCellValue(ctx, helper.QualifiedName{Name: "files"}, "data", map[string]any{"id": "1"})
+	require.NoError(t, err)
+	assert.Equal(t, "data", cell.Field.Name)
+	assert.True(t, cell.Field.Binary)
+	assert.Equal(t, "00ff10", cell.Value)
+
+	_, err = s.GetCellValue(ctx, helper.QualifiedName{Name: "files"}, "data", map[string]any{"id": "2"})
+	assert.ErrorIs(t, err, ErrRowNotFound)
+	_, err = s.GetCellValue(ctx, helper.QualifiedName{Name: "files"}, "data", map[string]any{"name": "x"})
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = s.GetCellValue(ctx, helper.QualifiedName{Name: "files"}, "size", map[string]any{"id": "1"})
+	assert.ErrorIs(t, err, ErrUnknownColumn)
+}
+
+func TestSQLiteUpdateRow(t *testing.T) {
+	s := newTestSQLiteClient(t,
+		`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, email TEXT)`,
+		`INSERT INTO users VALUES (1, 'Alice', 'a@x'), (2, 'Bob', 'b@x')`,
+	)
+	ctx := context.Background()
+	name := func(id int) any {
+		t.Helper()
+		var v any
+		require.NoError(t, s.db.QueryRow(`SELECT name FROM users WHERE id = ?`, id).Scan(&v))
+		return v
+	}
+
+	_, err := s.UpdateRow(ctx, model.RowEdit{Table: "users", Key: map[string]any{"id": 1}, Data: map[string]any{"name": "Ann"}, Expected: map[string]any{"name": "Alice"}})
+	require.NoError(t, err)
+	assert.Equal(t, "Ann", name(1))
+	assert.Equal(t, "Bob", name(2))
+
+	_, err = s.UpdateRow(ctx, model.RowEdit{Table: "users", Key: map[string]any{"id": 1}, Data: map[string]any{"name": "Anna"}, Expected: map[string]any{"name": "Alice"}})
+	assert.ErrorIs(t, err, ErrRowConflict)
+	assert.Equal(t, "Ann", name(1))
+
+	_, err = s.UpdateRow(ctx, model.RowEdit{Table: "users", Key: map[string]any{"id": 3}, Data: map[string]any{"name": "Cy"}})
+	assert.ErrorIs(t, err, ErrRowNotFound)
+	_, err = s.UpdateRow(ctx, model.RowEdit{Table: "users", Key: map[string]any{"name": "Bob"}, Data: map[string]any{"name": "Bo"}})
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = s.UpdateRow(ctx, model.RowEdit{Table: "users", Key: map[string]any{"id": 2}, Data: map[string]any{"name": "Bo"}, Version: "7"})
+	assert.ErrorIs(t, err, ErrNoRowVersion)
+}
+
+func TestSQLiteDeleteRowKeys(t *testing.T) {
+	s := newTestSQLiteClient(t,
+		`CREATE TABLE accounts (email TEXT NOT NULL UNIQUE, nick TEXT UNIQUE, plan TEXT)`,
+		`INSERT INTO accounts VALUES ('a@x', 'a', 'free'), ('b@x', 'b', 'free'), ('c@x', NULL, 'pro')`,
+		`CREATE TABLE logs (msg TEXT, level TEXT)`,
+		`INSERT INTO logs VALUES ('boot', 'info'), ('boot', 'info'), ('disk', 'warn')`,
+	)
+	ctx := context.Background()
+	count := func(table string) int {
+		t.Helper()
+		var n int
+		require.NoError(t, s.db.QueryRow(`SELECT COUNT(*) FROM `+table).Scan(&n))
+		return n
+	}
+
+	err := s.DeleteRow(ctx, model.RowEdit{Table: "accounts", Key: map[string]any{"nick": "a"}})
+	assert.EqualError(t, err, "invalid row key: expected values for email")
+	require.NoError(t, s.DeleteRow(ctx, model.RowEdit{Table: "accounts", Key: map[string]any{"email": "a@x"}}))
+	assert.Equal(t, 2, count("accounts"))
+
+	err = s.DeleteRow(ctx, model.RowEdit{Table: "logs", Key: map[string]any{"msg": "disk"}})
+	assert.ErrorIs(t, err, ErrNoPrimaryKey)
+	err = s.DeleteRow(ctx, model.RowEdit{Table: "logs", Key: map[string]any{"msg": "boot"}, Force: true})
+	assert.ErrorIs(t, err, ErrAmbiguousRow)
+	require.NoError(t, s.DeleteRow(ctx, model.RowEdit{Table: "logs", Key: map[string]any{"msg": "disk", "level": "warn"}, Force: true}))
+	assert.Equal(t, 2, count("logs"))
+}
+
+func TestSQLiteSchemaQualifiedNames(t *testing.T) {
+	s := newTestSQLiteClient(t, `ATTACH ':memory:' AS "Other"`)
+	ctx := context.Background()
+	table := helper.QualifiedName{Schema: "Other", Name: "Bestellungen Ü"}
+	count := func(query string, args ...any) int {
+		t.Helper()
+		var n int
+		require.NoError(t, s.db.QueryRow(query, args...).Scan(&n))
+		return n
+	}
+
+	require.NoError(t, s.CreateTable(ctx, table, []model.ColumnDef{
+		{Name: "Id", Type: "INTEGER", PrimaryKey: true},
+		{Name: `Größe "cm"`, Type: "TEXT"},
+	}))
+	_, err := s.db.Exec(`CREATE INDEX "Other"."größe_idx" ON "Bestellungen Ü" ("Größe ""cm""")`)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count(`SELECT COUNT(*) FROM main.sqlite_master`))
+
+	_, err = s.InsertRecord(ctx, table, map[string]any{"Id": 1, `Größe "cm"`: "12"})
+	require.NoError(t, err)
+	written, err := s.UpdateRecord(ctx, table, map[string]any{`Größe "cm"`: "14"}, map[string]any{"Id": 1})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, *written.RowsAffected)
+
+	columns, err := s.ListColumns(ctx, table)
+	require.NoError(t, err)
+	require.Len(t, columns, 2)
+	assert.Equal(t, `Größe "cm"`, columns[1].Name)
+
+	// Changing a column's type rebuilds the table, which must stay in its
+	// schema along with its index.
+	require.NoError(t, s.AlterTable(ctx, table, []model.AlterTableOperation{
+		{Action: "alter_column", ColumnName: `Größe "cm"`, Type: "INTEGER"},
+	}))
+	require.NoError(t, s.AddConstraint(ctx, model.AddConstraintParams{
+		Schema: "Other", TableName: table.Name, ConstraintName: "Größe_positiv", Type: "CHECK", CheckExpr: `"Größe ""cm""" > 0`,
+	}))
+	assert.Equal(t, 0, count(`SELECT COUNT(*) FROM main.sqlite_master`))
+	assert.Equal(t, 1, count(`SELECT COUNT(*) FROM "Other".sqlite_master WHERE type = 'index' AND name = 'größe_idx'`))
+	assert.Equal(t, 1, count(`SELECT COUNT(*) FROM "Other"."Bestellungen Ü" WHERE "Größe ""cm""" = 14`))
+
+	constraints, err := s.ListConstraints(ctx, table)
+	require.NoError(t, err)
+	var names []string
+	for _, c := range constraints {
+		names = append(names, c.ConstraintName)
+	}
+	assert.Contains(t, names, "Größe_positiv")
+
+	err = s.AddConstraint(ctx, model.AddConstraintParams{
+		Schema: "Other", TableName: table.Name, ConstraintName: "fk", Type: "FOREIGN KEY",
+		Columns: []string{"Id"}, RefSchema: "main", RefTable: "t", RefColumns: []string{"id"},
+	})
+	assert.Error(t, err)
+
+	_, err = s.InsertRecord(ctx, table, map[string]any{"bad\x00name": 1})
+	var identErr *helper.IdentifierError
+	assert.ErrorAs(t, err, &identErr)
+
+	written, err = s.DeleteRecord(ctx, table, map[string]any{"Id": 1})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, *written.RowsAffected)
+	require.NoError(t, s.DropTable(ctx, table, false))
+	assert.Equal(t, 0, count(`SELECT COUNT(*) FROM "Other".sqlite_master WHERE type = 'table'`))
+}
+
+func TestSQLiteExecuteBatch(t *testing.T) {
+	s := newTestSQLiteClient(t,
+		`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, note TEXT)`,
+		`INSERT INTO users VALUES (1, 'Ann', NULL)`,
+	)
+	ctx := context.Background()
+	ids := func() []int {
+		t.Helper()
+		rows, err := s.db.Query(`SELECT id FROM users ORDER BY id`)
+		require.NoError(t, err)
+		defer rows.Close()
+		var ids []int
+		for rows.Next() {
+			var id int
+			require.NoError(t, rows.Scan(&id))
+			ids = append(ids, id)
+		}
+		return ids
+	}
+	req := model.BatchRequest{
+		Table:   "users",
+		Inserts: []map[string]any{{"id": 2, "name": "Bo"}, {"id": 3, "name": nil}},
+		Deletes: []map[string]any{{"id": 1}},
+	}
+
+	resp, err := s.ExecuteBatch(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "rolled_back", resp.Transaction)
+	assert.Equal(t, 1, resp.Failed)
+	require.Len(t, resp.Results, 3)
+	assert.Equal(t, model.BatchResult{Op: "insert", Index: 0, RowsAffected: 1}, resp.Results[0])
+	assert.Contains(t, resp.Results[1].Error, "NOT NULL")
+	assert.True(t, resp.Results[2].Skipped)
+	assert.Equal(t, []int{1}, ids())
+
+	req.ContinueOnError = true
+	resp, err = s.ExecuteBatch(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "committed", resp.Transaction)
+	assert.Equal(t, 1, resp.Failed)
+	assert.EqualValues(t, 1, resp.Results[2].RowsAffected)
+	assert.Equal(t, []int{2}, ids())
+
+	resp, err = s.ExecuteBatch(ctx, model.BatchRequest{
+		Table: "users",
+		Updates: []model.BatchUpdate{
+			{Data: map[string]any{"note": "seen"}, Where: map[string]any{"note": nil}},
+			{Data: map[string]any{"bad\x00name": 1}, Where: map[string]any{"id": 2}},
+		},
+		ContinueOnError: true,
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, resp.Results[0].RowsAffected)
+	assert.Contains(t, resp.Results[1].Error, "invalid identifier")
+
+	// Inside an open transaction a failed batch only undoes itself.
+	tx, err := s.BeginTx(ctx)
+	require.NoError(t, err)
+	_, err = tx.InsertRecord(ctx, helper.QualifiedName{Name: "users"}, map[string]any{"id": 4, "name": "Cy"})
+	require.NoError(t, err)
+	resp, err = tx.ExecuteBatch(ctx, model.BatchRequest{
+		Table:   "users",
+		Inserts: []map[string]any{{"id": 5, "name": "Di"}, {"id": 4, "name": "Ed"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "rolled_back", resp.Transaction)
+	require.NoError(t, tx.Commit())
+	assert.Equal(t, []int{2, 4}, ids())
+}
+
+func TestSQLiteRecordsReturnRows(t *testing.T) {
+	s := newTestSQLiteClient(t,
+		`CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, status TEXT NOT NULL DEFAULT 'new', label TEXT AS (upper(name)))`,
+	)
+	ctx := context.Background()
+	table := helper.QualifiedName{Name: "items"}
+
+	written, err := s.InsertRecord(ctx, table, map[string]any{"name": "pen"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "status", "label"}, written.Columns)
+	assert.Equal(t, [][]any{{int64(1), "pen", "new", "PEN"}}, written.Rows)
+	assert.True(t, written.Fields[0].PrimaryKey)
+	assert.Equal(t, "items", written.Fields[0].Table)
+	_, err = s.InsertRecord(ctx, table, map[string]any{"name": "ink"})
+	require.NoError(t, err)
+
+	written, err = s.UpdateRecord(ctx, table, map[string]any{"name": "pencil"}, map[string]any{"id": 1})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, *written.RowsAffected)
+	assert.Equal(t, [][]any{{int64(1), "pencil", "new", "PENCIL"}}, written.Rows)
+
+	written, err = s.UpdateRecord(ctx, table, map[string]any{"status": "gone"}, map[string]any{"id": 3})
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, *written.RowsAffected)
+	assert.Equal(t, [][]any{}, written.Rows)
+
+	written, err = s.DeleteRecord(ctx, table, map[string]any{"status": "new"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, *written.RowsAffected)
+	assert.Len(t, written.Rows, 2)
+}
+
+func TestSQLiteUpsertRecord(t *testing.T) {
+	s := newTestSQLiteClient(t,
+		`CREATE TABLE countries (id INTEGER PRIMARY KEY, code TEXT NOT NULL, name TEXT, CONSTRAINT countries_code UNIQUE (code))`,
+		`INSERT INTO countries VALUES (1, 'DE', 'Deutschland')`,
+		`CREATE TABLE logs (msg TEXT)`,
+	)
+	ctx := context.Background()
+	table := helper.QualifiedName{Name: "countries"}
+	rows := func() [][]any {
+		t.Helper()
+		resp, err := s.ExecuteQuery(ctx, `SELECT id, code, name FROM countries ORDER BY id`)
+		require.NoError(t, err)
+		return resp.Rows
+	}
+
+	_, err := s.UpsertRecord(ctx, table, map[string]any{"id": 1, "code": "DE", "name": "Germany"}, model.Upsert{})
+	require.NoError(t, err)
+	written, err := s.UpsertRecord(ctx, table, map[string]any{"id": 9, "code": "DE", "name": "Allemagne"}, model.Upsert{Constraint: "countries_code", Update: []string{"name"}})
+	require.NoError(t, err)
+	assert.Equal(t, [][]any{{int64(1), "DE", "Allemagne"}}, written.Rows)
+	assert.Equal(t, [][]any{{int64(1), "DE", "Allemagne"}}, rows())
+
+	// An empty update list keeps the existing row, and returns none.
+	written, err = s.UpsertRecord(ctx, table, map[string]any{"code": "DE", "name": "x"}, model.Upsert{Constraint: "countries_code", Update: []string{}})
+	require.NoError(t, err)
+	assert.Empty(t, written.Rows)
+	assert.Equal(t, [][]any{{int64(1), "DE", "Allemagne"}}, rows())
+
+	_, err = s.UpsertRecord(ctx, table, map[string]any{"code": "FR"}, model.Upsert{Constraint: "nope"})
+	assert.ErrorIs(t, err, ErrUnknownConstraint)
+	_, err = s.UpsertRecord(ctx, table, map[string]any{"code": "FR"}, model.Upsert{})
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = s.UpsertRecord(ctx, table, map[string]any{"id": 2, "code": "FR"}, model.Upsert{Update: []string{"name"}})
+	assert.ErrorIs(t, err, ErrUnknownColumn)
+	_, err = s.UpsertRecord(ctx, helper.QualifiedName{Name: "logs"}, map[string]any{"msg": "x"}, model.Upsert{})
+	assert.ErrorIs(t, err, ErrNoPrimaryKey)
+
+	resp, err := s.ExecuteBatch(ctx, model.BatchRequest{
+		Table: "countries",
+		Inserts: []map[string]any{
+			{"code": "DE", "name": "Germany"},
+			{"code": "FR", "name": "France"},
+		},
+		Upsert: &model.Upsert{Constraint: "countries_code"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "committed", resp.Transaction)
+	assert.Equal(t, [][]any{{int64(1), "DE", "Germany"}, {int64(2), "FR", "France"}}, rows())
+}
+
+func TestSQLiteImportCSV(t *testing.T) {
+	s := newTestSQLiteClient(t,
+		`CREATE TABLE items (sku TEXT PRIMARY KEY, name TEXT, qty INTEGER, active BOOLEAN, added DATE)`,
+		`INSERT INTO items VALUES ('A0', 'old', 1, 1, NULL)`,
+	)
+	ctx := context.Background()
+	table := helper.QualifiedName{Name: "items"}
+	rows := func() [][]any {
+		t.Helper()
+		resp, err := s.ExecuteQuery(ctx, `SELECT sku, name, qty, active, added FROM items ORDER BY sku`)
+		require.NoError(t, err)
+		return resp.Rows
+	}
+
+	// Windows-1252, semicolons and a header naming the columns in another
+	// case, with a column the table lacks.
+	file := "SKU;Name;Qty;Active;Added;Note\r\n" +
+		"A1;Caf\xe9;3;yes;2024-05-01;x\r\n" +
+		"A2;B\xe4r;three;no;;y\r\n" +
+		"A3;;;;;\r\n" +
+		"A4;short\r\n"
+	resp, err := s.ImportCSV(ctx, table, strings.NewReader(file), model.ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "windows-1252", resp.Encoding)
+	assert.Equal(t, ";", resp.Delimiter)
+	assert.True(t, resp.Header)
+	assert.Equal(t, map[string]string{"SKU": "sku", "Name": "name", "Qty": "qty", "Active": "active", "Added": "added"}, resp.Mapping)
+	assert.EqualValues(t, 2, resp.RowsImported)
+	assert.EqualValues(t, 2, resp.RowsRejected)
+	assert.Equal(t, []model.ImportRejection{
+		{Line: 3, Column: "qty", Reason: `invalid integer "three"`},
+		{Line: 5, Reason: "expected 6 fields, got 2"},
+	}, resp.Rejected)
+	assert.Equal(t, [][]any{
+		{"A0", "old", int64(1), int64(1), nil},
+		{"A1", "Café", int64(3), int64(1), "2024-05-01"},
+		{"A3", nil, nil, nil, nil},
+	}, rows())
+
+	// Without a header, columns are mapped by position.
+	header := false
+	resp, err = s.ImportCSV(ctx, table, strings.NewReader("7,B1\n8,B2\n"), model.ImportOptions{
+		Header:  &header,
+		Mapping: map[string]string{"1": "qty", "2": "sku"},
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, resp.RowsImported)
+	assert.Len(t, rows(), 5)
+
+	// A row the database refuses rolls the whole file back.
+	_, err = s.ImportCSV(ctx, table, strings.NewReader("sku,name\nC1,new\nA0,dup\n"), model.ImportOptions{})
+	assert.ErrorContains(t, err, "lines 2-3")
+	assert.Len(t, rows(), 5)
+
+	_, err = s.ImportCSV(ctx, table, strings.NewReader("sku\nC1\n"), model.ImportOptions{Mapping: map[string]string{"sku": "nope"}})
+	assert.ErrorIs(t, err, ErrUnknownColumn)
+	_, err = s.ImportCSV(ctx, table, strings.NewReader("x,y\n1,2\n"), model.ImportOptions{Encoding: "klingon"})
+	assert.ErrorIs(t, err, ErrInvalidImport)
+}
+
+func TestParseSQLiteTable(t *testing.T) {
+	def, err := parseSQLiteTable("orders", `CREATE TABLE "orders" ( -- note (
+		id INTEGER PRIMARY KEY /* the key, ) */,
+		"total ""net""" DECIMAL(10, 2) NOT NULL DEFAULT 0.5 CHECK (total > 0),
+		note TEXT DEFAULT 'a, (b',
+		CONSTRAINT [one note] UNIQUE (note, "total ""net"""),
+		FOREIGN KEY (id) REFERENCES other (id) ON DELETE CASCADE
+	) STRICT`)
+	require.NoError(t, err)
+
+	require.Len(t, def.columns, 3)
+	assert.Equal(t, `total "net"`, def.columns[1].name)
+	assert.Equal(t, "DECIMAL(10, 2)", def.columns[1].typeName)
+	var kinds []string
+	for _, seg := range def.columns[1].segments {
+		kinds = append(kinds, seg.kind)
+	}
+	assert.Equal(t, []string{"notnull", "default", "c"}, kinds)
+	assert.Equal(t, "DEFAULT 'a, (b'", def.columns[2].segments[0].text)
+
+	require.Len(t, def.tableConstraints, 2)
+	assert.Equal(t, "one note", def.tableConstraints[0].name)
+	assert.Equal(t, []string{"note", `total "net"`}, def.tableConstraints[0].columns)
+	assert.Equal(t, "f", def.tableConstraints[1].kind)
+	assert.Equal(t, "STRICT", def.suffix)
+}
+
+func TestSQLiteProfileDSN(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "my db?#100%.sqlite")
+	dsn, err := BuildDSN(model.ConnectionProfile{Driver: "sqlite", Database: path})
+	require.NoError(t, err)
+	missing := NewSQLiteClient()
+	assert.Error(t, missing.Connect(dsn), "missing files are not created")
+	missing.Disconnect()
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+	for _, options := range []map[string]string{nil, {"_pragma": "foreign_keys(1)"}} {
+		dsn, err := BuildDSN(model.ConnectionProfile{Driver: "sqlite", Database: path, Options: options})
+		require.NoError(t, err)
+
+		s := NewSQLiteClient()
+		require.NoError(t, s.Connect(dsn))
+		_, err = s.db.Exec(`CREATE TABLE IF NOT EXISTS t (id INTEGER)`)
+		s.Disconnect()
+		require.NoError(t, err)
+	}
+
+	for _, options := range []map[string]string{nil, {"_pragma": "foreign_keys(1)"}} {
+		dsn, err := BuildDSN(model.ConnectionProfile{Driver: "sqlite", Database: ":memory:", Options: options})
+		require.NoError(t, err)
+
+		s := NewSQLiteClient()
+		require.NoError(t, s.Connect(dsn))
+		assert.Equal(t, 1, s.db.Stats().MaxOpenConnections, "in-memory databases use a single connection")
+		_, err = s.db.Exec(`CREATE TABLE t (id INTEGER)`)
+		require.NoError(t, err)
+		_, err = s.db.Exec(`INSERT INTO t VALUES (1)`)
+		s.Disconnect()
+		require.NoError(t, err)
+	}
+}
+
+func TestSQLiteAlterTableInTransaction(t *testing.T) {
+	s := newTestSQLiteClient(t, `CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)`)
+	ctx := context.Background()
+	table := helper.QualifiedName{Name: "users"}
+	columns := func() []string {
+		var names []string
+		rows, err := s.db.Query(`SELECT name FROM pragma_table_info('users')`)
+		require.NoError(t, err)
+		defer rows.Close()
+		for rows.Next() {
+			var name string
+			require.NoError(t, rows.Scan(&name))
+			names = append(names, name)
+		}
+		return names
+	}
+
+	tx, err := s.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.AlterTable(ctx, table, []model.AlterTableOperation{
+		{Action: "add_column", ColumnName: "email", Type: "TEXT"},
+		{Action: "rename_column", ColumnName: "name", NewName: "full_name"},
+	}))
+
+	// A failed request undoes its own actions but not earlier ones.
+	err = tx.AlterTable(ctx, table, []model.AlterTableOperation{
+		{Action: "add_column", ColumnName: "age", Type: "INTEGER"},
+		{Action: "drop_column", ColumnName: "missing"},
+	})
+	assert.Error(t, err)
+
+	err = tx.AlterTable(ctx, table, []model.AlterTableOperation{{Action: "alter_column", ColumnName: "email", Type: "VARCHAR(100)"}})
+	assert.EqualError(t, err, "alter_column rebuilds the table and cannot run inside a transaction")
+
+	require.NoError(t, tx.Commit())
+	assert.Equal(t, []string{"id", "full_name", "email"}, columns())
+}
+
+func TestSQLiteSchemaChangeRestoresForeignKeys(t *testing.T) {
+	s := newTestSQLiteClient(t, `PRAGMA foreign_keys = ON`)
+
+	// A request cancelled during the change still gets enforcement back on
+	// the connection it returns to the pool.
+	ctx, cancel := context.WithCancel(context.Background())
+	err := s.withSchemaChange(ctx, func(tx *sql.Tx) error {
+		cancel()
+		return context.Canceled
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	var enabled bool
+	require.NoError(t, s.db.QueryRow(`PRAGMA foreign_keys`).Scan(&enabled))
+	assert.True(t, enabled)
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"vind/backend/helper"
+	"vind/backend/internal/model"
+)
+
+var (
+	ErrUnknownConstraint = errors.New("no primary key or unique constraint named")
+	ErrAmbiguousUpsert   = errors.New("upsert key is ambiguous")
+)
+
+// upsertTarget is a resolved model.Upsert: inserts that conflict on the key
+// columns update the existing row instead.
+type upsertTarget struct {
+	key    []string
+	update []string // nil to update every inserted column outside key
+}
+
+// newUpsertTarget resolves u against keys, which maps the name of each
+// primary key and unique constraint of a table to its columns. primary names
+// the primary key, or is "" if the table has none.
+func newUpsertTarget(keys map[string][]string, primary string, u model.Upsert) (*upsertTarget, error) {
+	name := u.Constraint
+	if name == "" {
+		if primary == "" {
+			return nil, ErrNoPrimaryKey
+		}
+		name = primary
+	}
+	key, ok := keys[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownConstraint, name)
+	}
+	return &upsertTarget{key: key, update: u.Update}, nil
+}
+
+// newMySQLUpsertTarget resolves u like newUpsertTarget, but only on tables
+// with a single primary key or unique constraint: ON DUPLICATE KEY UPDATE
+// fires on a conflict with any of them, so with several it could update a
+// row that does not match the chosen key.
+func newMySQLUpsertTarget(keys map[string][]string, primary string, u model.Upsert) (*upsertTarget, error) {
+	target, err := newUpsertTarget(keys, primary, u)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) > 1 {
+		name := u.Constraint
+		if name == "" {
+			name = primary
+		}
+		var others []string
+		for other := range keys {
+			if other != name {
+				others = append(others, fmt.Sprintf("%q", other))
+			}
+		}
+		slices.Sort(others)
+		return nil, fmt.Errorf("%w: MySQL updates the row that conflicts with any unique key, and the table also has %s",
+			ErrAmbiguousUpsert, strings.Join(others, ", "))
+	}
+	return target, nil
+}
+
+// scanConstraintColumns reads rows of (constraint, is primary key, column),
+// ordered by constraint and then column position, as returned by the
+// drivers' key constraint queries.
+func scanConstraintColumns(rows *sql.Rows, err error) (map[string][]string, string, error) {
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	keys := map[string][]string{}
+	var primary string
+	for rows.Next() {
+		var name, column string
+		var isPrimary bool
+		if err := rows.Scan(&name, &isPrimary, &column); err != nil {
+			return nil, "", err
+		}
+		keys[name] = append(keys[name], column)
+		if isPrimary {
+			primary = name
+		}
+	}
+	return keys, primary, rows.Err()
+}
+
+// upsertClause returns what follows the VALUES list of an INSERT of columns
+// to make it an upsert on t: ON CONFLICT ... DO UPDATE on Postgres and
+// SQLite, ON DUPLICATE KEY UPDATE on MySQL, which cannot pick the key, see
+// newMySQLUpsertTarget. With nothing to update, the existing row is kept.
+func upsertClause(d helper.SQLDialect, columns []string, t upsertTarget) (string, error) {
+	for _, name := range t.key {
+		if !slices.Contains(columns, name) {
+			return "", fmt.Errorf("%w: upsert needs a value for %s", ErrInvalidKey, name)
+		}
+	}
+	update := t.update
+	if update == nil {
+		for _, name := range columns {
+			if !slices.Contains(t.key, name) {
+				update = append(update, name)
+			}
+		}
+	}
+	set := make([]string, len(update))
+	for i, name := range update {
+		if !slices.Contains(columns, name) {
+			return "", fmt.Errorf("%w %q: only inserted columns can be updated", ErrUnknownColumn, name)
+		}
+		col := helper.QuoteIdentifier(d, name)
+		if d == helper.DialectMySQL {
+			set[i] = col + " = VALUES(" + col + ")"
+		} else {
+			set[i] = col + " = excluded." + col
+		}
+	}
+
+	if d == helper.DialectMySQL {
+		if len(set) == 0 {
+			// Assigning a key column to itself keeps the row unchanged.
+			col := helper.QuoteIdentifier(d, t.key[0])
+			set = []string{col + " = " + col}
+		}
+		return " ON DUPLICATE KEY UPDATE " + strings.Join(set, ", "), nil
+	}
+
+	key := make([]string, len(t.key))
+	for i, name := range t.key {
+		key[i] = helper.QuoteIdentifier(d, name)
+	}
+	clause := " ON CONFLICT (" + strings.Join(key, ", ") + ") DO "
+	if len(set) == 0 {
+		return clause + "NOTHING", nil
+	}
+	return clause + "UPDATE SET " + strings.Join(set, ", "), nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+
+	"vind/backend/helper"
+	"vind/backend/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMySQLUpsertTarget(t *testing.T) {
+	single := map[string][]string{"PRIMARY": {"id"}}
+	several := map[string][]string{"PRIMARY": {"id"}, "users_email": {"email"}, "users_login": {"login"}}
+
+	tests := []struct {
+		name    string
+		keys    map[string][]string
+		primary string
+		upsert  model.Upsert
+		wantKey []string
+		wantErr string
+	}{
+		{name: "primary key only", keys: single, primary: "PRIMARY", wantKey: []string{"id"}},
+		{name: "single unique key", keys: map[string][]string{"users_email": {"email"}}, upsert: model.Upsert{Constraint: "users_email"}, wantKey: []string{"email"}},
+		{
+			name: "primary key among several", keys: several, primary: "PRIMARY",
+			wantErr: `upsert key is ambiguous: MySQL updates the row that conflicts with any unique key, and the table also has "users_email", "users_login"`,
+		},
+		{
+			name: "unique key among several", keys: several, primary: "PRIMARY", upsert: model.Upsert{Constraint: "users_email"},
+			wantErr: `upsert key is ambiguous: MySQL updates the row that conflicts with any unique key, and the table also has "PRIMARY", "users_login"`,
+		},
+		{name: "unknown constraint", keys: single, primary: "PRIMARY", upsert: model.Upsert{Constraint: "nope"}, wantErr: `no primary key or unique constraint named "nope"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			target, err := newMySQLUpsertTarget(tc.keys, tc.primary, tc.upsert)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantKey, target.key)
+
+			clause, err := upsertClause(helper.DialectMySQL, []string{"id", "email", "name"}, *target)
+			require.NoError(t, err)
+			assert.Contains(t, clause, "`name` = VALUES(`name`)")
+			assert.NotContains(t, clause, "`"+tc.wantKey[0]+"` = ")
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"vind/backend/internal/model"
+)
+
+// JSON has no representation for these floats, so they are sent as strings.
+func jsonFloat(f float64) any {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return f
+}
+
+// textValue turns driver byte slices into strings and leaves other values,
+// such as int64 or time.Time, as they are.
+func textValue(v any) any {
+	switch val := v.(type) {
+	case []byte:
+		return string(val)
+	case float64:
+		return jsonFloat(val)
+	}
+	return v
+}
+
+func hexValue(v any) any {
+	if b, ok := v.([]byte); ok {
+		return hex.EncodeToString(b)
+	}
+	return v
+}
+
+// jsonValue embeds JSON documents as-is rather than as quoted strings.
+func jsonValue(v any) any {
+	var b []byte
+	switch val := v.(type) {
+	case []byte:
+		b = val
+	case string:
+		b = []byte(val)
+	default:
+		return v
+	}
+	if !json.Valid(b) {
+		return string(b)
+	}
+	return json.RawMessage(append([]byte(nil), b...))
+}
+
+func dateValue(v any) any {
+	if t, ok := v.(time.Time); ok {
+		return t.Format(time.DateOnly)
+	}
+	return textValue(v)
+}
+
+// timeValue formats time.Time values with layout.
+func timeValue(layout string) func(v any) any {
+	return func(v any) any {
+		if t, ok := v.(time.Time); ok {
+			return t.Format(layout)
+		}
+		return textValue(v)
+	}
+}
+
+// postgresTimeLayouts are the formats of the Postgres time types, which
+// lib/pq returns as time.Time. Types without a time zone are read in UTC
+// and must not be sent with one, and times of day come with a zero date.
+var postgresTimeLayouts = map[string]string{
+	"TIMESTAMP":   "2006-01-02T15:04:05.999999",
+	"TIMESTAMPTZ": "2006-01-02T15:04:05.999999Z07:00",
+	"TIME":        "15:04:05.999999",
+	"TIMETZ":      "15:04:05.999999Z07:00",
+}
+
+// columnMeta describes ct with whatever the driver reports about it.
+func columnMeta(ct *sql.ColumnType) model.ColumnMeta {
+	meta := model.ColumnMeta{Name: ct.Name(), Type: ct.DatabaseTypeName()}
+	if nullable, ok := ct.Nullable(); ok {
+		meta.Nullable = &nullable
+	}
+	// Unbounded types such as text report math.MaxInt64.
+	if length, ok := ct.Length(); ok && length != math.MaxInt64 {
+		meta.Length = &length
+	}
+	if precision, scale, ok := ct.DecimalSize(); ok {
+		meta.Precision, meta.Scale = &precision, &scale
+	}
+	return meta
+}
+
+// postgresColumn converts the values lib/pq returns as text bytes: numeric
+// stays an exact string, json is embedded, arrays become JSON arrays and
+// bytea is hex-encoded. Times carry a zone only if their type has one.
+func postgresColumn(ct *sql.ColumnType) (model.ColumnMeta, func(v any) any) {
+	meta := columnMeta(ct)
+
+	switch typ := meta.Type; {
+	case typ == "BYTEA":
+		meta.Binary = true
+		return meta, hexValue
+	case typ == "JSON" || typ == "JSONB":
+		return meta, jsonValue
+	case typ == "DATE":
+		return meta, dateValue
+	case postgresTimeLayouts[typ] != "":
+		return meta, timeValue(postgresTimeLayouts[typ])
+	case strings.HasPrefix(typ, "_"):
+		elem := postgresArrayElement(strings.TrimPrefix(typ, "_"))
+		return meta, func(v any) any {
+			b, ok := v.([]byte)
+			if !ok {
+				return v
+			}
+			arr, ok := parsePostgresArray(string(b), elem)
+			if !ok {
+				return string(b)
+			}
+			return arr
+		}
+	}
+	return meta, textValue
+}
+
+// postgresArrayElement returns the converter for elements of an array
+// whose element type is typ. Elements arrive as text or nil.
+func postgresArrayElement(typ string) func(s string) any {
+	switch typ {
+	case "INT2", "INT4", "INT8", "OID":
+		return func(s string) any { return json.Number(s) }
+	case "FLOAT4", "FLOAT8":
+		return func(s string) any {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return s
+			}
+			return jsonFloat(f)
+		}
+	case "BOOL":
+		return func(s string) any { return s == "t" }
+	case "JSON", "JSONB":
+		return func(s string) any { return jsonValue(s) }
+	case "BYTEA":
+		return func(s string) any { return strings.TrimPrefix(s, `\x`) }
+	}
+	return func(s string) any { return s }
+}
+
+// parsePostgresArray parses the text form of a Postgres array, such as
+// {1,NULL,"a \"b\"",{2,3}}, converting each element with elem.
+func parsePostgresArray(s string, elem func(s string) any) ([]any, bool) {
+	// Arrays with non-default bounds are prefixed with e.g. "[0:2]=".
+	if strings.HasPrefix(s, "[") {
+		i := strings.IndexByte(s, '=')
+		if i < 0 {
+			return nil, false
+		}
+		s = s[i+1:]
+	}
+
+	arr, rest, ok := parsePostgresArrayLevel(s, elem)
+	return arr, ok && rest == ""
+}
+
+func parsePostgresArrayLevel(s string, elem func(s string) any) ([]any, string, bool) {
+	if !strings.HasPrefix(s, "{") {
+		return nil, s, false
+	}
+	s = s[1:]
+	arr := []any{}
+	if strings.HasPrefix(s, "}") {
+		return arr, s[1:], true
+	}
+
+	for {
+		switch {
+		case strings.HasPrefix(s, "{"):
+			sub, rest, ok := parsePostgresArrayLevel(s, elem)
+			if !ok {
+				return nil, s, false
+			}
+			arr = append(arr, sub)
+			s = rest
+		case strings.HasPrefix(s, `"`):
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, s, false
+			}
+			arr = append(arr, elem(b.String()))
+			s = s[i+1:]
+		default:
+			i := strings.IndexAny(s, ",}")
+			if i < 0 {
+				return nil, s, false
+			}
+			if token := strings.TrimSpace(s[:i]); strings.EqualFold(token, "NULL") {
+				arr = append(arr, nil)
+			} else {
+				arr = append(arr, elem(token))
+			}
+			s = s[i:]
+		}
+
+		switch {
+		case strings.HasPrefix(s, ","):
+			s = s[1:]
+		case strings.HasPrefix(s, "}"):
+			return arr, s[1:], true
+		default:
+			return nil, s, false
+		}
+	}
+}
+
+// PostgresArrayText returns the text form of an array that postgresColumn
+// converted for a column of type typ, such as _TEXT, so that it can be
+// written back as a literal. It reports false if typ is not an array type
+// or v is not an array.
+func PostgresArrayText(typ string, v any) (string, bool) {
+	elem, ok := strings.CutPrefix(typ, "_")
+	arr, isArray := v.([]any)
+	if !ok || !isArray {
+		return "", false
+	}
+	var b strings.Builder
+	writePostgresArray(&b, elem, arr)
+	return b.String(), true
+}
+
+func writePostgresArray(b *strings.Builder, elem string, arr []any) {
+	b.WriteByte('{')
+	for i, v := range arr {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		switch val := v.(type) {
+		case nil:
+			b.WriteString("NULL")
+		case []any:
+			writePostgresArray(b, elem, val)
+		case bool:
+			if val {
+				b.WriteByte('t')
+			} else {
+				b.WriteByte('f')
+			}
+		case json.Number:
+			b.WriteString(val.String())
+		case float64:
+			b.WriteString(strconv.FormatFloat(val, 'g', -1, 64))
+		case json.RawMessage:
+			writePostgresArrayString(b, string(val))
+		case string:
+			if elem == "BYTEA" {
+				val = `\x` + val
+			}
+			writePostgresArrayString(b, val)
+		}
+	}
+	b.WriteByte('}')
+}
+
+// writePostgresArrayString quotes every string element, which keeps ones
+// like NULL or "a,b" intact.
+func writePostgresArrayString(b *strings.Builder, s string) {
+	b.WriteByte('"')
+	b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s))
+	b.WriteByte('"')
+}
+
+// mysqlColumn converts the values the MySQL driver returns. The text
+// protocol returns every value as bytes, so numbers are parsed back, while
+// DECIMAL stays an exact string and binary types are hex-encoded.
+func mysqlColumn(ct *sql.ColumnType) (model.ColumnMeta, func(v any) any) {
+	meta := columnMeta(ct)
+	typ := strings.TrimPrefix(meta.Type, "UNSIGNED ")
+
+	switch typ {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "YEAR":
+		return meta, func(v any) any {
+			if b, ok := v.([]byte); ok {
+				return json.Number(b)
+			}
+			return v
+		}
+	case "FLOAT", "DOUBLE":
+		return meta, func(v any) any {
+			if b, ok := v.([]byte); ok {
+				f, err := strconv.ParseFloat(string(b), 64)
+				if err != nil {
+					return string(b)
+				}
+				return jsonFloat(f)
+			}
+			return textValue(v)
+		}
+	case "JSON":
+		return meta, jsonValue
+	case "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY", "BIT", "GEOMETRY":
+		meta.Binary = true
+		return meta, hexValue
+	case "DATETIME", "TIMESTAMP":
+		// MySQL sends "2006-01-02 15:04:05" without a zone.
+		return meta, func(v any) any {
+			if b, ok := v.([]byte); ok {
+				return strings.Replace(string(b), " ", "T", 1)
+			}
+			return textValue(v)
+		}
+	case "DATE":
+		return meta, dateValue
+	}
+	return meta, textValue
+}
+
+// sqliteColumn converts SQLite values. Column types are only declarations,
+// so blobs are detected by value and JSON only by its declared type.
+func sqliteColumn(ct *sql.ColumnType) (model.ColumnMeta, func(v any) any) {
+	meta := columnMeta(ct)
+
+	switch meta.Type {
+	case "BLOB":
+		meta.Binary = true
+	case "JSON":
+		return meta, jsonValue
+	case "DATE":
+		return meta, dateValue
+	}
+	return meta, func(v any) any {
+		if _, ok := v.([]byte); ok {
+			return hexValue(v)
+		}
+		return textValue(v)
+	}
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePostgresArray(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		elem func(s string) any
+		want []any
+		ok   bool
+	}{
+		{
+			name: "integers and nulls",
+			text: "{1,NULL,3}",
+			elem: postgresArrayElement("INT4"),
+			want: []any{json.Number("1"), nil, json.Number("3")},
+			ok:   true,
+		},
+		{
+			name: "quoted strings",
+			text: `{"a b","say \"hi\"",plain,"NULL"}`,
+			elem: postgresArrayElement("TEXT"),
+			want: []any{"a b", `say "hi"`, "plain", "NULL"},
+			ok:   true,
+		},
+		{
+			name: "nested",
+			text: "{{t,f},{f,t}}",
+			elem: postgresArrayElement("BOOL"),
+			want: []any{[]any{true, false}, []any{false, true}},
+			ok:   true,
+		},
+		{
+			name: "explicit bounds",
+			text: "[0:1]={1.5,NaN}",
+			elem: postgresArrayElement("FLOAT8"),
+			want: []any{1.5, "NaN"},
+			ok:   true,
+		},
+		{
+			name: "empty",
+			text: "{}",
+			elem: postgresArrayElement("TEXT"),
+			want: []any{},
+			ok:   true,
+		},
+		{
+			name: "unterminated",
+			text: `{"a`,
+			elem: postgresArrayElement("TEXT"),
+			ok:   false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := parsePostgresArray(tc.text, tc.elem)
+			assert.Equal(t, tc.ok, ok)
+			if tc.ok {
+				assert.Equal(t, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPostgresArrayTextRoundTrip(t *testing.T) {
+	tests := []struct {
+		typ  string
+		text string
+	}{
+		{typ: "_INT4", text: "{1,NULL,-3}"},
+		{typ: "_TEXT", text: `{"a b","say \"hi\"",plain,"NULL","back\\slash",""}`},
+		{typ: "_BOOL", text: "{{t,f},{f,t}}"},
+		{typ: "_FLOAT8", text: "{1.5,NaN,-Infinity}"},
+		{typ: "_JSONB", text: `{"{\"a\": [1, 2]}",NULL}`},
+		{typ: "_BYTEA", text: `{"\\x00ff"}`},
+		{typ: "_TEXT", text: "{}"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.text, func(t *testing.T) {
+			elem := postgresArrayElement(tc.typ[1:])
+			arr, ok := parsePostgresArray(tc.text, elem)
+			if !assert.True(t, ok) {
+				return
+			}
+			text, ok := PostgresArrayText(tc.typ, arr)
+			assert.True(t, ok)
+			again, ok := parsePostgresArray(text, elem)
+			assert.True(t, ok)
+			assert.Equal(t, arr, again)
+		})
+	}
+
+	_, ok := PostgresArrayText("TEXT", []any{"a"})
+	assert.False(t, ok, "not an array type")
+	_, ok = PostgresArrayText("_TEXT", "{a}")
+	assert.False(t, ok, "not a parsed array")
+}
+
+func TestValueConverters(t *testing.T) {
+	assert.Equal(t, json.RawMessage(`{"a":[1,2]}`), jsonValue([]byte(`{"a":[1,2]}`)))
+	assert.Equal(t, "not json", jsonValue([]byte("not json")))
+	assert.Equal(t, "00ff", hexValue([]byte{0x00, 0xff}))
+	assert.Equal(t, "12.50", textValue([]byte("12.50")))
+	assert.Equal(t, "-Infinity", textValue(math.Inf(-1)))
+	assert.Equal(t, int64(7), textValue(int64(7)))
+}
+
+func TestPostgresTimeValues(t *testing.T) {
+	// lib/pq reads values of types without a zone into a zero offset.
+	naive := time.Date(2024, 3, 1, 10, 30, 0, 500_000_000, time.FixedZone("", 0))
+	berlin := time.Date(2024, 3, 1, 10, 30, 0, 0, time.FixedZone("", 2*60*60))
+
+	tests := []struct {
+		typ   string
+		value any
+		want  any
+	}{
+		{"TIMESTAMP", naive, "2024-03-01T10:30:00.5"},
+		{"TIMESTAMP", []byte("infinity"), "infinity"},
+		{"TIMESTAMPTZ", naive.UTC(), "2024-03-01T10:30:00.5Z"},
+		{"TIMESTAMPTZ", berlin, "2024-03-01T10:30:00+02:00"},
+		{"TIME", time.Date(0, 1, 1, 23, 59, 1, 0, time.FixedZone("", 0)), "23:59:01"},
+		{"TIMETZ", time.Date(0, 1, 1, 8, 0, 0, 0, time.FixedZone("", -5*60*60)), "08:00:00-05:00"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.typ, func(t *testing.T) {
+			assert.Equal(t, tc.want, timeValue(postgresTimeLayouts[tc.typ])(tc.value))
+		})
+	}
+}